@@ -0,0 +1,256 @@
+package artifacts
+
+import (
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths of the Keys of an Amcache.hve hive read by ReadAmcache.
+const (
+    AmcacheFilesPath        = "Root\\InventoryApplicationFile"
+    AmcacheApplicationsPath = "Root\\InventoryApplication"
+    AmcacheDriversPath      = "Root\\InventoryDriverBinary"
+    AmcacheDevicesPath      = "Root\\InventoryDevicePnp"
+    AmcacheLegacyFilesPath  = "Root\\File" // Windows 8 and early Windows 10
+)
+
+// Amcache holds the inventory of an Amcache.hve hive, in which Windows records
+// the programs, drivers and devices found on the system.
+type Amcache struct {
+    Files        []AmcacheFile
+    Applications []AmcacheApplication
+    Drivers      []AmcacheDriver
+    Devices      []AmcacheDevice
+}
+
+// AmcacheFile is an executable file, from InventoryApplicationFile or, in older
+// hives, from the File Key. Fields that a layout doesn't record are left zero.
+type AmcacheFile struct {
+    Key            string    `reg:",keyname"`     // name of the Key of the file
+    SHA1           string    `reg:"FileId"`       // SHA-1 of the file, without the "0000" prefix Amcache stores
+    Path           string    `reg:"LowerCaseLongPath"`
+    Name           string
+    Publisher      string
+    Version        string
+    ProductName    string
+    ProductVersion string
+    BinaryType     string
+    Size           uint64
+    LinkDate       time.Time `reg:"-"`            // link date of the PE file
+    ProgramID      string    `reg:"ProgramId"`    // Key of the AmcacheApplication of the file
+    IsOSComponent  bool      `reg:"IsOsComponent"`
+    Volume         string    `reg:"-"`            // GUID of the volume of the file, older hives only
+    MFTEntry       uint64    `reg:"-"`            // NTFS MFT entry of the file, older hives only
+    MFTSequence    uint16    `reg:"-"`            // NTFS MFT sequence number of the file, older hives only
+    Created        time.Time `reg:"-"`            // creation time of the file, older hives only
+    Modified       time.Time `reg:"-"`            // last modification time of the file, older hives only
+    LastWritten    time.Time `reg:",lastwritten"` // last written time of the Key of the file
+    Err            error     `reg:"-"`            // set when a Value of the Key couldn't be decoded, leaving some fields zero
+}
+
+// amcacheLegacyFile is the layout of the Keys of the File Key of older hives,
+// whose Values are numbered.
+type amcacheLegacyFile struct {
+    ProductName string    `reg:"0"`
+    Publisher   string    `reg:"1"`
+    Version     string    `reg:"5"`
+    Size        uint64    `reg:"6"`
+    Name        string    `reg:"c"`
+    LinkDate    time.Time `reg:"f"`
+    Modified    time.Time `reg:"11"`
+    Created     time.Time `reg:"12"`
+    Path        string    `reg:"15"`
+    ProgramID   string    `reg:"100"`
+    SHA1        string    `reg:"101"`
+    LastWritten time.Time `reg:",lastwritten"`
+}
+
+// AmcacheApplication is an installed program, from InventoryApplication.
+type AmcacheApplication struct {
+    ProgramID       string    `reg:",keyname"`
+    Name            string
+    Version         string
+    Publisher       string
+    InstallDate     time.Time `reg:"-"`
+    Source          string
+    RootDirPath     string
+    UninstallString string
+    Type            string
+    MsiProductCode  string
+    LastWritten     time.Time `reg:",lastwritten"`
+    Err             error     `reg:"-"` // see AmcacheFile.Err
+}
+
+// AmcacheDriver is a driver, from InventoryDriverBinary.
+type AmcacheDriver struct {
+    Path           string    `reg:",keyname"`
+    Name           string    `reg:"DriverName"`
+    SHA1           string    `reg:"DriverId"`
+    Version        string    `reg:"DriverVersion"`
+    Company        string    `reg:"DriverCompany"`
+    Product        string
+    ProductVersion string
+    Service        string
+    Inf            string
+    Signed         bool      `reg:"DriverSigned"`
+    KernelMode     bool      `reg:"DriverIsKernelMode"`
+    LinkDate       time.Time `reg:"-"`
+    LastWritten    time.Time `reg:",lastwritten"`
+    Err            error     `reg:"-"` // see AmcacheFile.Err
+}
+
+// AmcacheDevice is a plug and play device, from InventoryDevicePnp.
+type AmcacheDevice struct {
+    Key                    string    `reg:",keyname"`
+    Description            string
+    BusReportedDescription string
+    Manufacturer           string
+    Model                  string
+    Class                  string
+    Service                string
+    DriverName             string
+    DriverVersion          string    `reg:"DriverVerVersion"`
+    DriverDate             time.Time `reg:"-"`
+    Inf                    string
+    HWID                   []string
+    ParentID               string    `reg:"ParentId"`
+    ContainerID            string    `reg:"ContainerId"`
+    LastWritten            time.Time `reg:",lastwritten"`
+    Err                    error     `reg:"-"` // see AmcacheFile.Err
+}
+
+// ReadAmcache reads the inventory of an Amcache.hve hive, in both the Windows
+// 10 and later layout and the older layout of the File Key. Entries with a Value
+// that can't be decoded are still returned, with their Err field set.
+func ReadAmcache(amcache libregf.Hive) (*Amcache, error) {
+    a := &Amcache{
+        Files:        []AmcacheFile{},
+        Applications: []AmcacheApplication{},
+        Drivers:      []AmcacheDriver{},
+        Devices:      []AmcacheDevice{},
+    }
+
+    err := eachAmcacheKey(amcache, AmcacheFilesPath, func(key libregf.RegistryKey) error {
+        var file AmcacheFile
+        err := libregf.Unmarshal(key, &file)
+        if err != nil && !decodeError(err) { return err }
+        file.Err = err
+        file.SHA1 = amcacheSHA1(file.SHA1)
+        file.LinkDate = amcacheTime(libregf.GetOr(key, "LinkDate", ""))
+        a.Files = append(a.Files, file)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    err = eachAmcacheKey(amcache, AmcacheApplicationsPath, func(key libregf.RegistryKey) error {
+        var app AmcacheApplication
+        err := libregf.Unmarshal(key, &app)
+        if err != nil && !decodeError(err) { return err }
+        app.Err = err
+        app.InstallDate = amcacheTime(libregf.GetOr(key, "InstallDate", ""))
+        a.Applications = append(a.Applications, app)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    err = eachAmcacheKey(amcache, AmcacheDriversPath, func(key libregf.RegistryKey) error {
+        var driver AmcacheDriver
+        err := libregf.Unmarshal(key, &driver)
+        if err != nil && !decodeError(err) { return err }
+        driver.Err = err
+        driver.SHA1 = amcacheSHA1(driver.SHA1)
+        if stamp, ok := getUint(key, "DriverTimeStamp"); ok && stamp != 0 { driver.LinkDate = time.Unix(int64(stamp), 0).UTC() }
+        a.Drivers = append(a.Drivers, driver)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    err = eachAmcacheKey(amcache, AmcacheDevicesPath, func(key libregf.RegistryKey) error {
+        var device AmcacheDevice
+        err := libregf.Unmarshal(key, &device)
+        if err != nil && !decodeError(err) { return err }
+        device.Err = err
+        device.DriverDate = amcacheTime(libregf.GetOr(key, "DriverVerDate", ""))
+        a.Devices = append(a.Devices, device)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    // older hives keep files under a Key per volume, named by its GUID
+    err = eachAmcacheKey(amcache, AmcacheLegacyFilesPath, func(volume libregf.RegistryKey) error {
+        guid, err := volume.Name()
+        if err != nil { return err }
+
+        return eachSubkey(volume, func(name string, key libregf.RegistryKey) error {
+            var legacy amcacheLegacyFile
+            err := libregf.Unmarshal(key, &legacy)
+            if err != nil && !decodeError(err) { return err }
+
+            file := AmcacheFile{
+                Key:         name,
+                SHA1:        amcacheSHA1(legacy.SHA1),
+                Path:        legacy.Path,
+                Name:        legacy.Name,
+                Publisher:   legacy.Publisher,
+                Version:     legacy.Version,
+                ProductName: legacy.ProductName,
+                Size:        legacy.Size,
+                LinkDate:    legacy.LinkDate,
+                ProgramID:   legacy.ProgramID,
+                Volume:      guid,
+                Created:     legacy.Created,
+                Modified:    legacy.Modified,
+                LastWritten: legacy.LastWritten,
+                Err:         err,
+            }
+            // Keys are named after the MFT reference of the file, in hexadecimal
+            if ref, err := strconv.ParseUint(name, 16, 64); err == nil {
+                file.MFTEntry = ref & 0xffffffffffff
+                file.MFTSequence = uint16(ref >> 48)
+            }
+            a.Files = append(a.Files, file)
+            return nil
+        })
+    })
+    if err != nil { return nil, err }
+
+    return a, nil
+}
+
+// eachAmcacheKey calls fn with each sub-Key of the Key by its path inside h.
+// A missing Key calls fn for nothing.
+func eachAmcacheKey(h libregf.Hive, path string, fn func(key libregf.RegistryKey) error) error {
+    key, err := openKey(h, path)
+    if err != nil || key == nil { return err }
+    defer release(key)
+
+    return eachSubkey(key, func(name string, subkey libregf.RegistryKey) error {
+        return fn(subkey)
+    })
+}
+
+// amcacheSHA1 strips the four zeroes Amcache prefixes SHA-1 hashes with.
+func amcacheSHA1(id string) string {
+    if len(id) == 44 && strings.HasPrefix(id, "0000") { return id[4:] }
+    return id
+}
+
+// amcacheTime parses the dates Amcache stores as strings, such as
+// "12/31/2019 23:59:59", giving a zero time.Time for other strings.
+func amcacheTime(s string) time.Time {
+    for _, layout := range []string{"01/02/2006 15:04:05", "01/02/2006", "1-2-2006"} {
+        if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil { return t }
+    }
+    return time.Time{}
+}
+
+// getUint returns the Value of key by its name as an unsigned integer, whether
+// it is a 32-bit or a 64-bit integer.
+func getUint(key libregf.RegistryKey, name string) (uint64, bool) {
+    if u, err := libregf.Get[uint32](key, name); err == nil { return uint64(u), true }
+    if u, err := libregf.Get[uint64](key, name); err == nil { return u, true }
+    return 0, false
+}
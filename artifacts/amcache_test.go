@@ -0,0 +1,51 @@
+package artifacts_test
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// Entries with a Value of an unexpected type are returned with Err set, along
+// with the other entries.
+func TestReadAmcacheBadValues(t *testing.T) {
+    amcache := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Root\\InventoryApplicationFile\\good.exe|1", Values: []regftest.Value{
+            {Name: "FileId", Type: "REG_SZ", String: "0000da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+            {Name: "Name", Type: "REG_SZ", String: "good.exe"},
+            {Name: "Size", Type: "REG_QWORD", Integer: 1024},
+        }},
+        {Path: "Root\\InventoryApplicationFile\\bad.exe|2", Values: []regftest.Value{
+            {Name: "Name", Type: "REG_SZ", String: "bad.exe"},
+            {Name: "Size", Type: "REG_SZ", String: "1024"},
+        }},
+        {Path: "Root\\InventoryApplication\\app", Values: []regftest.Value{{Name: "Name", Type: "REG_DWORD", Integer: 1}}},
+        {Path: "Root\\InventoryDriverBinary\\c:/windows/system32/drivers/x.sys", Values: []regftest.Value{{Name: "DriverSigned", Type: "REG_SZ", String: "yes"}}},
+        {Path: "Root\\InventoryDevicePnp\\usb", Values: []regftest.Value{{Name: "HWID", Type: "REG_DWORD", Integer: 1}}},
+        {Path: "Root\\File\\{volume}\\1000000000001f", Values: []regftest.Value{{Name: "6", Type: "REG_SZ", String: "big"}}},
+    }})
+
+    a, err := artifacts.ReadAmcache(amcache)
+    if err != nil { t.Fatal(err) }
+
+    if len(a.Files) != 3 { t.Fatalf("Files: got %d, want 3", len(a.Files)) }
+    files := map[string]artifacts.AmcacheFile{}
+    for _, f := range a.Files {
+        files[f.Key] = f
+    }
+    if f := files["good.exe|1"]; f.Err != nil || f.SHA1 != "da39a3ee5e6b4b0d3255bfef95601890afd80709" || f.Size != 1024 { t.Errorf("good.exe: got %+v", f) }
+    if f := files["bad.exe|2"]; !isTypeError(f.Err) { t.Errorf("bad.exe: got %v, want an UnmarshalTypeError", f.Err) }
+    if f := files["1000000000001f"]; !isTypeError(f.Err) || f.Volume != "{volume}" || f.MFTEntry != 0x1f || f.MFTSequence != 0x10 { t.Errorf("legacy file: got %+v", f) }
+
+    if len(a.Applications) != 1 || !isTypeError(a.Applications[0].Err) { t.Errorf("Applications: got %+v", a.Applications) }
+    if len(a.Drivers) != 1 || !isTypeError(a.Drivers[0].Err) { t.Errorf("Drivers: got %+v", a.Drivers) }
+    if len(a.Devices) != 1 || !isTypeError(a.Devices[0].Err) { t.Errorf("Devices: got %+v", a.Devices) }
+}
+
+func isTypeError(err error) bool {
+    var typeErr *libregf.UnmarshalTypeError
+    return errors.As(err, &typeErr)
+}
@@ -0,0 +1,242 @@
+package artifacts
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths of the Key holding the AppCompatCache Value, relative to a control set
+// of a SYSTEM hive.
+const (
+    AppCompatCachePath   = "Control\\Session Manager\\AppCompatCache"
+    AppCompatCachePathXP = "Control\\Session Manager\\AppCompatibility" // Windows XP
+)
+
+// AppCompatCacheFormat is the layout of the data of the AppCompatCache Value,
+// which changed with most versions of Windows.
+type AppCompatCacheFormat int
+
+const (
+    AppCompatCacheUnknown AppCompatCacheFormat = iota
+    AppCompatCacheXP                           // Windows XP 32-bit
+    AppCompatCacheVista                        // Windows Server 2003 and Vista, and XP 64-bit
+    AppCompatCache7                            // Windows 7 and Server 2008 R2
+    AppCompatCache8                            // Windows 8 and Server 2012
+    AppCompatCache81                           // Windows 8.1 and Server 2012 R2
+    AppCompatCache10                           // Windows 10, 11 and Server 2016 and later
+)
+
+var appCompatCacheFormatNames = []string{"unknown", "xp", "vista", "7", "8", "8.1", "10"}
+
+// String returns the name of the format.
+func (format AppCompatCacheFormat) String() string {
+    if format < 0 || int(format) >= len(appCompatCacheFormatNames) { return "unknown" }
+    return appCompatCacheFormatNames[format]
+}
+
+// Signatures and sizes of the AppCompatCache formats.
+const (
+    appCompatCacheXPSignature    = 0xdeadbeef
+    appCompatCacheVistaSignature = 0xbadc0ffe
+    appCompatCache7Signature     = 0xbadc0fee
+    appCompatCache8HeaderSize    = 128
+    appCompatCacheXPHeaderSize   = 400
+    appCompatCacheXPEntrySize    = 552
+    appCompatCacheXPMaxEntries   = 96
+    appCompatCacheXPPathSize     = 520
+    appCompatCache7HeaderSize    = 128
+
+    // insertion flag set once the file was executed
+    appCompatCacheExecuted = 0x00000002
+)
+
+// AppCompatCache is the content of the AppCompatCache, or ShimCache, of a
+// SYSTEM hive: files that Windows checked for compatibility issues, which
+// mostly happens as they are executed or browsed.
+type AppCompatCache struct {
+    ControlSet  string                // control set the cache was read from, such as "ControlSet001"
+    Format      AppCompatCacheFormat  // layout of the cache
+    Entries     []AppCompatCacheEntry // files of the cache, most recent first
+    LastWritten time.Time             // last written time of the Key holding the cache
+}
+
+// AppCompatCacheEntry is a file of the AppCompatCache. Fields that the format
+// of the cache doesn't record are left zero.
+type AppCompatCacheEntry struct {
+    Position int       // position in the cache, 0 being the most recently inserted file
+    Path     string    // path of the file, as stored
+    Modified time.Time // last modification time of the file, as recorded in its file system
+    Updated  time.Time // last update time of the entry, Windows XP only
+    Size     uint64    // size of the file, Windows XP only
+    Flags    uint32    // insertion flags, Vista to 8.1 only; Windows Server 2003 stores the size of the file there
+    Executed bool      // whether the file was executed, from Flags
+    Package  string    // package of modern applications, Windows 8.1 only
+}
+
+// ReadAppCompatCache reads the AppCompatCache of the current control set of a
+// SYSTEM hive. A hive without one gives an AppCompatCache without entries.
+func ReadAppCompatCache(system libregf.Hive) (*AppCompatCache, error) {
+    ccs, err := currentControlSet(system)
+    if err != nil { return nil, err }
+    cache := &AppCompatCache{ControlSet: ccs, Entries: []AppCompatCacheEntry{}}
+    if ccs == "" { return cache, nil }
+
+    for _, path := range []string{AppCompatCachePath, AppCompatCachePathXP} {
+        key, err := openKey(system, ccs+"\\"+path)
+        if err != nil { return nil, err }
+        if key == nil { continue }
+
+        data, err := getBytes(key, "AppCompatCache")
+        if err == nil { cache.LastWritten, err = lastWritten(key) }
+        release(key)
+        if errors.Is(err, libregf.ErrNotFound) { continue }
+        if err != nil { return nil, err }
+
+        cache.Format, cache.Entries, err = ParseAppCompatCache(data)
+        return cache, err
+    }
+
+    return cache, nil
+}
+
+// ParseAppCompatCache decodes the data of an AppCompatCache Value, whatever
+// its format.
+func ParseAppCompatCache(data []byte) (AppCompatCacheFormat, []AppCompatCacheEntry, error) {
+    if len(data) < 8 { return AppCompatCacheUnknown, nil, fmt.Errorf("artifacts: AppCompatCache too short") }
+
+    signature := le.Uint32(data)
+    switch {
+    case signature == appCompatCacheXPSignature:
+        entries, err := parseAppCompatCacheXP(data)
+        return AppCompatCacheXP, entries, err
+    case signature == appCompatCacheVistaSignature:
+        return AppCompatCacheVista, parseAppCompatCache7(data, false), nil
+    case signature == appCompatCache7Signature:
+        return AppCompatCache7, parseAppCompatCache7(data, true), nil
+    case signature == appCompatCache8HeaderSize && hasSignatureAt(data, appCompatCache8HeaderSize, "00ts"):
+        return AppCompatCache8, parseAppCompatCache8(data, appCompatCache8HeaderSize, AppCompatCache8), nil
+    case signature == appCompatCache8HeaderSize && hasSignatureAt(data, appCompatCache8HeaderSize, "10ts"):
+        return AppCompatCache81, parseAppCompatCache8(data, appCompatCache8HeaderSize, AppCompatCache81), nil
+    case (signature == 0x30 || signature == 0x34) && hasSignatureAt(data, int(signature), "10ts"):
+        return AppCompatCache10, parseAppCompatCache8(data, int(signature), AppCompatCache10), nil
+    }
+
+    return AppCompatCacheUnknown, nil, fmt.Errorf("artifacts: unknown AppCompatCache signature 0x%08x", signature)
+}
+
+// hasSignatureAt tells whether data holds signature at offset.
+func hasSignatureAt(data []byte, offset int, signature string) bool {
+    return offset+len(signature) <= len(data) && string(data[offset:offset+len(signature)]) == signature
+}
+
+// parseAppCompatCacheXP decodes the fixed size entries of Windows XP, ordered
+// by the LRU list of the header.
+func parseAppCompatCacheXP(data []byte) ([]AppCompatCacheEntry, error) {
+    if len(data) < appCompatCacheXPHeaderSize { return nil, fmt.Errorf("artifacts: AppCompatCache too short") }
+
+    n := int(le.Uint32(data[4:]))
+    if n > appCompatCacheXPMaxEntries { n = appCompatCacheXPMaxEntries }
+
+    entries := []AppCompatCacheEntry{}
+    for i := 0; i < n; i++ {
+        index := int(le.Uint32(data[8+i*4:]))
+        offset := appCompatCacheXPHeaderSize + index*appCompatCacheXPEntrySize
+        if index < 0 || offset+appCompatCacheXPEntrySize > len(data) { continue }
+
+        e := data[offset : offset+appCompatCacheXPEntrySize]
+        entries = append(entries, AppCompatCacheEntry{
+            Position: i,
+            Path:     decodeUTF16(e[:appCompatCacheXPPathSize]),
+            Modified: filetimeAt(e, 528),
+            Size:     le.Uint64(e[536:]),
+            Updated:  filetimeAt(e, 544),
+        })
+    }
+
+    return entries, nil
+}
+
+// parseAppCompatCache7 decodes the entries of Windows Server 2003, Vista and,
+// when win7 is true, Windows 7, whose paths are stored after the entries.
+// 32-bit and 64-bit systems have entries of different sizes, told apart by the
+// padding 64-bit entries have after the size of their path.
+func parseAppCompatCache7(data []byte, win7 bool) []AppCompatCacheEntry {
+    header := 8
+    if win7 { header = appCompatCache7HeaderSize }
+    if len(data) < header+8 { return []AppCompatCacheEntry{} }
+
+    n := int(le.Uint32(data[4:]))
+    x64 := le.Uint32(data[header+4:]) == 0
+
+    entries := []AppCompatCacheEntry{}
+    f := &fields{data: data, off: header}
+    for i := 0; i < n; i++ {
+        var entry AppCompatCacheEntry
+        var pathOffset uint64
+
+        size := int(f.uint16())
+        f.uint16() // maximum size of the path
+        if x64 {
+            f.uint32()
+            pathOffset = f.uint64()
+        } else {
+            pathOffset = uint64(f.uint32())
+        }
+        entry.Modified = libregf.FiletimeToTime(f.uint64())
+        entry.Flags = f.uint32()
+        f.uint32() // shim flags
+        if win7 {
+            // size and offset of data of its own
+            if x64 {
+                f.uint64()
+                f.uint64()
+            } else {
+                f.uint32()
+                f.uint32()
+            }
+        }
+        if f.short { break }
+
+        if pathOffset+uint64(size) <= uint64(len(data)) {
+            entry.Path = decodeUTF16(data[pathOffset : pathOffset+uint64(size)])
+        }
+        entry.Position = i
+        entry.Executed = entry.Flags&appCompatCacheExecuted != 0
+        entries = append(entries, entry)
+    }
+
+    return entries
+}
+
+// parseAppCompatCache8 decodes the entries of Windows 8 and later, which start
+// after a header of the given size. Each entry has a signature and its size.
+func parseAppCompatCache8(data []byte, header int, format AppCompatCacheFormat) []AppCompatCacheEntry {
+    entries := []AppCompatCacheEntry{}
+
+    for offset := header; offset+12 <= len(data); {
+        if !hasSignatureAt(data, offset, "00ts") && !hasSignatureAt(data, offset, "10ts") { break }
+        size := int(le.Uint32(data[offset+8:]))
+        if size < 0 || offset+12+size > len(data) { break }
+
+        var entry AppCompatCacheEntry
+        f := &fields{data: data[offset+12 : offset+12+size]}
+        entry.Path = f.utf16(int(f.uint16()))
+        if format == AppCompatCache81 { entry.Package = f.utf16(int(f.uint16())) }
+        if format != AppCompatCache10 {
+            entry.Flags = f.uint32()
+            f.uint32() // shim flags
+            entry.Executed = entry.Flags&appCompatCacheExecuted != 0
+        }
+        entry.Modified = libregf.FiletimeToTime(f.uint64())
+        if f.short { break }
+
+        entry.Position = len(entries)
+        entries = append(entries, entry)
+        offset += 12 + size
+    }
+
+    return entries
+}
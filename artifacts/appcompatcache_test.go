@@ -0,0 +1,178 @@
+package artifacts_test
+
+import (
+    "bytes"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// utf16n encodes s in UTF-16LE, without a NUL.
+func utf16n(s string) []byte {
+    b := utf16z(s)
+    return b[:len(b)-2]
+}
+
+type appCompatFile struct {
+    path     string
+    modified time.Time
+    flags    uint32
+}
+
+// appCompatCacheXP builds a Windows XP cache, whose LRU list puts the entries
+// in the reverse order of their slots.
+func appCompatCacheXP(files []appCompatFile, size uint64, updated time.Time) []byte {
+    header := make([]byte, 400)
+    le.PutUint32(header[0:], 0xdeadbeef)
+    le.PutUint32(header[4:], uint32(len(files)))
+    for i := range files {
+        le.PutUint32(header[8+4*i:], uint32(len(files)-1-i))
+    }
+    data := header
+    for i := range files {
+        f := files[len(files)-1-i]
+        e := make([]byte, 552)
+        copy(e, utf16z(f.path))
+        le.PutUint64(e[528:], filetime(f.modified))
+        le.PutUint64(e[536:], size)
+        le.PutUint64(e[544:], filetime(updated))
+        data = append(data, e...)
+    }
+    return data
+}
+
+// appCompatCache7 builds a Windows Server 2003/Vista cache or, when win7 is
+// true, a Windows 7 one, of 32-bit or 64-bit layout, with paths after the entries.
+func appCompatCache7(files []appCompatFile, win7, x64 bool) []byte {
+    signature, header := uint32(0xbadc0ffe), 8
+    if win7 { signature, header = 0xbadc0fee, 128 }
+    entrySize := 24
+    switch {
+    case win7 && x64:
+        entrySize = 48
+    case win7:
+        entrySize = 32
+    case x64:
+        entrySize = 32
+    }
+
+    data := make([]byte, header)
+    le.PutUint32(data[0:], signature)
+    le.PutUint32(data[4:], uint32(len(files)))
+    paths := []byte{}
+    pathsOffset := header + entrySize*len(files)
+    for _, f := range files {
+        path := utf16n(f.path)
+        e := le.AppendUint16(nil, uint16(len(path)))
+        e = le.AppendUint16(e, uint16(len(path)+2))
+        if x64 {
+            e = le.AppendUint32(e, 0)
+            e = le.AppendUint64(e, uint64(pathsOffset+len(paths)))
+        } else {
+            e = le.AppendUint32(e, uint32(pathsOffset+len(paths)))
+        }
+        e = le.AppendUint64(e, filetime(f.modified))
+        e = le.AppendUint32(e, f.flags)
+        e = le.AppendUint32(e, 0)
+        e = append(e, make([]byte, entrySize-len(e))...)
+        data = append(data, e...)
+        paths = append(paths, utf16z(f.path)...)
+    }
+    return append(data, paths...)
+}
+
+// appCompatCache8 builds a cache of Windows 8, 8.1 or 10, with the header size
+// and entry signature of each.
+func appCompatCache8(files []appCompatFile, format artifacts.AppCompatCacheFormat, header int, pkg string) []byte {
+    data := make([]byte, header)
+    le.PutUint32(data, uint32(header))
+    signature := "10ts"
+    if format == artifacts.AppCompatCache8 { signature = "00ts" }
+    for _, f := range files {
+        path := utf16n(f.path)
+        body := append(le.AppendUint16(nil, uint16(len(path))), path...)
+        if format == artifacts.AppCompatCache81 {
+            body = le.AppendUint16(body, uint16(len(utf16n(pkg))))
+            body = append(body, utf16n(pkg)...)
+        }
+        if format != artifacts.AppCompatCache10 {
+            body = le.AppendUint32(body, f.flags)
+            body = le.AppendUint32(body, 0)
+        }
+        body = le.AppendUint64(body, filetime(f.modified))
+        body = le.AppendUint32(body, 4)
+        body = append(body, 1, 2, 3, 4)
+
+        data = append(data, signature...)
+        data = le.AppendUint32(data, 0x1234)
+        data = le.AppendUint32(data, uint32(len(body)))
+        data = append(data, body...)
+    }
+    return data
+}
+
+func TestParseAppCompatCache(t *testing.T) {
+    files := []appCompatFile{
+        {"C:\\Windows\\System32\\cmd.exe", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), 0x00000002},
+        {"\\??\\C:\\Users\\alice\\Downloads\\setup.exe", time.Date(2022, 6, 7, 8, 9, 10, 0, time.UTC), 0},
+        {"C:\\Program Files\\App\\app.exe", time.Date(2023, 11, 12, 13, 14, 15, 0, time.UTC), 0x00000003},
+    }
+    updated := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+
+    for _, test := range []struct {
+        name   string
+        data   []byte
+        format artifacts.AppCompatCacheFormat
+        flags  bool // whether the format records flags
+    }{
+        {"xp", appCompatCacheXP(files, 4096, updated), artifacts.AppCompatCacheXP, false},
+        {"vista 32-bit", appCompatCache7(files, false, false), artifacts.AppCompatCacheVista, true},
+        {"vista 64-bit", appCompatCache7(files, false, true), artifacts.AppCompatCacheVista, true},
+        {"7 32-bit", appCompatCache7(files, true, false), artifacts.AppCompatCache7, true},
+        {"7 64-bit", appCompatCache7(files, true, true), artifacts.AppCompatCache7, true},
+        {"8", appCompatCache8(files, artifacts.AppCompatCache8, 128, ""), artifacts.AppCompatCache8, true},
+        {"8.1", appCompatCache8(files, artifacts.AppCompatCache81, 128, "Microsoft.App_8wekyb3d8bbwe"), artifacts.AppCompatCache81, true},
+        {"10", appCompatCache8(files, artifacts.AppCompatCache10, 0x30, ""), artifacts.AppCompatCache10, false},
+        {"10 creators update", appCompatCache8(files, artifacts.AppCompatCache10, 0x34, ""), artifacts.AppCompatCache10, false},
+    } {
+        format, entries, err := artifacts.ParseAppCompatCache(test.data)
+        if err != nil { t.Errorf("%s: %v", test.name, err); continue }
+        if format != test.format { t.Errorf("%s: got format %v", test.name, format) }
+        if len(entries) != len(files) { t.Errorf("%s: got %d entries, want %d", test.name, len(entries), len(files)); continue }
+
+        for i, e := range entries {
+            f := files[i]
+            if e.Position != i || e.Path != f.path || !e.Modified.Equal(f.modified) { t.Errorf("%s: entry %d: got %d %q %v", test.name, i, e.Position, e.Path, e.Modified) }
+            if test.flags && (e.Flags != f.flags || e.Executed != (f.flags&2 != 0)) { t.Errorf("%s: entry %d: got flags %#x, executed %v", test.name, i, e.Flags, e.Executed) }
+            if !test.flags && (e.Flags != 0 || e.Executed) { t.Errorf("%s: entry %d: got flags %#x, executed %v, from a format without them", test.name, i, e.Flags, e.Executed) }
+            if format == artifacts.AppCompatCacheXP && (e.Size != 4096 || !e.Updated.Equal(updated)) { t.Errorf("%s: entry %d: got size %d, updated %v", test.name, i, e.Size, e.Updated) }
+            if format == artifacts.AppCompatCache81 && e.Package != "Microsoft.App_8wekyb3d8bbwe" { t.Errorf("%s: entry %d: got package %q", test.name, i, e.Package) }
+        }
+    }
+
+    for name, data := range map[string][]byte{
+        "short":             {1, 2, 3},
+        "unknown signature": bytes.Repeat([]byte{0x42}, 64),
+        "truncated xp":      appCompatCacheXP(files, 0, updated)[:100],
+    } {
+        if _, _, err := artifacts.ParseAppCompatCache(data); err == nil { t.Errorf("%s: no error", name) }
+    }
+}
+
+func TestReadAppCompatCache(t *testing.T) {
+    files := []appCompatFile{{"C:\\Windows\\notepad.exe", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), 0}}
+    lw := time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 1}}},
+        {Path: "ControlSet001\\" + artifacts.AppCompatCachePath, LastWritten: lw, Values: []regftest.Value{
+            {Name: "AppCompatCache", Type: "REG_BINARY", Data: appCompatCache8(files, artifacts.AppCompatCache10, 0x34, "")},
+        }},
+    }})
+
+    cache, err := artifacts.ReadAppCompatCache(system)
+    if err != nil { t.Fatal(err) }
+    if cache.ControlSet != "ControlSet001" || cache.Format != artifacts.AppCompatCache10 || !cache.LastWritten.Equal(lw) { t.Errorf("got %+v", cache) }
+    if len(cache.Entries) != 1 || cache.Entries[0].Path != "C:\\Windows\\notepad.exe" { t.Errorf("got entries %+v", cache.Entries) }
+}
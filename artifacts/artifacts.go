@@ -0,0 +1,183 @@
+// Package artifacts decodes the forensic artifacts that Windows keeps in its
+// registry hives, such as UserAssist, into Go structs.
+//
+// Parsers work on any libregf.Hive: a *libregf.File, but also mocks and other
+// registry sources. They take the hives they need as arguments, named after
+// the files they come from (SYSTEM, SOFTWARE, NTUSER.DAT, ...). Artifacts
+// whose Keys are missing give no entries rather than an error.
+package artifacts
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "time"
+    "unicode/utf16"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+var le = binary.LittleEndian
+
+// filetimeAt decodes the FILETIME at offset in data, giving a zero time.Time
+// when data is too short.
+func filetimeAt(data []byte, offset int) time.Time {
+    if offset+8 > len(data) { return time.Time{} }
+    return libregf.FiletimeToTime(le.Uint64(data[offset:]))
+}
+
+// release frees a RegistryKey or RegistryValue whose implementation holds on to
+// memory that the garbage collector doesn't know about, as the libregf backend does.
+func release(x interface{}) {
+    if f, ok := x.(interface{ Free() error }); ok { f.Free() }
+}
+
+// decodeError tells whether err, as returned by libregf.Unmarshal, comes from a
+// Value that doesn't fit its field, as found in hives written by another version
+// of Windows or tampered with, rather than from reading the hive. Such errors
+// are recorded in the entry being decoded instead of failing the artifact.
+func decodeError(err error) bool {
+    var typeErr *libregf.UnmarshalTypeError
+    return errors.As(err, &typeErr)
+}
+
+// lastWritten returns the last written time of key, or a zero time.Time for
+// RegistryKeys that don't know it.
+func lastWritten(key libregf.RegistryKey) (time.Time, error) {
+    kt, ok := key.(interface{ LastWritten() (time.Time, error) })
+    if !ok { return time.Time{}, nil }
+    return kt.LastWritten()
+}
+
+// openKey returns the Key by its path inside h, or nil when there is no such Key.
+func openKey(h libregf.Hive, path string) (libregf.RegistryKey, error) {
+    key, err := h.GetKey(path)
+    if errors.Is(err, libregf.ErrNotFound) { return nil, nil }
+    if err != nil { return nil, err }
+    return key, nil
+}
+
+// currentControlSet returns the path of the control set in use in a SYSTEM
+// hive, or "" when the hive has no Select\Current Value to tell which one it is.
+func currentControlSet(system libregf.Hive) (string, error) {
+    ccs, err := libregf.CurrentControlSet(system)
+    if errors.Is(err, libregf.ErrNotFound) { return "", nil }
+    return ccs, err
+}
+
+// eachSubkey calls fn with the name of each sub-Key of key and the sub-Key
+// itself, which is released once fn returns.
+func eachSubkey(key libregf.RegistryKey, fn func(name string, subkey libregf.RegistryKey) error) error {
+    n, err := key.SubkeysLen()
+    if err != nil { return err }
+
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return err }
+        name, err := subkey.Name()
+        if err == nil { err = fn(name, subkey) }
+        release(subkey)
+        if err != nil { return err }
+    }
+
+    return nil
+}
+
+// eachValue calls fn with the name of each Value of key and the Value itself,
+// which is released once fn returns.
+func eachValue(key libregf.RegistryKey, fn func(name string, value libregf.RegistryValue) error) error {
+    n, err := key.ValuesLen()
+    if err != nil { return err }
+
+    for i := 0; i < n; i++ {
+        value, err := key.GetValueAt(i)
+        if err != nil { return err }
+        name, err := value.Name()
+        if err == nil { err = fn(name, value) }
+        release(value)
+        if err != nil { return err }
+    }
+
+    return nil
+}
+
+// guidAt formats the GUID at offset in data the way Windows does, upper cased
+// and between braces, giving "" when data is too short.
+func guidAt(data []byte, offset int) string {
+    if offset+16 > len(data) { return "" }
+    b := data[offset : offset+16]
+    return fmt.Sprintf("{%08X-%04X-%04X-%X-%X}", le.Uint32(b[0:]), le.Uint16(b[4:]), le.Uint16(b[6:]), b[8:10], b[10:16])
+}
+
+// dosTime converts an MS-DOS date and time, as found in shell items, to a
+// time.Time. Such times have a 2 seconds resolution and no time zone; they are
+// returned in UTC. A zero date gives a zero time.Time.
+func dosTime(date, t uint16) time.Time {
+    if date == 0 { return time.Time{} }
+    return time.Date(1980+int(date>>9), time.Month(date>>5&0x0f), int(date&0x1f), int(t>>11), int(t>>5&0x3f), int(t&0x1f)*2, 0, time.UTC)
+}
+
+// utf16At decodes the NUL terminated UTF-16LE string at offset in data,
+// returning it along with the offset past its NUL.
+func utf16At(data []byte, offset int) (string, int) {
+    units := []uint16{}
+    for offset+1 < len(data) {
+        c := le.Uint16(data[offset:])
+        offset += 2
+        if c == 0 { break }
+        units = append(units, c)
+    }
+    return string(utf16.Decode(units)), offset
+}
+
+// asciiAt decodes the NUL terminated narrow string at offset in data, returning
+// it along with the offset past its NUL. Bytes above 0x7f are taken as ISO 8859-1.
+func asciiAt(data []byte, offset int) (string, int) {
+    runes := []rune{}
+    for offset < len(data) {
+        c := data[offset]
+        offset++
+        if c == 0 { break }
+        runes = append(runes, rune(c))
+    }
+    return string(runes), offset
+}
+
+// fields reads consecutive little endian fields from data. Reading past the
+// end of data gives zeroes and sets short, so that the fields of a record can
+// be read first and checked once.
+type fields struct {
+    data  []byte
+    off   int
+    short bool
+}
+
+// next returns the next n bytes of data.
+func (f *fields) next(n int) []byte {
+    if n < 0 {
+        f.short = true
+        return nil
+    }
+    if f.short || f.off+n > len(f.data) {
+        f.short = true
+        return make([]byte, n)
+    }
+    b := f.data[f.off : f.off+n]
+    f.off += n
+    return b
+}
+
+func (f *fields) uint16() uint16 { return le.Uint16(f.next(2)) }
+func (f *fields) uint32() uint32 { return le.Uint32(f.next(4)) }
+func (f *fields) uint64() uint64 { return le.Uint64(f.next(8)) }
+
+// utf16 returns the next size bytes of data as a UTF-16LE string.
+func (f *fields) utf16(size int) string {
+    return decodeUTF16(f.next(size))
+}
+
+// decodeUTF16 decodes UTF-16LE data, stopping at the first NUL.
+func decodeUTF16(data []byte) string {
+    s, _ := utf16At(data, 0)
+    return s
+}
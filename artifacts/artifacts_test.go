@@ -0,0 +1,39 @@
+package artifacts_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// A SYSTEM hive without Select\Current, as found in some images and exports,
+// gives no entries rather than an error.
+func TestNoControlSet(t *testing.T) {
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "ControlSet001\\Services\\Svc", Values: []regftest.Value{{Name: "ImagePath", Type: "REG_EXPAND_SZ", String: "C:\\svc.exe"}}},
+        {Path: "MountedDevices"},
+    }})
+
+    cache, err := artifacts.ReadAppCompatCache(system)
+    if err != nil || len(cache.Entries) != 0 { t.Errorf("ReadAppCompatCache: got %+v, %v", cache, err) }
+    if entries, err := artifacts.BAM(system, nil); err != nil || len(entries) != 0 { t.Errorf("BAM: got %+v, %v", entries, err) }
+    if devices, err := artifacts.USBDevices(system, nil, nil); err != nil || len(devices) != 0 { t.Errorf("USBDevices: got %+v, %v", devices, err) }
+    if services, err := artifacts.Services(system, nil); err != nil || len(services) != 0 { t.Errorf("Services: got %+v, %v", services, err) }
+    if autoruns, err := artifacts.AutoRuns(artifacts.Hives{System: system}, nil); err != nil || len(autoruns) != 0 { t.Errorf("AutoRuns: got %+v, %v", autoruns, err) }
+}
+
+func TestControlSet(t *testing.T) {
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 2}}},
+        {Path: "ControlSet001\\Services\\Old"},
+        {Path: "ControlSet002\\Services\\Svc", Values: []regftest.Value{{Name: "ImagePath", Type: "REG_EXPAND_SZ", String: "C:\\svc.exe"}}},
+    }})
+
+    services, err := artifacts.Services(system, nil)
+    if err != nil { t.Fatal(err) }
+    if len(services) != 1 || services[0].ImagePath != "C:\\svc.exe" { t.Errorf("Services: got %+v, want the service of ControlSet002", services) }
+
+    cache, err := artifacts.ReadAppCompatCache(system)
+    if err != nil || cache.ControlSet != "ControlSet002" { t.Errorf("ReadAppCompatCache: got %+v, %v", cache, err) }
+}
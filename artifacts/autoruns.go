@@ -0,0 +1,433 @@
+package artifacts
+
+import (
+    "errors"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// AutoRunCategory is the kind of autostart extensibility point (ASEP) of an
+// AutoRun, named the way the Autoruns tool of Sysinternals groups them.
+type AutoRunCategory string
+
+const (
+    AutoRunLogon         AutoRunCategory = "Logon"             // Run, RunOnce and Load
+    AutoRunWinlogon      AutoRunCategory = "Winlogon"          // Shell, Userinit and the Notify packages
+    AutoRunImageHijack   AutoRunCategory = "Image Hijacks"     // Image File Execution Options debuggers and SilentProcessExit monitors
+    AutoRunAppInit       AutoRunCategory = "AppInit"           // AppInit_DLLs
+    AutoRunActiveSetup   AutoRunCategory = "Active Setup"      // StubPath of the installed components
+    AutoRunExplorer      AutoRunCategory = "Explorer"          // shell extensions, icon overlays and delay loaded shell objects
+    AutoRunIE            AutoRunCategory = "Internet Explorer" // Browser Helper Objects
+    AutoRunCOMHijack     AutoRunCategory = "COM Hijacks"       // COM classes registered by a user, which take precedence over those of the system
+    AutoRunPrintMonitor  AutoRunCategory = "Print Monitors"
+    AutoRunLSA           AutoRunCategory = "LSA Providers"     // authentication, notification and security packages
+    AutoRunNetsh         AutoRunCategory = "Netsh"             // helper DLLs
+    AutoRunScheduledTask AutoRunCategory = "Scheduled Tasks"   // actions of the tasks of the Task Scheduler cache
+)
+
+// Hives are the hives of a system that AutoRuns reads, any of which may be nil.
+type Hives struct {
+    System   libregf.Hive
+    Software libregf.Hive
+    Users    map[string]UserHives // hives of the users, by user name
+}
+
+// UserHives are the hives of a user, either of which may be nil.
+type UserHives struct {
+    NTUser   libregf.Hive
+    UsrClass libregf.Hive
+}
+
+// AutoRun is something that Windows starts by itself, found at an autostart
+// extensibility point (ASEP).
+type AutoRun struct {
+    Category    AutoRunCategory
+    Hive        string    // file of the hive of the entry: "SYSTEM", "SOFTWARE", "NTUSER.DAT" or "UsrClass.dat"
+    User        string    // user whose hive holds the entry, "" for the hives of the system
+    Key         string    // path of the Key of the entry inside its hive
+    Name        string    // name of the Value, or of the Key, of the entry
+    Command     string    // command line, executable or DLL that is started
+    LastWritten time.Time // last written time of the Key of the entry
+}
+
+// Paths of the Keys read by AutoRuns, relative to a SOFTWARE hive, or to the
+// Software Key of an NTUSER.DAT hive for those of the CurrentVersion Keys.
+const (
+    currentVersionPath   = "Microsoft\\Windows\\CurrentVersion"
+    currentVersionNTPath = "Microsoft\\Windows NT\\CurrentVersion"
+    wow64Path            = "Wow6432Node\\"
+)
+
+// AutoRuns collects the programs, DLLs and COM objects that Windows starts by
+// itself from the hives of a system and of its users: Run and RunOnce Keys,
+// Winlogon, Image File Execution Options, AppInit_DLLs, Active Setup, shell
+// extensions, Browser Helper Objects, COM hijacks, print monitors, LSA
+// packages, Netsh helpers and scheduled tasks.
+//
+// COM objects are resolved to their server through the SOFTWARE hive. Commands
+// are expanded by env unless env is nil; see libregf.SystemEnvironment.
+func AutoRuns(hives Hives, env *libregf.Environment) ([]AutoRun, error) {
+    c := &autoRunCollector{entries: []AutoRun{}, software: hives.Software, env: env}
+
+    if hives.System != nil {
+        if err := c.system(autoRunSource{h: hives.System, hive: "SYSTEM"}); err != nil { return nil, err }
+    }
+    if hives.Software != nil {
+        if err := c.machine(autoRunSource{h: hives.Software, hive: "SOFTWARE"}); err != nil { return nil, err }
+    }
+
+    users := make([]string, 0, len(hives.Users))
+    for user := range hives.Users {
+        users = append(users, user)
+    }
+    sort.Strings(users)
+    for _, user := range users {
+        if h := hives.Users[user].NTUser; h != nil {
+            if err := c.user(autoRunSource{h: h, hive: "NTUSER.DAT", user: user}); err != nil { return nil, err }
+        }
+        if h := hives.Users[user].UsrClass; h != nil {
+            if err := c.classes(autoRunSource{h: h, hive: "UsrClass.dat", user: user}, "CLSID"); err != nil { return nil, err }
+        }
+    }
+
+    return c.entries, nil
+}
+
+// autoRunSource is a hive read by AutoRuns.
+type autoRunSource struct {
+    h    libregf.Hive
+    hive string
+    user string
+}
+
+// autoRunCollector gathers the AutoRuns of the hives it reads.
+type autoRunCollector struct {
+    entries  []AutoRun
+    software libregf.Hive
+    env      *libregf.Environment
+}
+
+// valueCommands returns the commands of a Value of an ASEP Key, if any.
+type valueCommands func(name string, value libregf.RegistryValue) ([]string, error)
+
+// keyCommand returns the command of a sub-Key of an ASEP Key, if any.
+type keyCommand func(name string, key libregf.RegistryKey) (string, error)
+
+// system reads the ASEPs of a SYSTEM hive.
+func (c *autoRunCollector) system(src autoRunSource) error {
+    ccs, err := currentControlSet(src.h)
+    if err != nil || ccs == "" { return err }
+
+    err = c.subkeys(src, AutoRunPrintMonitor, ccs+"\\Control\\Print\\Monitors", valueOf("Driver"))
+    if err != nil { return err }
+
+    for _, lsa := range []struct{ path, name string }{
+        {"Control\\Lsa", "Authentication Packages"},
+        {"Control\\Lsa", "Notification Packages"},
+        {"Control\\Lsa", "Security Packages"},
+        {"Control\\Lsa\\OSConfig", "Security Packages"},
+        {"Control\\SecurityProviders", "SecurityProviders"},
+    } {
+        if err := c.values(src, AutoRunLSA, ccs+"\\"+lsa.path, stringValues(lsa.name)); err != nil { return err }
+    }
+
+    return nil
+}
+
+// machine reads the ASEPs of a SOFTWARE hive.
+func (c *autoRunCollector) machine(src autoRunSource) error {
+    for _, prefix := range []string{"", wow64Path} {
+        if err := c.run(src, prefix); err != nil { return err }
+
+        err := c.subkeys(src, AutoRunImageHijack, prefix+currentVersionNTPath+"\\Image File Execution Options", valueOf("Debugger"))
+        if err != nil { return err }
+        err = c.values(src, AutoRunAppInit, prefix+currentVersionNTPath+"\\Windows", stringValues("AppInit_DLLs"))
+        if err != nil { return err }
+        err = c.subkeys(src, AutoRunActiveSetup, prefix+"Microsoft\\Active Setup\\Installed Components", valueOf("StubPath"))
+        if err != nil { return err }
+        err = c.subkeys(src, AutoRunIE, prefix+currentVersionPath+"\\Explorer\\Browser Helper Objects", func(clsid string, _ libregf.RegistryKey) (string, error) {
+            return c.resolveCLSID(clsid)
+        })
+        if err != nil { return err }
+        if err := c.explorer(src, prefix+currentVersionPath); err != nil { return err }
+    }
+
+    err := c.subkeys(src, AutoRunImageHijack, currentVersionNTPath+"\\SilentProcessExit", valueOf("MonitorProcess"))
+    if err != nil { return err }
+    err = c.values(src, AutoRunWinlogon, currentVersionNTPath+"\\Winlogon", stringValues("Shell", "Userinit", "Taskman", "AppSetup"))
+    if err != nil { return err }
+    err = c.subkeys(src, AutoRunWinlogon, currentVersionNTPath+"\\Winlogon\\Notify", valueOf("DllName"))
+    if err != nil { return err }
+    err = c.values(src, AutoRunNetsh, "Microsoft\\NetSh", stringValues())
+    if err != nil { return err }
+
+    return c.scheduledTasks(src)
+}
+
+// user reads the ASEPs of an NTUSER.DAT hive.
+func (c *autoRunCollector) user(src autoRunSource) error {
+    if err := c.run(src, "Software\\"); err != nil { return err }
+
+    err := c.values(src, AutoRunLogon, "Software\\"+currentVersionNTPath+"\\Windows", stringValues("Load", "Run"))
+    if err != nil { return err }
+    err = c.values(src, AutoRunWinlogon, "Software\\"+currentVersionNTPath+"\\Winlogon", stringValues("Shell"))
+    if err != nil { return err }
+    if err := c.explorer(src, "Software\\"+currentVersionPath); err != nil { return err }
+
+    // the classes of a user are in their UsrClass.dat hive since Windows Vista
+    return c.classes(src, "Software\\Classes\\CLSID")
+}
+
+// run reads the Run Keys of a SOFTWARE or NTUSER.DAT hive, their path starting
+// with prefix.
+func (c *autoRunCollector) run(src autoRunSource, prefix string) error {
+    for _, path := range []string{"Run", "RunOnce", "Policies\\Explorer\\Run"} {
+        if err := c.values(src, AutoRunLogon, prefix+currentVersionPath+"\\"+path, stringValues()); err != nil { return err }
+    }
+    return nil
+}
+
+// explorer reads the shell extensions registered under the CurrentVersion Key
+// at path.
+func (c *autoRunCollector) explorer(src autoRunSource, path string) error {
+    // Values named after the CLSID of the extension
+    err := c.values(src, AutoRunExplorer, path+"\\Shell Extensions\\Approved", func(clsid string, _ libregf.RegistryValue) ([]string, error) {
+        return c.resolveCLSIDs(clsid)
+    })
+    if err != nil { return err }
+
+    // Values holding the CLSID of the object
+    err = c.values(src, AutoRunExplorer, path+"\\ShellServiceObjectDelayLoad", func(_ string, value libregf.RegistryValue) ([]string, error) {
+        clsid, err := value.TString()
+        if err != nil { return nil, nil }
+        return c.resolveCLSIDs(clsid)
+    })
+    if err != nil { return err }
+
+    // Keys whose default Value holds the CLSID of the overlay
+    return c.subkeys(src, AutoRunExplorer, path+"\\Explorer\\ShellIconOverlayIdentifiers", func(_ string, key libregf.RegistryKey) (string, error) {
+        return c.resolveCLSID(libregf.GetOr(key, "", ""))
+    })
+}
+
+// classes reads the COM classes registered in the CLSID Key at path of a user
+// hive, which take precedence over the classes of the system.
+func (c *autoRunCollector) classes(src autoRunSource, path string) error {
+    return c.subkeys(src, AutoRunCOMHijack, path, func(_ string, key libregf.RegistryKey) (string, error) {
+        return comServer(key)
+    })
+}
+
+// scheduledTasks reads the actions of the tasks of the Task Scheduler cache of
+// a SOFTWARE hive, whose Keys are named after the GUID of each task.
+func (c *autoRunCollector) scheduledTasks(src autoRunSource) error {
+    path := currentVersionNTPath + "\\Schedule\\TaskCache\\Tasks"
+    key, err := openKey(src.h, path)
+    if err != nil || key == nil { return err }
+    defer release(key)
+
+    return eachSubkey(key, func(guid string, task libregf.RegistryKey) error {
+        lw, err := lastWritten(task)
+        if err != nil { return err }
+        name := libregf.GetOr(task, "Path", guid)
+        actions, err := libregf.Get[[]byte](task, "Actions")
+        if err != nil { return nil }
+
+        for _, action := range parseTaskActions(actions) {
+            command := action.command
+            if action.clsid != "" {
+                if command, err = c.resolveCLSID(action.clsid); err != nil { return err }
+            }
+            c.add(src, AutoRunScheduledTask, path+"\\"+guid, name, command, lw)
+        }
+        return nil
+    })
+}
+
+// values adds an entry for each command fn returns for the Values of the Key at
+// path.
+func (c *autoRunCollector) values(src autoRunSource, category AutoRunCategory, path string, fn valueCommands) error {
+    key, err := openKey(src.h, path)
+    if err != nil || key == nil { return err }
+    defer release(key)
+
+    lw, err := lastWritten(key)
+    if err != nil { return err }
+
+    return eachValue(key, func(name string, value libregf.RegistryValue) error {
+        commands, err := fn(name, value)
+        if err != nil { return err }
+        for _, command := range commands {
+            c.add(src, category, path, name, command, lw)
+        }
+        return nil
+    })
+}
+
+// subkeys adds an entry, named after the sub-Key, for the command fn returns
+// for each sub-Key of the Key at path.
+func (c *autoRunCollector) subkeys(src autoRunSource, category AutoRunCategory, path string, fn keyCommand) error {
+    key, err := openKey(src.h, path)
+    if err != nil || key == nil { return err }
+    defer release(key)
+
+    return eachSubkey(key, func(name string, subkey libregf.RegistryKey) error {
+        command, err := fn(name, subkey)
+        if err != nil { return err }
+        lw, err := lastWritten(subkey)
+        if err != nil { return err }
+
+        c.add(src, category, path+"\\"+name, name, command, lw)
+        return nil
+    })
+}
+
+// add adds an entry, unless its command is empty.
+func (c *autoRunCollector) add(src autoRunSource, category AutoRunCategory, path, name, command string, lw time.Time) {
+    command = strings.TrimSpace(command)
+    if command == "" { return }
+
+    c.entries = append(c.entries, AutoRun{
+        Category:    category,
+        Hive:        src.hive,
+        User:        src.user,
+        Key:         path,
+        Name:        name,
+        Command:     expandImagePath(command, c.env),
+        LastWritten: lw,
+    })
+}
+
+// stringValues returns the strings of the Values named names, or of every
+// Value when no names are given. REG_MULTI_SZ Values give a command per string.
+func stringValues(names ...string) valueCommands {
+    return func(name string, value libregf.RegistryValue) ([]string, error) {
+        if len(names) > 0 {
+            found := false
+            for _, n := range names {
+                found = found || libregf.EqualNames(n, name)
+            }
+            if !found { return nil, nil }
+        }
+
+        _type, err := value.Type()
+        if err != nil { return nil, err }
+        switch _type {
+        case libregf.ValueTypeString, libregf.ValueTypeExpandableString:
+            s, err := value.TString()
+            if err != nil { return nil, err }
+            return []string{s}, nil
+        case libregf.ValueTypeMultiValueString:
+            return value.TStrings()
+        }
+        return nil, nil
+    }
+}
+
+// valueOf returns the string Value of a sub-Key by its name.
+func valueOf(name string) keyCommand {
+    return func(_ string, key libregf.RegistryKey) (string, error) {
+        return libregf.GetOr(key, name, ""), nil
+    }
+}
+
+// resolveCLSID returns the server of the COM class whose CLSID is clsid, as
+// registered in the SOFTWARE hive, or "" when unknown.
+func (c *autoRunCollector) resolveCLSID(clsid string) (string, error) {
+    if c.software == nil || clsid == "" { return "", nil }
+
+    for _, prefix := range []string{"", wow64Path} {
+        key, err := openKey(c.software, prefix+"Classes\\CLSID\\"+clsid)
+        if err != nil { return "", err }
+        if key == nil { continue }
+
+        server, err := comServer(key)
+        release(key)
+        if err != nil || server != "" { return server, err }
+    }
+
+    return "", nil
+}
+
+// resolveCLSIDs is resolveCLSID for valueCommands.
+func (c *autoRunCollector) resolveCLSIDs(clsid string) ([]string, error) {
+    server, err := c.resolveCLSID(clsid)
+    if err != nil { return nil, err }
+    return []string{server}, nil
+}
+
+// comServer returns the DLL or the executable serving the COM class of key,
+// from the default Value of its InprocServer32 or LocalServer32 sub-Key.
+func comServer(key libregf.RegistryKey) (string, error) {
+    for _, name := range []string{"InprocServer32", "LocalServer32"} {
+        server, err := key.GetSubkey(name)
+        if errors.Is(err, libregf.ErrNotFound) { continue }
+        if err != nil { return "", err }
+
+        s := libregf.GetOr(server, "", "")
+        release(server)
+        if s != "" { return s, nil }
+    }
+
+    return "", nil
+}
+
+// taskAction is an action of a scheduled task: a command line to execute or a
+// COM handler.
+type taskAction struct {
+    command string
+    clsid   string
+}
+
+// Magic numbers of the actions of scheduled tasks.
+const (
+    taskActionExec       = 0x6666
+    taskActionComHandler = 0x7777
+    taskActionEmail      = 0x8888
+    taskActionMessageBox = 0x9999
+)
+
+// parseTaskActions decodes the Actions Value of a task of the Task Scheduler
+// cache: a version, the principal of the task for versions 2 and 3, then each
+// action, introduced by its magic number and, for version 3, its identifier.
+// Strings are stored as their size in bytes followed by UTF-16. Decoding stops
+// at e-mail and message box actions, which are deprecated and start nothing.
+func parseTaskActions(data []byte) []taskAction {
+    f := &fields{data: data}
+    str := func() string {
+        size := int(f.uint32())
+        if size > len(data)-f.off { f.short = true }
+        if f.short { return "" }
+        return f.utf16(size)
+    }
+
+    actions := []taskAction{}
+    version := f.uint16()
+    if version >= 2 { str() }
+    for !f.short && f.off < len(data) {
+        magic := f.uint16()
+        if version >= 3 { str() }
+
+        var action taskAction
+        switch magic {
+        case taskActionExec:
+            action.command = str()
+            if args := str(); args != "" { action.command += " " + args }
+            str() // working directory
+            if version >= 3 { f.uint16() }
+        case taskActionComHandler:
+            action.clsid = guidAt(f.next(16), 0)
+            str() // data
+        default:
+            return actions
+        }
+        if f.short { break }
+
+        actions = append(actions, action)
+    }
+
+    return actions
+}
@@ -0,0 +1,135 @@
+package artifacts_test
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// taskString encodes a string of the Actions Value of a scheduled task: its
+// size in bytes, then UTF-16.
+func taskString(s string) []byte {
+    u := utf16z(s)
+    u = u[:len(u)-2]
+    return append(le.AppendUint32(nil, uint32(len(u))), u...)
+}
+
+// taskExec encodes an action executing command, for an Actions Value of the
+// given version.
+func taskExec(version uint16, command, args, dir string) []byte {
+    data := le.AppendUint16(nil, 0x6666)
+    if version >= 3 { data = append(data, taskString("Action1")...) }
+    data = bytes.Join([][]byte{data, taskString(command), taskString(args), taskString(dir)}, nil)
+    if version >= 3 { data = le.AppendUint16(data, 0) }
+    return data
+}
+
+func TestAutoRuns(t *testing.T) {
+    lw := time.Date(2024, 10, 1, 8, 0, 0, 0, time.UTC)
+    const (
+        bho     = "{11111111-2222-3333-4444-555555555555}"
+        handler = "{66666666-7777-8888-9999-AAAAAAAAAAAA}"
+        hijack  = "{BCDE0395-E52F-467C-8E3D-C4579291692E}"
+        tasks   = "Microsoft\\Windows NT\\CurrentVersion\\Schedule\\TaskCache\\Tasks"
+    )
+    str := func(name, s string) regftest.Value {
+        return regftest.Value{Name: name, Type: "REG_SZ", String: s}
+    }
+    server := func(s string) []regftest.Value {
+        return []regftest.Value{str("", s)}
+    }
+
+    v1 := bytes.Join([][]byte{le.AppendUint16(nil, 1), taskExec(1, "C:\\updater.exe", "/quiet", "C:\\")}, nil)
+    v3 := bytes.Join([][]byte{
+        le.AppendUint16(nil, 3), taskString("Author"),
+        taskExec(3, "%windir%\\system32\\cmd.exe", "/c cleanup.bat", ""),
+        le.AppendUint16(nil, 0x7777), taskString("Action2"), guidBytes(handler), taskString("<data/>"),
+        // e-mail actions end the list
+        le.AppendUint16(nil, 0x8888), taskString("Action3"),
+        taskExec(3, "C:\\never.exe", "", ""),
+    }, nil)
+    software := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Microsoft\\Windows\\CurrentVersion\\Run", LastWritten: lw, Values: []regftest.Value{
+            str("OneDrive", "\"C:\\Program Files\\Microsoft OneDrive\\OneDrive.exe\" /background"),
+            str("Empty", ""),
+            {Name: "Count", Type: "REG_DWORD", Integer: 1},
+        }},
+        {Path: "Wow6432Node\\Microsoft\\Windows\\CurrentVersion\\RunOnce", Values: []regftest.Value{
+            {Name: "Setup", Type: "REG_EXPAND_SZ", String: "%SystemRoot%\\SysWOW64\\setup.exe"},
+        }},
+        {Path: "Microsoft\\Windows NT\\CurrentVersion\\Image File Execution Options\\notepad.exe", Values: []regftest.Value{str("GlobalFlag", "0x200")}},
+        {Path: "Microsoft\\Windows NT\\CurrentVersion\\Image File Execution Options\\sethc.exe", LastWritten: lw, Values: []regftest.Value{str("Debugger", "C:\\Windows\\System32\\cmd.exe")}},
+        {Path: "Microsoft\\Windows NT\\CurrentVersion\\Winlogon", Values: []regftest.Value{
+            str("Shell", "explorer.exe, C:\\evil.exe"),
+            str("Userinit", "C:\\Windows\\system32\\userinit.exe,"),
+            str("DefaultUserName", "alice"),
+        }},
+        {Path: "Microsoft\\Windows\\CurrentVersion\\Explorer\\Browser Helper Objects\\" + bho},
+        {Path: "Classes\\CLSID\\" + bho + "\\InprocServer32", Values: server("C:\\Program Files\\Toolbar\\bho.dll")},
+        {Path: "Wow6432Node\\Classes\\CLSID\\" + handler + "\\LocalServer32", Values: server("C:\\Program Files (x86)\\Handler\\handler.exe")},
+        {Path: tasks + "\\{0A000000-0000-0000-0000-000000000000}", LastWritten: lw, Values: []regftest.Value{
+            str("Path", "\\Updater"),
+            {Name: "Actions", Type: "REG_BINARY", Data: v1},
+        }},
+        {Path: tasks + "\\{0B000000-0000-0000-0000-000000000000}", Values: []regftest.Value{
+            str("Path", "\\Microsoft\\Windows\\Cleanup"),
+            {Name: "Actions", Type: "REG_BINARY", Data: v3},
+        }},
+        {Path: tasks + "\\{0C000000-0000-0000-0000-000000000000}", Values: []regftest.Value{str("Path", "\\NoActions")}},
+        {Path: tasks + "\\{0D000000-0000-0000-0000-000000000000}", Values: []regftest.Value{
+            str("Path", "\\Truncated"),
+            {Name: "Actions", Type: "REG_BINARY", Data: v1[:len(v1)-4]},
+        }},
+    }})
+    ntuser := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Software\\Microsoft\\Windows\\CurrentVersion\\Run", Values: []regftest.Value{str("Updater", "C:\\Users\\alice\\AppData\\updater.exe")}},
+        {Path: "Software\\Microsoft\\Windows NT\\CurrentVersion\\Windows", Values: []regftest.Value{str("Load", "C:\\Users\\alice\\load.exe"), str("Device", "printer")}},
+    }})
+    usrclass := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "CLSID\\" + hijack + "\\InprocServer32", Values: server("C:\\Users\\alice\\AppData\\hijack.dll")},
+        {Path: "CLSID\\{00000000-0000-0000-0000-000000000001}"},
+    }})
+    env := libregf.NewEnvironment(map[string]string{"SystemRoot": "C:\\Windows", "windir": "C:\\Windows"})
+
+    entries, err := artifacts.AutoRuns(artifacts.Hives{Software: software, Users: map[string]artifacts.UserHives{
+        "alice": {NTUser: ntuser, UsrClass: usrclass},
+        "bob":   {},
+    }}, env)
+    if err != nil { t.Fatal(err) }
+
+    const nt = "Microsoft\\Windows NT\\CurrentVersion\\"
+    want := []artifacts.AutoRun{
+        {Category: artifacts.AutoRunLogon, Hive: "SOFTWARE", Key: "Microsoft\\Windows\\CurrentVersion\\Run", Name: "OneDrive", Command: "\"C:\\Program Files\\Microsoft OneDrive\\OneDrive.exe\" /background"},
+        {Category: artifacts.AutoRunImageHijack, Hive: "SOFTWARE", Key: nt + "Image File Execution Options\\sethc.exe", Name: "sethc.exe", Command: "C:\\Windows\\System32\\cmd.exe"},
+        {Category: artifacts.AutoRunIE, Hive: "SOFTWARE", Key: "Microsoft\\Windows\\CurrentVersion\\Explorer\\Browser Helper Objects\\" + bho, Name: bho, Command: "C:\\Program Files\\Toolbar\\bho.dll"},
+        {Category: artifacts.AutoRunLogon, Hive: "SOFTWARE", Key: "Wow6432Node\\Microsoft\\Windows\\CurrentVersion\\RunOnce", Name: "Setup", Command: "C:\\Windows\\SysWOW64\\setup.exe"},
+        {Category: artifacts.AutoRunWinlogon, Hive: "SOFTWARE", Key: nt + "Winlogon", Name: "Shell", Command: "explorer.exe, C:\\evil.exe"},
+        {Category: artifacts.AutoRunWinlogon, Hive: "SOFTWARE", Key: nt + "Winlogon", Name: "Userinit", Command: "C:\\Windows\\system32\\userinit.exe,"},
+        {Category: artifacts.AutoRunScheduledTask, Hive: "SOFTWARE", Key: tasks + "\\{0A000000-0000-0000-0000-000000000000}", Name: "\\Updater", Command: "C:\\updater.exe /quiet"},
+        {Category: artifacts.AutoRunScheduledTask, Hive: "SOFTWARE", Key: tasks + "\\{0B000000-0000-0000-0000-000000000000}", Name: "\\Microsoft\\Windows\\Cleanup", Command: "C:\\Windows\\system32\\cmd.exe /c cleanup.bat"},
+        {Category: artifacts.AutoRunScheduledTask, Hive: "SOFTWARE", Key: tasks + "\\{0B000000-0000-0000-0000-000000000000}", Name: "\\Microsoft\\Windows\\Cleanup", Command: "C:\\Program Files (x86)\\Handler\\handler.exe"},
+        {Category: artifacts.AutoRunLogon, Hive: "NTUSER.DAT", User: "alice", Key: "Software\\Microsoft\\Windows\\CurrentVersion\\Run", Name: "Updater", Command: "C:\\Users\\alice\\AppData\\updater.exe"},
+        {Category: artifacts.AutoRunLogon, Hive: "NTUSER.DAT", User: "alice", Key: "Software\\" + nt + "Windows", Name: "Load", Command: "C:\\Users\\alice\\load.exe"},
+        {Category: artifacts.AutoRunCOMHijack, Hive: "UsrClass.dat", User: "alice", Key: "CLSID\\" + hijack, Name: hijack, Command: "C:\\Users\\alice\\AppData\\hijack.dll"},
+    }
+
+    got := make([]artifacts.AutoRun, len(entries))
+    for i, e := range entries {
+        got[i] = e
+        got[i].LastWritten = time.Time{}
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got %d entries:", len(got))
+        for _, e := range got {
+            t.Errorf("  %+v", e)
+        }
+    }
+    for _, e := range entries {
+        if (e.Name == "OneDrive" || e.Name == "sethc.exe" || e.Name == "\\Updater") && !e.LastWritten.Equal(lw) { t.Errorf("%s: got LastWritten %v", e.Name, e.LastWritten) }
+    }
+}
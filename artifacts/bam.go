@@ -0,0 +1,77 @@
+package artifacts
+
+import (
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths of the Keys holding a Key per user for the Background Activity
+// Moderator (bam) and the Desktop Activity Moderator (dam), relative to a
+// control set of a SYSTEM hive.
+var bamPaths = []struct{ service, path string }{
+    {"bam", "Services\\bam\\State\\UserSettings"},
+    {"bam", "Services\\bam\\UserSettings"}, // Windows 10 1709 to 1803
+    {"dam", "Services\\dam\\State\\UserSettings"},
+    {"dam", "Services\\dam\\UserSettings"}, // Windows 10 1709 to 1803
+}
+
+// BAMEntry is a program that the Background or the Desktop Activity Moderator
+// saw running on behalf of a user.
+type BAMEntry struct {
+    Service        string    // "bam" or "dam"
+    SID            string    // SID of the user
+    User           string    // name of the user, when known
+    Path           string    // path of the program, such as \Device\HarddiskVolume2\Windows\explorer.exe, or name of its package
+    LastExecuted   time.Time // when the program last ran
+    SequenceNumber uint32    // SequenceNumber of the Key of the user
+    Version        uint32    // Version of the Key of the user
+    LastWritten    time.Time // last written time of the Key of the user
+}
+
+// BAM reads the programs that the Background and the Desktop Activity
+// Moderators saw running, from the current control set of a SYSTEM hive.
+// SIDs are resolved to user names by users, which may be nil; see ReadUserNames.
+func BAM(system libregf.Hive, users UserNames) ([]BAMEntry, error) {
+    ccs, err := currentControlSet(system)
+    if err != nil { return nil, err }
+
+    entries := []BAMEntry{}
+    if ccs == "" { return entries, nil }
+    for _, p := range bamPaths {
+        key, err := openKey(system, ccs+"\\"+p.path)
+        if err != nil { return nil, err }
+        if key == nil { continue }
+
+        err = eachSubkey(key, func(sid string, user libregf.RegistryKey) error {
+            lw, err := lastWritten(user)
+            if err != nil { return err }
+            sequence := libregf.GetOr[uint32](user, "SequenceNumber", 0)
+            version := libregf.GetOr[uint32](user, "Version", 0)
+
+            return eachValue(user, func(name string, value libregf.RegistryValue) error {
+                _type, err := value.Type()
+                if err != nil { return err }
+                if _type != libregf.ValueTypeBinaryData { return nil }
+                data, err := value.Data()
+                if err != nil { return err }
+
+                entries = append(entries, BAMEntry{
+                    Service:        p.service,
+                    SID:            sid,
+                    User:           users.Lookup(sid),
+                    Path:           name,
+                    LastExecuted:   filetimeAt(data, 0),
+                    SequenceNumber: sequence,
+                    Version:        version,
+                    LastWritten:    lw,
+                })
+                return nil
+            })
+        })
+        release(key)
+        if err != nil { return nil, err }
+    }
+
+    return entries, nil
+}
@@ -0,0 +1,124 @@
+package artifacts_test
+
+import (
+    "encoding/binary"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+const domainSID = "S-1-5-21-111-222-333"
+
+// bamValue returns the 24 bytes of a bam or dam Value, which start with a FILETIME.
+func bamValue(t time.Time) []byte {
+    data := make([]byte, 24)
+    binary.LittleEndian.PutUint64(data, filetime(t))
+    return data
+}
+
+// samAccountV returns a V Value of the Account Key of a SAM hive, which ends
+// with the SID of the machine.
+func samAccountV() []byte {
+    data := make([]byte, 64)
+    data = append(data, 1, 4, 0, 0, 0, 0, 0, 5)
+    for _, n := range []uint32{21, 111, 222, 333} {
+        data = binary.LittleEndian.AppendUint32(data, n)
+    }
+    return data
+}
+
+func TestReadUserNames(t *testing.T) {
+    sam := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: artifacts.SAMAccountPath, Values: []regftest.Value{{Name: "V", Type: "REG_BINARY", Data: samAccountV()}}},
+        {Path: artifacts.SAMUserNamesPath + "\\Administrator", Values: []regftest.Value{{Name: "", Type: "0x1f4"}}},
+        {Path: artifacts.SAMUserNamesPath + "\\bob", Values: []regftest.Value{{Name: "", Type: "0x3ea"}}},
+        {Path: artifacts.SAMUserNamesPath + "\\NoDefault"},
+    }})
+    software := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: artifacts.ProfileListPath + "\\" + domainSID + "-1001", Values: []regftest.Value{{Name: "ProfileImagePath", Type: "REG_EXPAND_SZ", String: "C:\\Users\\alice"}}},
+        {Path: artifacts.ProfileListPath + "\\S-1-5-21-444-555-666-1105", Values: []regftest.Value{{Name: "ProfileImagePath", Type: "REG_EXPAND_SZ", String: "C:\\Users\\carol.CORP"}}},
+    }})
+
+    names, err := artifacts.ReadUserNames(sam, software)
+    if err != nil { t.Fatal(err) }
+    for sid, want := range map[string]string{
+        domainSID + "-500":          "Administrator",
+        domainSID + "-1001":         "alice",
+        domainSID + "-1002":         "bob",
+        "S-1-5-21-444-555-666-1105": "carol.CORP",
+        "S-1-5-18":                  "SYSTEM",
+        "S-1-5-19":                  "LOCAL SERVICE",
+        "s-1-5-20":                  "NETWORK SERVICE",
+        domainSID + "-1003":         "",
+    } {
+        if got := names.Lookup(sid); got != want { t.Errorf("%s: got %q, want %q", sid, got, want) }
+    }
+    if len(names) != 7 { t.Errorf("got %d names, want 7: %v", len(names), names) }
+
+    // either hive may be missing, and a SAM hive without V names no one
+    names, err = artifacts.ReadUserNames(openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: artifacts.SAMUserNamesPath + "\\bob", Values: []regftest.Value{{Name: "", Type: "0x3ea"}}},
+    }}), nil)
+    if err != nil || len(names) != 3 { t.Errorf("SAM without V: got %v, %v", names, err) }
+    if name := artifacts.UserNames(nil).Lookup("S-1-5-18"); name != "" { t.Errorf("nil UserNames: got %q", name) }
+}
+
+func TestBAM(t *testing.T) {
+    lw := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+    run := time.Date(2024, 5, 6, 7, 0, 0, 0, time.UTC)
+    old := time.Date(2018, 4, 1, 10, 0, 0, 0, time.UTC)
+    alice, bob := domainSID+"-1001", domainSID+"-1002"
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 1}}},
+        {Path: "ControlSet001\\Services\\bam\\State\\UserSettings\\" + alice, LastWritten: lw, Values: []regftest.Value{
+            {Name: "\\Device\\HarddiskVolume2\\Windows\\explorer.exe", Type: "REG_BINARY", Data: bamValue(run)},
+            {Name: "Microsoft.WindowsCalculator_8wekyb3d8bbwe", Type: "REG_BINARY", Data: bamValue(run)},
+            {Name: "SequenceNumber", Type: "REG_DWORD", Integer: 7},
+            {Name: "Version", Type: "REG_DWORD", Integer: 1},
+        }},
+        {Path: "ControlSet001\\Services\\bam\\UserSettings\\" + bob, LastWritten: lw, Values: []regftest.Value{
+            {Name: "\\Device\\HarddiskVolume2\\Tools\\old.exe", Type: "REG_BINARY", Data: bamValue(old)},
+            {Name: "SequenceNumber", Type: "REG_DWORD", Integer: 2},
+        }},
+        {Path: "ControlSet001\\Services\\dam\\State\\UserSettings\\" + alice, LastWritten: lw, Values: []regftest.Value{
+            {Name: "Microsoft.Windows.Photos_8wekyb3d8bbwe", Type: "REG_BINARY", Data: bamValue(run)},
+            {Name: "SequenceNumber", Type: "REG_DWORD", Integer: 3},
+            {Name: "Version", Type: "REG_DWORD", Integer: 1},
+        }},
+        {Path: "ControlSet001\\Services\\dam\\UserSettings\\" + bob, LastWritten: lw, Values: []regftest.Value{
+            {Name: "Microsoft.Windows.Maps_8wekyb3d8bbwe", Type: "REG_BINARY", Data: bamValue(old)},
+        }},
+        {Path: "ControlSet002\\Services\\bam\\State\\UserSettings\\" + alice, Values: []regftest.Value{
+            {Name: "\\Device\\HarddiskVolume2\\Windows\\stale.exe", Type: "REG_BINARY", Data: bamValue(old)},
+        }},
+    }})
+    users := artifacts.UserNames{alice: "alice"}
+
+    entries, err := artifacts.BAM(system, users)
+    if err != nil { t.Fatal(err) }
+
+    want := []artifacts.BAMEntry{
+        {Service: "bam", SID: alice, User: "alice", Path: "\\Device\\HarddiskVolume2\\Windows\\explorer.exe", LastExecuted: run, SequenceNumber: 7, Version: 1, LastWritten: lw},
+        {Service: "bam", SID: alice, User: "alice", Path: "Microsoft.WindowsCalculator_8wekyb3d8bbwe", LastExecuted: run, SequenceNumber: 7, Version: 1, LastWritten: lw},
+        {Service: "bam", SID: bob, Path: "\\Device\\HarddiskVolume2\\Tools\\old.exe", LastExecuted: old, SequenceNumber: 2, LastWritten: lw},
+        {Service: "dam", SID: alice, User: "alice", Path: "Microsoft.Windows.Photos_8wekyb3d8bbwe", LastExecuted: run, SequenceNumber: 3, Version: 1, LastWritten: lw},
+        {Service: "dam", SID: bob, Path: "Microsoft.Windows.Maps_8wekyb3d8bbwe", LastExecuted: old, LastWritten: lw},
+    }
+    if len(entries) != len(want) { t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries) }
+    byPath := map[string]artifacts.BAMEntry{}
+    for _, e := range entries {
+        byPath[e.Service+":"+e.Path] = e
+    }
+    for _, w := range want {
+        e, ok := byPath[w.Service+":"+w.Path]
+        if !ok { t.Errorf("%s %s: missing", w.Service, w.Path); continue }
+        if e.SID != w.SID || e.User != w.User || e.SequenceNumber != w.SequenceNumber || e.Version != w.Version { t.Errorf("%s %s: got %+v, want %+v", w.Service, w.Path, e, w) }
+        if !e.LastExecuted.Equal(w.LastExecuted) || !e.LastWritten.Equal(w.LastWritten) { t.Errorf("%s %s: got times %v, %v", w.Service, w.Path, e.LastExecuted, e.LastWritten) }
+    }
+
+    // without Select\Current there is no telling which control set to read
+    entries, err = artifacts.BAM(openHive(t, &regftest.Hive{Keys: []regftest.Key{{Path: "ControlSet001\\Services\\bam\\State\\UserSettings\\" + alice}}}), nil)
+    if err != nil || len(entries) != 0 { t.Errorf("no Select: got %+v, %v", entries, err) }
+}
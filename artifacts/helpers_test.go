@@ -0,0 +1,23 @@
+package artifacts_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// openHive builds h in a temporary directory and opens it, closing it when the
+// test ends.
+func openHive(t testing.TB, h *regftest.Hive) *libregf.File {
+    t.Helper()
+
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatalf("building hive: %v", err) }
+
+    file, err := libregf.OpenFile(path)
+    if err != nil { t.Fatalf("opening hive: %v", err) }
+    t.Cleanup(file.Close)
+
+    return file
+}
@@ -0,0 +1,59 @@
+package artifacts
+
+import (
+    "strings"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// KnownFolders maps the GUIDs of the known folders of Windows, upper cased and
+// between braces, to their default paths. Paths that depend on the system or
+// the user hold environment variables, which an Environment expands.
+var KnownFolders = map[string]string{
+    "{008CA0B1-55B4-4C56-B8A8-4DE4B299D3BE}": "%APPDATA%\\Microsoft\\Windows\\AccountPictures",
+    "{0139D44E-6AFE-49F2-8690-3DAFCAE6FFB8}": "%ProgramData%\\Microsoft\\Windows\\Start Menu\\Programs",
+    "{0762D272-C50A-4BB0-A382-697DCD729B80}": "%SystemDrive%\\Users",
+    "{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}": "%SystemRoot%\\System32",
+    "{18989B1D-99B5-455B-841C-AB7C74E4DDFC}": "%USERPROFILE%\\Videos",
+    "{33E28130-4E1E-4676-835A-98395C3BC3BB}": "%USERPROFILE%\\Pictures",
+    "{374DE290-123F-4565-9164-39C4925E467B}": "%USERPROFILE%\\Downloads",
+    "{3EB685DB-65F9-4CF6-A03A-E3EF65729F3D}": "%APPDATA%",
+    "{4BD8D571-6D19-48D3-BE97-422220080E43}": "%USERPROFILE%\\Music",
+    "{5E6C858F-0E22-4760-9AFE-EA3317B67173}": "%USERPROFILE%",
+    "{6365D5A7-0F0D-45E5-87F6-0DA56B6A4F7D}": "%CommonProgramW6432%",
+    "{625B53C3-AB48-4EC1-BA1F-A1EF4146FC19}": "%APPDATA%\\Microsoft\\Windows\\Start Menu",
+    "{62AB5D82-FDC1-4DC3-A9DD-070D1D495D97}": "%ProgramData%",
+    "{6D809377-6AF0-444B-8957-A3773F02200E}": "%ProgramW6432%",
+    "{7C5A40EF-A0FB-4BFC-874A-C0F2E0B9FA8E}": "%ProgramFiles(x86)%",
+    "{82A5EA35-D9CD-47C5-9629-E15D2F714E6E}": "%ProgramData%\\Microsoft\\Windows\\Start Menu\\Programs\\StartUp",
+    "{8983036C-27C0-404B-8F08-102D10DCFD74}": "%APPDATA%\\Microsoft\\Windows\\SendTo",
+    "{905E63B6-C1BF-494E-B29C-65B732D3D21A}": "%ProgramFiles%",
+    "{9E3995AB-1F9C-4F13-B827-48B24B6C7174}": "%APPDATA%\\Microsoft\\Internet Explorer\\Quick Launch\\User Pinned",
+    "{A4115719-D62E-491D-AA7C-E74B8BE3B067}": "%ProgramData%\\Microsoft\\Windows\\Start Menu",
+    "{A520A1A4-1780-4FF6-BD18-167343C5AF16}": "%USERPROFILE%\\AppData\\LocalLow",
+    "{A77F5D77-2E2B-44C3-A6A2-ABA601054A51}": "%APPDATA%\\Microsoft\\Windows\\Start Menu\\Programs",
+    "{B4BFCC3A-DB2C-424C-B029-7FE99A87C641}": "%USERPROFILE%\\Desktop",
+    "{B97D20BB-F46A-4C97-BA10-5E3608430854}": "%APPDATA%\\Microsoft\\Windows\\Start Menu\\Programs\\StartUp",
+    "{C4AA340D-F20F-4863-AFEF-F87EF2E6BA25}": "%PUBLIC%\\Desktop",
+    "{D65231B0-B2F1-4857-A4CE-A8E7C6EA7D27}": "%SystemRoot%\\SysWOW64",
+    "{DE974D24-D9C6-4D3E-BF91-F4455120B917}": "%CommonProgramFiles(x86)%",
+    "{DFDF76A2-C82A-4D63-906A-5644AC457385}": "%PUBLIC%",
+    "{F1B32785-6FBA-4FCF-9D55-7B8E7F157091}": "%LOCALAPPDATA%",
+    "{F38BF404-1D43-42F2-9305-67DE0B28FC23}": "%SystemRoot%",
+    "{F7F1ED05-9F6D-47A2-AAAE-29D317C6F066}": "%CommonProgramFiles%",
+    "{FD228CB7-AE11-4AE3-864C-16F3910AB8FE}": "%SystemRoot%\\Fonts",
+    "{FDD39AD0-238F-46AF-ADB4-6C85480369C7}": "%USERPROFILE%\\Documents",
+}
+
+// ResolveKnownFolder replaces the known folder GUID that path starts with, as
+// in "{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\cmd.exe", by the path of the
+// folder, expanded by env unless env is nil. Other paths are returned as is.
+func ResolveKnownFolder(path string, env *libregf.Environment) string {
+    if len(path) < 38 || path[0] != '{' || path[37] != '}' { return path }
+
+    folder, ok := KnownFolders[strings.ToUpper(path[:38])]
+    if !ok { return path }
+    if env != nil { folder = env.Expand(folder) }
+
+    return folder + path[38:]
+}
@@ -0,0 +1,280 @@
+package artifacts
+
+import (
+    "errors"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths of the MRU lists read by MRULists, relative to an NTUSER.DAT hive.
+const (
+    RecentDocsPath         = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\RecentDocs"
+    OpenSavePidlMRUPath    = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\ComDlg32\\OpenSavePidlMRU"
+    LastVisitedPidlMRUPath = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\ComDlg32\\LastVisitedPidlMRU"
+    OpenSaveMRUPath        = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\ComDlg32\\OpenSaveMRU"    // Windows XP
+    LastVisitedMRUPath     = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\ComDlg32\\LastVisitedMRU" // Windows XP
+    RunMRUPath             = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\RunMRU"
+    TypedPathsPath         = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\TypedPaths"
+)
+
+// MRUEntry is an entry of a most recently used (MRU) list.
+//
+// Windows only records when a list last changed, as the last written time of
+// its Key, which is when its most recent entry was used: LastWritten is only
+// set for the entry at Position 0.
+type MRUEntry struct {
+    List        string      // name of the list, followed by the name of its sub-Key if any, such as "RecentDocs\.pdf"
+    Key         string      // path of the Key holding the entry
+    Name        string      // name of the Value holding the entry
+    Position    int         // position in the list, 0 being the most recent, or -1 when the entry isn't listed
+    Value       string      // the entry: name of a document, path of a folder, command...
+    Program     string      // program that used the entry, LastVisitedPidlMRU and LastVisitedMRU only
+    Items       []ShellItem // shell items the entry was decoded from, if any
+    LastWritten time.Time   // last written time of the Key, for the most recent entry only
+}
+
+// mruDecoder decodes the data of an MRU entry into entry.
+type mruDecoder func(entry *MRUEntry, value libregf.RegistryValue) error
+
+// MRULists reads the MRU lists of an NTUSER.DAT hive: RecentDocs,
+// OpenSavePidlMRU, LastVisitedPidlMRU, their Windows XP counterparts
+// OpenSaveMRU and LastVisitedMRU, RunMRU and TypedPaths.
+func MRULists(ntuser libregf.Hive) ([]MRUEntry, error) {
+    entries := []MRUEntry{}
+
+    for _, read := range []func(libregf.Hive) ([]MRUEntry, error){RecentDocs, OpenSavePidlMRU, LastVisitedPidlMRU, RunMRU, TypedPaths} {
+        found, err := read(ntuser)
+        if err != nil { return nil, err }
+        entries = append(entries, found...)
+    }
+
+    for _, list := range []struct {
+        name, path string
+        subkeys    bool
+    }{
+        {"OpenSaveMRU", OpenSaveMRUPath, true},
+        {"LastVisitedMRU", LastVisitedMRUPath, false},
+    } {
+        found, err := readMRU(ntuser, list.name, list.path, list.subkeys, decodeMRUString)
+        if err != nil { return nil, err }
+        entries = append(entries, found...)
+    }
+
+    return entries, nil
+}
+
+// RecentDocs reads the documents and folders recently opened by a user, from
+// the RecentDocs Key of their NTUSER.DAT hive and its sub-Key per extension.
+func RecentDocs(ntuser libregf.Hive) ([]MRUEntry, error) {
+    return readMRU(ntuser, "RecentDocs", RecentDocsPath, true, func(entry *MRUEntry, value libregf.RegistryValue) error {
+        data, err := value.Data()
+        if err != nil { return err }
+
+        // the name of the document, then the shell item of its shortcut
+        name, end := utf16At(data, 0)
+        entry.Value = name
+        entry.Items, _ = ParseShellItems(data[end:])
+        return nil
+    })
+}
+
+// OpenSavePidlMRU reads the files recently opened or saved through the common
+// file dialogs, by extension, from an NTUSER.DAT hive.
+func OpenSavePidlMRU(ntuser libregf.Hive) ([]MRUEntry, error) {
+    return readMRU(ntuser, "OpenSavePidlMRU", OpenSavePidlMRUPath, true, func(entry *MRUEntry, value libregf.RegistryValue) error {
+        data, err := value.Data()
+        if err != nil { return err }
+
+        entry.Items, _ = ParseShellItems(data)
+        entry.Value = ShellPath(entry.Items)
+        return nil
+    })
+}
+
+// LastVisitedPidlMRU reads the programs that recently used the common file
+// dialogs, along with the folder they last used, from an NTUSER.DAT hive.
+func LastVisitedPidlMRU(ntuser libregf.Hive) ([]MRUEntry, error) {
+    return readMRU(ntuser, "LastVisitedPidlMRU", LastVisitedPidlMRUPath, false, func(entry *MRUEntry, value libregf.RegistryValue) error {
+        data, err := value.Data()
+        if err != nil { return err }
+
+        // the name of the executable, then the shell item list of the folder
+        program, end := utf16At(data, 0)
+        entry.Program = program
+        entry.Items, _ = ParseShellItems(data[end:])
+        entry.Value = ShellPath(entry.Items)
+        return nil
+    })
+}
+
+// RunMRU reads the commands recently typed in the Run dialog, from an
+// NTUSER.DAT hive.
+func RunMRU(ntuser libregf.Hive) ([]MRUEntry, error) {
+    return readMRU(ntuser, "RunMRU", RunMRUPath, false, func(entry *MRUEntry, value libregf.RegistryValue) error {
+        if err := decodeMRUString(entry, value); err != nil { return err }
+
+        // commands end with "\1"
+        entry.Value = strings.TrimSuffix(entry.Value, "\\1")
+        return nil
+    })
+}
+
+// TypedPaths reads the paths recently typed in the address bar of Explorer,
+// from an NTUSER.DAT hive. Values are named url1, url2... from the most recent.
+func TypedPaths(ntuser libregf.Hive) ([]MRUEntry, error) {
+    key, err := openKey(ntuser, TypedPathsPath)
+    if err != nil || key == nil { return []MRUEntry{}, err }
+    defer release(key)
+
+    lw, err := lastWritten(key)
+    if err != nil { return nil, err }
+
+    entries := []MRUEntry{}
+    err = eachValue(key, func(name string, value libregf.RegistryValue) error {
+        entry := MRUEntry{List: "TypedPaths", Key: TypedPathsPath, Name: name, Position: -1}
+        if n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(name), "url")); err == nil && n > 0 { entry.Position = n - 1 }
+        if entry.Position == 0 { entry.LastWritten = lw }
+        if err := decodeMRUString(&entry, value); err != nil { return err }
+
+        entries = append(entries, entry)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    sortMRU(entries)
+    return entries, nil
+}
+
+// readMRU reads the MRU list of the Key by its path inside h and, when subkeys
+// is true, those of its sub-Keys, decoding entries with decode.
+func readMRU(h libregf.Hive, list, path string, subkeys bool, decode mruDecoder) ([]MRUEntry, error) {
+    key, err := openKey(h, path)
+    if err != nil || key == nil { return []MRUEntry{}, err }
+    defer release(key)
+
+    entries, err := readMRUKey(key, list, path, decode)
+    if err != nil || !subkeys { return entries, err }
+
+    err = eachSubkey(key, func(name string, subkey libregf.RegistryKey) error {
+        found, err := readMRUKey(subkey, list+"\\"+name, path+"\\"+name, decode)
+        if err != nil { return err }
+        entries = append(entries, found...)
+        return nil
+    })
+
+    return entries, err
+}
+
+// readMRUKey reads the entries of the MRU list of key, found at path, in
+// their order.
+func readMRUKey(key libregf.RegistryKey, list, path string, decode mruDecoder) ([]MRUEntry, error) {
+    order, err := MRUOrder(key)
+    if err != nil { return nil, err }
+    positions := make(map[string]int, len(order))
+    for i, name := range order {
+        positions[libregf.UpcaseName(name)] = i
+    }
+
+    lw, err := lastWritten(key)
+    if err != nil { return nil, err }
+
+    entries := []MRUEntry{}
+    err = eachValue(key, func(name string, value libregf.RegistryValue) error {
+        if libregf.EqualNames(name, "MRUList") || libregf.EqualNames(name, "MRUListEx") { return nil }
+
+        entry := MRUEntry{List: list, Key: path, Name: name, Position: -1}
+        if i, ok := positions[libregf.UpcaseName(name)]; ok { entry.Position = i }
+        if entry.Position == 0 { entry.LastWritten = lw }
+        if err := decode(&entry, value); err != nil { return err }
+
+        entries = append(entries, entry)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    sortMRU(entries)
+    return entries, nil
+}
+
+// sortMRU sorts entries by position, with unlisted entries last.
+func sortMRU(entries []MRUEntry) {
+    sort.SliceStable(entries, func(i, j int) bool {
+        pi, pj := entries[i].Position, entries[j].Position
+        if pi < 0 { return false }
+        return pj < 0 || pi < pj
+    })
+}
+
+// decodeMRUString decodes string entries, as found in RunMRU and the MRU lists
+// of Windows XP. LastVisitedMRU entries hold the name of a program followed by
+// a folder.
+func decodeMRUString(entry *MRUEntry, value libregf.RegistryValue) error {
+    _type, err := value.Type()
+    if err != nil { return err }
+    if _type == libregf.ValueTypeString || _type == libregf.ValueTypeExpandableString {
+        entry.Value, err = value.TString()
+        return err
+    }
+
+    data, err := value.Data()
+    if err != nil { return err }
+    first, end := utf16At(data, 0)
+    if second, _ := utf16At(data, end); second != "" {
+        entry.Program = first
+        entry.Value = second
+    } else {
+        entry.Value = first
+    }
+    return nil
+}
+
+// MRUOrder returns the names of the Values of an MRU list Key, most recent
+// first, as given by its MRUListEx Value (a list of numbers) or its MRUList
+// Value (a string of letters). Keys with neither give no names.
+func MRUOrder(key libregf.RegistryKey) ([]string, error) {
+    if data, err := getBytes(key, "MRUListEx"); err == nil {
+        names := []string{}
+        for _, n := range ParseMRUListEx(data) {
+            names = append(names, strconv.Itoa(n))
+        }
+        return names, nil
+    } else if !errors.Is(err, libregf.ErrNotFound) {
+        return nil, err
+    }
+
+    value, err := key.GetValue("MRUList")
+    if errors.Is(err, libregf.ErrNotFound) { return []string{}, nil }
+    if err != nil { return nil, err }
+    defer release(value)
+
+    list, err := value.TString()
+    if err != nil { return nil, err }
+    return ParseMRUList(list), nil
+}
+
+// ParseMRUListEx decodes the data of an MRUListEx Value: the numbers of the
+// entries, most recent first, up to a terminating 0xffffffff.
+func ParseMRUListEx(data []byte) []int {
+    order := []int{}
+    for i := 0; i+4 <= len(data); i += 4 {
+        n := le.Uint32(data[i:])
+        if n == 0xffffffff { break }
+        order = append(order, int(n))
+    }
+    return order
+}
+
+// ParseMRUList decodes an MRUList Value, whose letters are the names of the
+// entries, most recent first.
+func ParseMRUList(list string) []string {
+    names := []string{}
+    for _, r := range list {
+        if r == 0 { break }
+        names = append(names, string(r))
+    }
+    return names
+}
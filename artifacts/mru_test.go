@@ -0,0 +1,122 @@
+package artifacts_test
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// mruEntry is what TestMRULists checks of an MRUEntry.
+type mruEntry struct {
+    List     string
+    Name     string
+    Position int
+    Value    string
+    Program  string
+    Written  bool // whether LastWritten is set
+}
+
+func TestMRULists(t *testing.T) {
+    lw := time.Date(2024, 7, 8, 9, 10, 11, 0, time.UTC)
+    modified := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+    binary := func(name string, data ...[]byte) regftest.Value {
+        return regftest.Value{Name: name, Type: "REG_BINARY", Data: bytes.Join(data, nil)}
+    }
+    str := func(name, s string) regftest.Value {
+        return regftest.Value{Name: name, Type: "REG_SZ", String: s}
+    }
+    users := shellItemList(rootFolderItem("{20D04FE0-3AEA-1069-A2D8-08002B30309D}"), volumeItem("C:\\"), fileItem(0x31, 0, modified, "Users", nil))
+    report := shellItemList(fileItem(0x32, 1234, modified, "report.txt", nil))
+    ntuser := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: artifacts.RecentDocsPath, LastWritten: lw, Values: []regftest.Value{
+            binary("7", utf16z("unlisted.docx"), shellItemList()),
+            binary("0", utf16z("report.pdf"), shellItemList()),
+            binary("1", utf16z("Users"), shellItemList()),
+            binary("2", utf16z("notes.txt"), shellItemList()),
+            binary("MRUListEx", mruListEx(2, 0, 1)),
+        }},
+        {Path: artifacts.RecentDocsPath + "\\.pdf", LastWritten: lw, Values: []regftest.Value{
+            binary("0", utf16z("report.pdf"), shellItemList()),
+            binary("MRUListEx", mruListEx(0)),
+        }},
+        {Path: artifacts.OpenSavePidlMRUPath + "\\txt", LastWritten: lw, Values: []regftest.Value{
+            binary("0", report),
+            binary("MRUListEx", mruListEx(0)),
+        }},
+        {Path: artifacts.LastVisitedPidlMRUPath, LastWritten: lw, Values: []regftest.Value{
+            binary("0", utf16z("notepad.exe"), users),
+            binary("1", utf16z("mspaint.exe"), shellItemList(rootFolderItem("{20D04FE0-3AEA-1069-A2D8-08002B30309D}"))),
+            binary("MRUListEx", mruListEx(1, 0)),
+        }},
+        {Path: artifacts.OpenSaveMRUPath + "\\txt", LastWritten: lw, Values: []regftest.Value{
+            str("a", "C:\\notes.txt"),
+            str("MRUList", "a"),
+        }},
+        {Path: artifacts.LastVisitedMRUPath, LastWritten: lw, Values: []regftest.Value{
+            binary("a", utf16z("notepad.exe"), utf16z("C:\\Users")),
+            str("MRUList", "a"),
+        }},
+        {Path: artifacts.RunMRUPath, LastWritten: lw, Values: []regftest.Value{
+            str("a", "cmd\\1"),
+            str("b", "regedit\\1"),
+            str("c", "\\\\server\\share\\1"),
+            str("d", "unlisted\\1"),
+            str("MRUList", "cab"),
+        }},
+        {Path: artifacts.TypedPathsPath, LastWritten: lw, Values: []regftest.Value{
+            str("url10", "C:\\Ten"),
+            str("Other", "C:\\Other"),
+            str("url2", "C:\\Two"),
+            str("url1", "C:\\One"),
+        }},
+    }})
+
+    entries, err := artifacts.MRULists(ntuser)
+    if err != nil { t.Fatal(err) }
+
+    got := []mruEntry{}
+    for _, e := range entries {
+        got = append(got, mruEntry{e.List, e.Name, e.Position, e.Value, e.Program, !e.LastWritten.IsZero()})
+        if !e.LastWritten.IsZero() && !e.LastWritten.Equal(lw) { t.Errorf("%s %s: got LastWritten %v", e.List, e.Name, e.LastWritten) }
+    }
+    want := []mruEntry{
+        {"RecentDocs", "2", 0, "notes.txt", "", true},
+        {"RecentDocs", "0", 1, "report.pdf", "", false},
+        {"RecentDocs", "1", 2, "Users", "", false},
+        {"RecentDocs", "7", -1, "unlisted.docx", "", false},
+        {"RecentDocs\\.pdf", "0", 0, "report.pdf", "", true},
+        {"OpenSavePidlMRU\\txt", "0", 0, "report.txt", "", true},
+        {"LastVisitedPidlMRU", "1", 0, "My Computer", "mspaint.exe", true},
+        {"LastVisitedPidlMRU", "0", 1, "My Computer\\C:\\Users", "notepad.exe", false},
+        {"RunMRU", "c", 0, "\\\\server\\share", "", true},
+        {"RunMRU", "a", 1, "cmd", "", false},
+        {"RunMRU", "b", 2, "regedit", "", false},
+        {"RunMRU", "d", -1, "unlisted", "", false},
+        {"TypedPaths", "url1", 0, "C:\\One", "", true},
+        {"TypedPaths", "url2", 1, "C:\\Two", "", false},
+        {"TypedPaths", "url10", 9, "C:\\Ten", "", false},
+        {"TypedPaths", "Other", -1, "C:\\Other", "", false},
+        {"OpenSaveMRU\\txt", "a", 0, "C:\\notes.txt", "", true},
+        {"LastVisitedMRU", "a", 0, "C:\\Users", "notepad.exe", true},
+    }
+    if !reflect.DeepEqual(got, want) { t.Errorf("got:\n%+v\nwant:\n%+v", got, want) }
+
+    for _, e := range entries {
+        if e.List == "LastVisitedPidlMRU" && e.Name == "0" && len(e.Items) != 3 { t.Errorf("LastVisitedPidlMRU 0: got %d shell items, want 3", len(e.Items)) }
+        if e.List == "OpenSavePidlMRU\\txt" && e.Key != artifacts.OpenSavePidlMRUPath+"\\txt" { t.Errorf("OpenSavePidlMRU: got Key %q", e.Key) }
+    }
+
+    // hives without the lists give no entries
+    entries, err = artifacts.MRULists(openHive(t, &regftest.Hive{}))
+    if err != nil || len(entries) != 0 { t.Errorf("empty hive: got %+v, %v", entries, err) }
+}
+
+func TestParseMRUList(t *testing.T) {
+    if got := artifacts.ParseMRUListEx(mruListEx(3, 0, 12)); !reflect.DeepEqual(got, []int{3, 0, 12}) { t.Errorf("ParseMRUListEx: got %v", got) }
+    if got := artifacts.ParseMRUListEx([]byte{1, 0, 0, 0, 2, 0}); !reflect.DeepEqual(got, []int{1}) { t.Errorf("ParseMRUListEx, short: got %v", got) }
+    if got := artifacts.ParseMRUList("cab\x00d"); !reflect.DeepEqual(got, []string{"c", "a", "b"}) { t.Errorf("ParseMRUList: got %q", got) }
+}
@@ -0,0 +1,277 @@
+package artifacts
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths read by SAMUsers, along with SAMAccountPath and SAMUserNamesPath.
+const (
+    SAMUsersPath          = "SAM\\Domains\\Account\\Users"
+    SAMAliasesPath        = "SAM\\Domains\\Account\\Aliases"
+    SAMBuiltinAliasesPath = "SAM\\Domains\\Builtin\\Aliases"
+)
+
+// SAMAccountFlags are the account control bits (ACB) of a SAM account.
+type SAMAccountFlags uint16
+
+const (
+    SAMAccountDisabled              SAMAccountFlags = 0x0001
+    SAMHomeDirRequired              SAMAccountFlags = 0x0002
+    SAMPasswordNotRequired          SAMAccountFlags = 0x0004
+    SAMTempDuplicateAccount         SAMAccountFlags = 0x0008
+    SAMNormalAccount                SAMAccountFlags = 0x0010
+    SAMMNSLogonAccount              SAMAccountFlags = 0x0020
+    SAMInterdomainTrustAccount      SAMAccountFlags = 0x0040
+    SAMWorkstationTrustAccount      SAMAccountFlags = 0x0080
+    SAMServerTrustAccount           SAMAccountFlags = 0x0100
+    SAMPasswordDoesNotExpire        SAMAccountFlags = 0x0200
+    SAMAccountLocked                SAMAccountFlags = 0x0400
+    SAMEncryptedTextPasswordAllowed SAMAccountFlags = 0x0800
+    SAMSmartcardRequired            SAMAccountFlags = 0x1000
+    SAMTrustedForDelegation         SAMAccountFlags = 0x2000
+    SAMNotDelegated                 SAMAccountFlags = 0x4000
+    SAMUseDESKeyOnly                SAMAccountFlags = 0x8000
+)
+
+var samAccountFlagNames = []string{
+    "disabled", "home directory required", "password not required", "temporary duplicate account",
+    "normal account", "MNS logon account", "interdomain trust account", "workstation trust account",
+    "server trust account", "password does not expire", "locked", "encrypted text password allowed",
+    "smartcard required", "trusted for delegation", "not delegated", "use DES key only",
+}
+
+// String returns the names of the flags, separated by "|", such as
+// "disabled|normal account".
+func (flags SAMAccountFlags) String() string {
+    names := []string{}
+    for i, name := range samAccountFlagNames {
+        if flags&(1<<i) != 0 { names = append(names, name) }
+    }
+    return strings.Join(names, "|")
+}
+
+// SAMUser is a local account, from the F and V Values of its Key in a SAM hive.
+// Password hashes are left out, as they are encrypted by the boot key.
+type SAMUser struct {
+    RID                 uint32
+    SID                 string          // SID of the account, when the SID of the machine is known
+    Name                string
+    FullName            string
+    Comment             string
+    UserComment         string
+    HomeDir             string
+    HomeDirDrive        string
+    LogonScript         string
+    ProfilePath         string
+    Flags               SAMAccountFlags
+    Disabled            bool            // from Flags
+    Locked              bool            // from Flags
+    PasswordNotRequired bool            // from Flags
+    LastLogon           time.Time
+    PasswordLastSet     time.Time
+    AccountExpires      time.Time       // zero when the account never expires
+    LastFailedLogon     time.Time
+    FailedLogonCount    uint16
+    LogonCount          uint16
+    Groups              []string        // names of the local groups the account belongs to
+    Created             time.Time       // last written time of the Key of the account under Names, usually when it was created
+    LastWritten         time.Time       // last written time of the Key of the account
+    Err                 error           // set when the F or the V Value couldn't be read, leaving their fields zero
+}
+
+// samVHeaderSize is the size of the header of the V Value of an account,
+// holding the offset and the size of each of its fields, stored after it.
+const samVHeaderSize = 0xcc
+
+// samNeverExpires is the FILETIME of accounts that never expire.
+const samNeverExpires = 0x7fffffffffffffff
+
+// SAMUsers reads the local accounts of a SAM hive, along with the groups they
+// belong to from the aliases of the Builtin and the Account domains. Accounts
+// whose F or V Value can't be read are still returned, with their Err field set.
+func SAMUsers(sam libregf.Hive) ([]SAMUser, error) {
+    key, err := openKey(sam, SAMUsersPath)
+    if err != nil || key == nil { return []SAMUser{}, err }
+    defer release(key)
+
+    domain, err := samDomainSID(sam)
+    if err != nil { return nil, err }
+    created, err := samCreationTimes(sam)
+    if err != nil { return nil, err }
+    groups, err := samGroups(sam)
+    if err != nil { return nil, err }
+
+    users := []SAMUser{}
+    err = eachSubkey(key, func(name string, account libregf.RegistryKey) error {
+        // Keys of accounts are named after their RID, in hexadecimal
+        rid, err := strconv.ParseUint(name, 16, 32)
+        if err != nil { return nil }
+
+        user := SAMUser{RID: uint32(rid), Created: created[uint32(rid)], Groups: []string{}}
+        if domain != "" { user.SID = fmt.Sprintf("%s-%d", domain, rid) }
+        if user.LastWritten, err = lastWritten(account); err != nil { return err }
+        for _, p := range []struct {
+            name  string
+            parse func([]byte)
+        }{
+            {"F", user.parseF},
+            {"V", user.parseV},
+        } {
+            // accounts may lack either Value, which leaves their fields zero
+            data, err := getBytes(account, p.name)
+            if err == nil {
+                p.parse(data)
+            } else if !errors.Is(err, libregf.ErrNotFound) && user.Err == nil {
+                user.Err = err
+            }
+        }
+        for _, group := range groups {
+            for _, member := range group.members {
+                if member == user.SID && user.SID != "" { user.Groups = append(user.Groups, group.name) }
+            }
+        }
+
+        users = append(users, user)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    return users, nil
+}
+
+// parseF decodes the F Value of an account, which holds its times, counters
+// and flags.
+func (user *SAMUser) parseF(f []byte) {
+    if len(f) < 0x44 { return }
+
+    user.LastLogon = samTime(le.Uint64(f[0x08:]))
+    user.PasswordLastSet = samTime(le.Uint64(f[0x18:]))
+    user.AccountExpires = samTime(le.Uint64(f[0x20:]))
+    user.LastFailedLogon = samTime(le.Uint64(f[0x28:]))
+    user.Flags = SAMAccountFlags(le.Uint16(f[0x38:]))
+    user.FailedLogonCount = le.Uint16(f[0x40:])
+    user.LogonCount = le.Uint16(f[0x42:])
+
+    user.Disabled = user.Flags&SAMAccountDisabled != 0
+    user.Locked = user.Flags&SAMAccountLocked != 0
+    user.PasswordNotRequired = user.Flags&SAMPasswordNotRequired != 0
+}
+
+// parseV decodes the strings of the V Value of an account. Its header holds a
+// 12 bytes entry per field: the offset of the field after the header, its
+// size and an unknown number.
+func (user *SAMUser) parseV(v []byte) {
+    for i, field := range []*string{
+        1: &user.Name,
+        2: &user.FullName,
+        3: &user.Comment,
+        4: &user.UserComment,
+        6: &user.HomeDir,
+        7: &user.HomeDirDrive,
+        8: &user.LogonScript,
+        9: &user.ProfilePath,
+    } {
+        if field == nil { continue }
+        *field = samString(v, samVHeaderSize, i*12)
+    }
+}
+
+// samString returns the UTF-16 string whose offset, relative to base, and
+// size are stored at entry in data, or "" when they are out of bounds.
+func samString(data []byte, base, entry int) string {
+    if entry+8 > len(data) { return "" }
+
+    offset := uint64(base) + uint64(le.Uint32(data[entry:]))
+    size := uint64(le.Uint32(data[entry+4:]))
+    if offset+size > uint64(len(data)) { return "" }
+
+    return decodeUTF16(data[offset : offset+size])
+}
+
+// samTime converts the FILETIMEs of the F Value, giving a zero time.Time for
+// the times that never happen.
+func samTime(ft uint64) time.Time {
+    if ft >= samNeverExpires { return time.Time{} }
+    return libregf.FiletimeToTime(ft)
+}
+
+// samCreationTimes returns the last written time of the Keys under Names, by
+// the RID of the account, held by the type of their default Value.
+func samCreationTimes(sam libregf.Hive) (map[uint32]time.Time, error) {
+    times := map[uint32]time.Time{}
+
+    key, err := openKey(sam, SAMUserNamesPath)
+    if err != nil || key == nil { return times, err }
+    defer release(key)
+
+    err = eachSubkey(key, func(_ string, user libregf.RegistryKey) error {
+        value, err := user.GetValue("")
+        if err != nil { return nil }
+        rid, err := value.Type()
+        release(value)
+        if err != nil { return err }
+
+        times[uint32(rid)], err = lastWritten(user)
+        return err
+    })
+
+    return times, err
+}
+
+// samGroup is a local group: an alias of the Builtin or the Account domain.
+type samGroup struct {
+    name    string
+    members []string // SIDs of the members
+}
+
+// samGroups reads the local groups of a SAM hive, from the C Value of each
+// alias.
+func samGroups(sam libregf.Hive) ([]samGroup, error) {
+    groups := []samGroup{}
+
+    for _, path := range []string{SAMBuiltinAliasesPath, SAMAliasesPath} {
+        key, err := openKey(sam, path)
+        if err != nil { return nil, err }
+        if key == nil { continue }
+
+        err = eachSubkey(key, func(name string, alias libregf.RegistryKey) error {
+            if _, err := strconv.ParseUint(name, 16, 32); err != nil { return nil }
+            if c, err := getBytes(alias, "C"); err == nil { groups = append(groups, parseSAMAlias(c)) }
+            return nil
+        })
+        release(key)
+        if err != nil { return nil, err }
+    }
+
+    return groups, nil
+}
+
+// samCHeaderSize is the size of the header of the C Value of an alias, which
+// holds the offsets of its fields, stored after it.
+const samCHeaderSize = 0x34
+
+// parseSAMAlias decodes the C Value of an alias: its name, then its members,
+// as consecutive binary SIDs.
+func parseSAMAlias(c []byte) samGroup {
+    group := samGroup{members: []string{}}
+    if len(c) < samCHeaderSize { return group }
+
+    group.name = samString(c, samCHeaderSize, 0x10)
+
+    offset := samCHeaderSize + int(le.Uint32(c[0x28:]))
+    n := int(le.Uint32(c[0x30:]))
+    for i := 0; i < n && offset >= samCHeaderSize && offset < len(c); i++ {
+        sid, size := parseSID(c[offset:])
+        if size == 0 { break }
+        group.members = append(group.members, sid)
+        offset += size
+    }
+
+    return group
+}
@@ -0,0 +1,154 @@
+package artifacts_test
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// sidBytes encodes a SID of the NT authority by its sub authorities.
+func sidBytes(subs ...uint32) []byte {
+    data := []byte{1, byte(len(subs)), 0, 0, 0, 0, 0, 5}
+    for _, n := range subs {
+        data = le.AppendUint32(data, n)
+    }
+    return data
+}
+
+// samF returns the F Value of an account.
+func samF(lastLogon, passwordSet, expires, failed uint64, flags artifacts.SAMAccountFlags, failures, logons uint16) []byte {
+    f := make([]byte, 0x50)
+    le.PutUint64(f[0x08:], lastLogon)
+    le.PutUint64(f[0x18:], passwordSet)
+    le.PutUint64(f[0x20:], expires)
+    le.PutUint64(f[0x28:], failed)
+    le.PutUint16(f[0x38:], uint16(flags))
+    le.PutUint16(f[0x40:], failures)
+    le.PutUint16(f[0x42:], logons)
+    return f
+}
+
+// samV returns the V Value of an account holding fields, by their index in
+// the header.
+func samV(fields map[int]string) []byte {
+    header := make([]byte, 0xcc)
+    data := []byte{}
+    for i := 0; i < 17; i++ {
+        s, ok := fields[i]
+        if !ok { continue }
+        u := utf16z(s)
+        u = u[:len(u)-2]
+        le.PutUint32(header[i*12:], uint32(len(data)))
+        le.PutUint32(header[i*12+4:], uint32(len(u)))
+        data = append(data, u...)
+        // fields are aligned on 4 bytes
+        for len(data)%4 != 0 {
+            data = append(data, 0)
+        }
+    }
+    return append(header, data...)
+}
+
+// samC returns the C Value of an alias.
+func samC(name string, members ...[]byte) []byte {
+    header := make([]byte, 0x34)
+    u := utf16z(name)
+    u = u[:len(u)-2]
+    le.PutUint32(header[0x10:], 0)
+    le.PutUint32(header[0x14:], uint32(len(u)))
+    le.PutUint32(header[0x28:], uint32(len(u)))
+    le.PutUint32(header[0x30:], uint32(len(members)))
+    return bytes.Join([][]byte{header, u, bytes.Join(members, nil)}, nil)
+}
+
+func TestSAMUsers(t *testing.T) {
+    lw := time.Date(2024, 11, 2, 3, 4, 5, 0, time.UTC)
+    created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+    logon := time.Date(2024, 11, 1, 8, 30, 0, 0, time.UTC)
+    passwordSet := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+    expires := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+    failed := time.Date(2024, 10, 31, 23, 59, 58, 0, time.UTC)
+    const never = 0x7fffffffffffffff
+    users := artifacts.SAMUsersPath + "\\"
+    binary := func(name string, data []byte) regftest.Value {
+        return regftest.Value{Name: name, Type: "REG_BINARY", Data: data}
+    }
+
+    sam := openHive(t, &regftest.Hive{
+        Keys: []regftest.Key{
+            {Path: artifacts.SAMAccountPath, Values: []regftest.Value{binary("V", samAccountV())}},
+            {Path: users + "000001F4", LastWritten: lw, Values: []regftest.Value{
+                binary("F", samF(filetime(logon), filetime(passwordSet), never, 0, artifacts.SAMNormalAccount|artifacts.SAMPasswordDoesNotExpire, 0, 42)),
+                binary("V", samV(map[int]string{1: "Administrator", 3: "Built-in account for administering the computer/domain"})),
+            }},
+            {Path: users + "000003E9", LastWritten: lw, Values: []regftest.Value{
+                binary("F", samF(0, filetime(passwordSet), filetime(expires), filetime(failed), artifacts.SAMNormalAccount|artifacts.SAMAccountDisabled|artifacts.SAMAccountLocked|artifacts.SAMPasswordNotRequired, 3, 7)),
+                binary("V", samV(map[int]string{1: "alice", 2: "Alice Liddell", 4: "user comment", 6: "\\\\server\\alice", 7: "H:", 8: "logon.bat", 9: "C:\\Profiles\\alice"})),
+            }},
+            {Path: users + "000003EA", Values: []regftest.Value{
+                binary("F", samF(0, 0, never, never, artifacts.SAMNormalAccount, 0, 0)),
+                binary("V", samV(map[int]string{1: "bob"})),
+            }},
+            {Path: users + "000003EB", Values: []regftest.Value{binary("F", make([]byte, 0x20))}},
+            {Path: users + "Names\\alice", LastWritten: created, Values: []regftest.Value{{Name: "", Type: "0x3e9"}}},
+            {Path: artifacts.SAMBuiltinAliasesPath + "\\00000220", Values: []regftest.Value{
+                binary("C", samC("Administrators", sidBytes(21, 111, 222, 333, 500), sidBytes(21, 111, 222, 333, 1001))),
+            }},
+            {Path: artifacts.SAMBuiltinAliasesPath + "\\00000221", Values: []regftest.Value{
+                binary("C", samC("Users", sidBytes(4), sidBytes(11), sidBytes(21, 111, 222, 333, 1001))),
+            }},
+            {Path: artifacts.SAMBuiltinAliasesPath + "\\Members"},
+            {Path: artifacts.SAMAliasesPath + "\\000003EC", Values: []regftest.Value{
+                binary("C", samC("Helpers", sidBytes(21, 111, 222, 333, 1002))),
+            }},
+        },
+        Corruptions: []regftest.Corruption{{Path: users + "000003EA", Value: "F", Kind: regftest.BadDataOffset}},
+    })
+
+    accounts, err := artifacts.SAMUsers(sam)
+    if err != nil { t.Fatal(err) }
+    if len(accounts) != 4 { t.Fatalf("got %d accounts, want 4: %+v", len(accounts), accounts) }
+
+    admin := accounts[0]
+    if admin.RID != 500 || admin.SID != domainSID+"-500" || admin.Name != "Administrator" || admin.Comment != "Built-in account for administering the computer/domain" || admin.Err != nil { t.Errorf("Administrator: got %+v", admin) }
+    if !admin.LastLogon.Equal(logon) || !admin.PasswordLastSet.Equal(passwordSet) || !admin.AccountExpires.IsZero() || !admin.LastFailedLogon.IsZero() || !admin.LastWritten.Equal(lw) || !admin.Created.IsZero() {
+        t.Errorf("Administrator: got times %+v", admin)
+    }
+    if admin.Flags != artifacts.SAMNormalAccount|artifacts.SAMPasswordDoesNotExpire || admin.Flags.String() != "normal account|password does not expire" || admin.Disabled || admin.Locked || admin.LogonCount != 42 {
+        t.Errorf("Administrator: got flags %v, counts %+v", admin.Flags, admin)
+    }
+    if !reflect.DeepEqual(admin.Groups, []string{"Administrators"}) { t.Errorf("Administrator: got groups %q", admin.Groups) }
+
+    alice := accounts[1]
+    want := artifacts.SAMUser{
+        RID: 1001, SID: domainSID + "-1001", Name: "alice", FullName: "Alice Liddell", UserComment: "user comment",
+        HomeDir: "\\\\server\\alice", HomeDirDrive: "H:", LogonScript: "logon.bat", ProfilePath: "C:\\Profiles\\alice",
+        Flags: artifacts.SAMNormalAccount | artifacts.SAMAccountDisabled | artifacts.SAMAccountLocked | artifacts.SAMPasswordNotRequired,
+        Disabled: true, Locked: true, PasswordNotRequired: true, FailedLogonCount: 3, LogonCount: 7,
+        Groups: []string{"Administrators", "Users"},
+    }
+    got := alice
+    got.PasswordLastSet, got.AccountExpires, got.LastFailedLogon, got.Created, got.LastWritten = time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}
+    if !reflect.DeepEqual(got, want) { t.Errorf("alice: got %+v, want %+v", got, want) }
+    if !alice.LastLogon.IsZero() || !alice.PasswordLastSet.Equal(passwordSet) || !alice.AccountExpires.Equal(expires) || !alice.LastFailedLogon.Equal(failed) || !alice.Created.Equal(created) {
+        t.Errorf("alice: got times %+v", alice)
+    }
+
+    // an unreadable F leaves its fields zero, while V is still read
+    bob := accounts[2]
+    if bob.RID != 1002 || bob.Err == nil || bob.Name != "bob" || bob.Flags != 0 || !reflect.DeepEqual(bob.Groups, []string{"Helpers"}) { t.Errorf("bob: got %+v", bob) }
+
+    // a short F and a missing V aren't errors
+    if short := accounts[3]; short.RID != 1003 || short.Err != nil || short.Name != "" || short.Flags != 0 || len(short.Groups) != 0 { t.Errorf("000003EB: got %+v", short) }
+
+    // without the Account V Value, SIDs and groups are unknown
+    accounts, err = artifacts.SAMUsers(openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: users + "000001F4", Values: []regftest.Value{binary("V", samV(map[int]string{1: "Administrator"}))}},
+        {Path: artifacts.SAMBuiltinAliasesPath + "\\00000220", Values: []regftest.Value{binary("C", samC("Administrators", sidBytes(21, 111, 222, 333, 500)))}},
+    }}))
+    if err != nil || len(accounts) != 1 || accounts[0].SID != "" || accounts[0].Name != "Administrator" || len(accounts[0].Groups) != 0 { t.Errorf("no domain SID: got %+v, %v", accounts, err) }
+}
@@ -0,0 +1,231 @@
+package artifacts
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// ServicesPath is the path of the Key of the services and drivers, relative to
+// a control set of a SYSTEM hive.
+const ServicesPath = "Services"
+
+// ServiceType is the Type Value of a service, a combination of flags.
+type ServiceType uint32
+
+const (
+    ServiceKernelDriver        ServiceType = 0x001
+    ServiceFileSystemDriver    ServiceType = 0x002
+    ServiceAdapter             ServiceType = 0x004
+    ServiceRecognizerDriver    ServiceType = 0x008
+    ServiceWin32OwnProcess     ServiceType = 0x010
+    ServiceWin32ShareProcess   ServiceType = 0x020
+    ServiceUserService         ServiceType = 0x040 // template of a per user service
+    ServiceUserServiceInstance ServiceType = 0x080
+    ServiceInteractiveProcess  ServiceType = 0x100
+)
+
+var serviceTypeNames = []struct {
+    t    ServiceType
+    name string
+}{
+    {ServiceKernelDriver, "kernel driver"},
+    {ServiceFileSystemDriver, "file system driver"},
+    {ServiceAdapter, "adapter"},
+    {ServiceRecognizerDriver, "recognizer driver"},
+    {ServiceWin32OwnProcess, "own process"},
+    {ServiceWin32ShareProcess, "share process"},
+    {ServiceUserService, "user service"},
+    {ServiceUserServiceInstance, "user service instance"},
+    {ServiceInteractiveProcess, "interactive"},
+}
+
+// String returns the names of the flags of the type, separated by "|", such
+// as "share process|user service".
+func (t ServiceType) String() string {
+    names := []string{}
+    for _, n := range serviceTypeNames {
+        if t&n.t != 0 {
+            names = append(names, n.name)
+            t &^= n.t
+        }
+    }
+    if t != 0 || len(names) == 0 { names = append(names, fmt.Sprintf("0x%x", uint32(t))) }
+    return strings.Join(names, "|")
+}
+
+// IsDriver tells whether the service is a driver rather than a process.
+func (t ServiceType) IsDriver() bool {
+    return t&(ServiceKernelDriver|ServiceFileSystemDriver|ServiceRecognizerDriver) != 0
+}
+
+// ServiceStart is the Start Value of a service: when it starts.
+type ServiceStart uint32
+
+const (
+    ServiceBootStart ServiceStart = iota // loaded by the boot loader, drivers only
+    ServiceSystemStart                   // loaded as the kernel initializes, drivers only
+    ServiceAutoStart                     // started by the service control manager at boot
+    ServiceDemandStart                   // started on demand
+    ServiceDisabled
+)
+
+var serviceStartNames = []string{"boot", "system", "automatic", "manual", "disabled"}
+
+// String returns the name of the start mode.
+func (s ServiceStart) String() string {
+    if int(s) >= len(serviceStartNames) { return fmt.Sprintf("%d", uint32(s)) }
+    return serviceStartNames[s]
+}
+
+// ServiceErrorControl is the ErrorControl Value of a service: what Windows does
+// when it fails to start at boot.
+type ServiceErrorControl uint32
+
+var serviceErrorControlNames = []string{"ignore", "normal", "severe", "critical"}
+
+// String returns the name of the error control.
+func (e ServiceErrorControl) String() string {
+    if int(e) >= len(serviceErrorControlNames) { return fmt.Sprintf("%d", uint32(e)) }
+    return serviceErrorControlNames[e]
+}
+
+// ServiceActionType is what the service control manager does when a service
+// fails.
+type ServiceActionType uint32
+
+var serviceActionTypeNames = []string{"none", "restart", "reboot", "run command"}
+
+// String returns the name of the action.
+func (a ServiceActionType) String() string {
+    if int(a) >= len(serviceActionTypeNames) { return fmt.Sprintf("%d", uint32(a)) }
+    return serviceActionTypeNames[a]
+}
+
+// ServiceFailureAction is an action taken when a service fails, after Delay.
+type ServiceFailureAction struct {
+    Type  ServiceActionType
+    Delay time.Duration
+}
+
+// ServiceFailureActions is what the service control manager does when a
+// service fails, from its FailureActions, FailureCommand and RebootMessage
+// Values. Actions apply to the successive failures, the last one repeating.
+type ServiceFailureActions struct {
+    ResetPeriod   time.Duration          // time without failure after which the failure count is reset
+    Actions       []ServiceFailureAction
+    Command       string                 // command of the "run command" action
+    RebootMessage string                 // message broadcast before the "reboot" action
+}
+
+// Service is a service or a driver, from the Services Key of a SYSTEM hive.
+// Descriptions and display names are often references to resources of a DLL,
+// such as "@%SystemRoot%\system32\wuaueng.dll,-105", and are left as is.
+type Service struct {
+    Name             string                 `reg:",keyname"`
+    DisplayName      string
+    Description      string
+    ImagePath        string                 `reg:"-"`         // path of the executable or of the driver, expanded
+    RawImagePath     string                 `reg:"ImagePath"` // ImagePath as stored
+    Type             ServiceType
+    Start            ServiceStart
+    ErrorControl     ServiceErrorControl
+    ObjectName       string                 // account the service runs as, or driver object of a driver
+    Group            string                 // load ordering group
+    DependOnService  []string
+    DependOnGroup    []string
+    DelayedAutostart bool                   `reg:"DelayedAutoStart"`
+    ServiceDll       string                 `reg:"-"`         // DLL of services hosted by svchost.exe, from Parameters, expanded
+    FailureActions   *ServiceFailureActions `reg:"-"`         // nil when the service has none
+    LastWritten      time.Time              `reg:",lastwritten"`
+    Err              error                  `reg:"-"`         // set when a Value of the Key couldn't be decoded, leaving some fields zero
+}
+
+// Services reads the services and drivers of the current control set of a
+// SYSTEM hive. Paths are expanded by env unless env is nil; see
+// libregf.SystemEnvironment. Either way, the "\SystemRoot\" prefix and the
+// paths relative to the system root that drivers use become "%SystemRoot%\".
+// Services with a Value that can't be decoded are still returned, with their
+// Err field set.
+func Services(system libregf.Hive, env *libregf.Environment) ([]Service, error) {
+    ccs, err := currentControlSet(system)
+    if err != nil || ccs == "" { return []Service{}, err }
+
+    key, err := openKey(system, ccs+"\\"+ServicesPath)
+    if err != nil || key == nil { return []Service{}, err }
+    defer release(key)
+
+    services := []Service{}
+    err = eachSubkey(key, func(_ string, key libregf.RegistryKey) error {
+        var service Service
+        err := libregf.Unmarshal(key, &service)
+        if err != nil && !decodeError(err) { return err }
+        service.Err = err
+        service.ImagePath = expandImagePath(service.RawImagePath, env)
+
+        dll := libregf.GetOr(key, "ServiceDll", "")
+        if parameters, err := key.GetSubkey("Parameters"); err == nil {
+            dll = libregf.GetOr(parameters, "ServiceDll", dll)
+            release(parameters)
+        } else if !errors.Is(err, libregf.ErrNotFound) {
+            return err
+        }
+        service.ServiceDll = expandImagePath(dll, env)
+
+        if data, err := libregf.Get[[]byte](key, "FailureActions"); err == nil {
+            service.FailureActions = ParseFailureActions(data)
+            service.FailureActions.Command = libregf.GetOr(key, "FailureCommand", "")
+            service.FailureActions.RebootMessage = libregf.GetOr(key, "RebootMessage", "")
+        }
+
+        services = append(services, service)
+        return nil
+    })
+    if err != nil { return nil, err }
+
+    return services, nil
+}
+
+// ParseFailureActions decodes the data of a FailureActions Value, a
+// SERVICE_FAILURE_ACTIONS structure whose pointers are left out: a 20 bytes
+// header holding the reset period and the number of actions, followed by the
+// type and the delay of each action.
+func ParseFailureActions(data []byte) *ServiceFailureActions {
+    f := &fields{data: data}
+    fa := &ServiceFailureActions{Actions: []ServiceFailureAction{}}
+
+    fa.ResetPeriod = time.Duration(f.uint32()) * time.Second
+    f.uint32() // reboot message
+    f.uint32() // command
+    n := int(f.uint32())
+    f.uint32() // actions
+    for i := 0; i < n; i++ {
+        action := ServiceFailureAction{Type: ServiceActionType(f.uint32()), Delay: time.Duration(f.uint32()) * time.Millisecond}
+        if f.short { break }
+        fa.Actions = append(fa.Actions, action)
+    }
+
+    return fa
+}
+
+// expandImagePath turns the paths of services and drivers into DOS paths,
+// expanded by env unless env is nil. Drivers use NT paths such as
+// "\SystemRoot\System32\drivers\disk.sys" or "\??\C:\Windows\...", or paths
+// relative to the system root such as "System32\drivers\disk.sys".
+func expandImagePath(path string, env *libregf.Environment) string {
+    lower := strings.ToLower(path)
+    switch {
+    case strings.HasPrefix(lower, "\\systemroot\\"):
+        path = "%SystemRoot%" + path[len("\\SystemRoot"):]
+    case strings.HasPrefix(lower, "\\??\\"):
+        path = path[len("\\??\\"):]
+    case strings.HasPrefix(lower, "system32\\") || strings.HasPrefix(lower, "syswow64\\"):
+        path = "%SystemRoot%\\" + path
+    }
+
+    if env != nil { path = env.Expand(path) }
+    return path
+}
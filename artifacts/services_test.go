@@ -0,0 +1,33 @@
+package artifacts_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// A service with a Value of an unexpected type is returned with Err set, and
+// the other services are still read.
+func TestServicesBadValues(t *testing.T) {
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 1}}},
+        {Path: "ControlSet001\\Services\\Bad", Values: []regftest.Value{
+            {Name: "ImagePath", Type: "REG_EXPAND_SZ", String: "C:\\bad.exe"},
+            {Name: "Start", Type: "REG_SZ", String: "auto"},
+        }},
+        {Path: "ControlSet001\\Services\\Good", Values: []regftest.Value{
+            {Name: "ImagePath", Type: "REG_EXPAND_SZ", String: "C:\\good.exe"},
+            {Name: "Start", Type: "REG_DWORD", Integer: 2},
+        }},
+        {Path: "ControlSet001\\Services\\Good\\Parameters", Values: []regftest.Value{{Name: "ServiceDll", Type: "REG_EXPAND_SZ", String: "C:\\good.dll"}}},
+    }})
+
+    services, err := artifacts.Services(system, nil)
+    if err != nil { t.Fatal(err) }
+    if len(services) != 2 { t.Fatalf("got %d services, want 2", len(services)) }
+
+    bad, good := services[0], services[1]
+    if bad.Name != "Bad" || bad.ImagePath != "C:\\bad.exe" || !isTypeError(bad.Err) { t.Errorf("Bad: got %+v", bad) }
+    if good.Name != "Good" || good.Err != nil || good.Start != 2 || good.ServiceDll != "C:\\good.dll" { t.Errorf("Good: got %+v", good) }
+}
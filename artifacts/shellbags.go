@@ -0,0 +1,146 @@
+package artifacts
+
+import (
+    "errors"
+    "strconv"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths of the BagMRU Keys, relative to the hive they are found in.
+const (
+    ShellBagsNTUser   = "Software\\Microsoft\\Windows\\Shell\\BagMRU"
+    ShellBagsNTUserXP = "Software\\Microsoft\\Windows\\ShellNoRoam\\BagMRU" // Windows XP
+    ShellBagsUsrClass = "Local Settings\\Software\\Microsoft\\Windows\\Shell\\BagMRU"
+)
+
+// maxShellBagDepth is how deep BagMRU Keys are followed, which stops corrupted
+// hives from looping forever.
+const maxShellBagDepth = 64
+
+// ShellBag is a folder that a user browsed in Explorer, as remembered by the
+// BagMRU Keys.
+//
+// LastWritten is the last written time of the BagMRU Key of the folder itself,
+// which changes as its sub folders are browsed. Interaction times are derived
+// from last written times the way ShellBags tools do: LastInteracted is the
+// last written time of the parent Key when the folder comes first in the
+// MRUListEx of its parent, and FirstInteracted is the last written time of
+// the Key of the folder when none of its sub folders were ever browsed, as
+// the Key has not changed since it was created.
+type ShellBag struct {
+    Key             string    // path of the BagMRU Key of the folder inside its hive
+    Path            string    // path of the folder, rebuilt from the shell items of its ancestors
+    Item            ShellItem // shell item of the folder itself
+    Slot            int       // NodeSlot of the folder, its Key under Bags, or -1
+    MRUPosition     int       // position in the MRUListEx of its parent, 0 being the most recent, or -1
+    FirstInteracted time.Time
+    LastInteracted  time.Time
+    LastWritten     time.Time
+}
+
+// ShellBags rebuilds the folders browsed by a user from the BagMRU Keys of
+// their NTUSER.DAT and UsrClass.dat hives, either of which may be nil.
+func ShellBags(ntuser, usrclass libregf.Hive) ([]ShellBag, error) {
+    bags := []ShellBag{}
+
+    for _, source := range []struct {
+        h    libregf.Hive
+        path string
+    }{
+        {ntuser, ShellBagsNTUser},
+        {ntuser, ShellBagsNTUserXP},
+        {usrclass, ShellBagsUsrClass},
+    } {
+        if source.h == nil { continue }
+
+        key, err := openKey(source.h, source.path)
+        if err != nil { return nil, err }
+        if key == nil { continue }
+
+        lw, err := lastWritten(key)
+        if err == nil { bags, err = shellBags(bags, key, source.path, "", lw, 0) }
+        release(key)
+        if err != nil { return nil, err }
+    }
+
+    return bags, nil
+}
+
+// shellBags appends the folders listed by the BagMRU Key key, found at path,
+// and those of their sub folders, to bags. The Key is the folder parent, whose
+// own path is given, and lw is its last written time.
+func shellBags(bags []ShellBag, key libregf.RegistryKey, path, parent string, lw time.Time, depth int) ([]ShellBag, error) {
+    if depth >= maxShellBagDepth { return bags, nil }
+
+    order := map[int]int{}
+    if mru, err := getBytes(key, "MRUListEx"); err == nil {
+        for i, n := range ParseMRUListEx(mru) {
+            order[n] = i
+        }
+    }
+
+    err := eachValue(key, func(name string, value libregf.RegistryValue) error {
+        n, err := strconv.Atoi(name)
+        if err != nil { return nil }
+        data, err := value.Data()
+        if err != nil { return err }
+
+        items, _ := ParseShellItems(data)
+        if len(items) == 0 { return nil }
+
+        bag := ShellBag{
+            Key:         path + "\\" + name,
+            Path:        parent,
+            Item:        items[len(items)-1],
+            Slot:        -1,
+            MRUPosition: -1,
+        }
+        for _, item := range items {
+            bag.Path = joinShellPath(bag.Path, item.Name)
+        }
+        if i, ok := order[n]; ok {
+            bag.MRUPosition = i
+            if i == 0 { bag.LastInteracted = lw }
+        }
+
+        subkey, err := key.GetSubkey(name)
+        if errors.Is(err, libregf.ErrNotFound) {
+            bags = append(bags, bag)
+            return nil
+        }
+        if err != nil { return err }
+        defer release(subkey)
+
+        if slot, err := libregf.Get[uint32](subkey, "NodeSlot"); err == nil { bag.Slot = int(slot) }
+        bag.LastWritten, err = lastWritten(subkey)
+        if err != nil { return err }
+        if !hasShellBags(subkey) { bag.FirstInteracted = bag.LastWritten }
+
+        bags = append(bags, bag)
+        bags, err = shellBags(bags, subkey, bag.Key, bag.Path, bag.LastWritten, depth+1)
+        return err
+    })
+
+    return bags, err
+}
+
+// hasShellBags tells whether a BagMRU Key lists any sub folder.
+func hasShellBags(key libregf.RegistryKey) bool {
+    found := false
+    eachValue(key, func(name string, value libregf.RegistryValue) error {
+        if _, err := strconv.Atoi(name); err == nil { found = true }
+        return nil
+    })
+    return found
+}
+
+// getBytes returns the data of the Value of key by its name, whatever its type.
+func getBytes(key libregf.RegistryKey, name string) ([]byte, error) {
+    value, err := key.GetValue(name)
+    if err != nil { return nil, err }
+    defer release(value)
+
+    return value.Data()
+}
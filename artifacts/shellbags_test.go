@@ -0,0 +1,69 @@
+package artifacts_test
+
+import (
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// mruListEx encodes an MRUListEx Value.
+func mruListEx(order ...uint32) []byte {
+    data := []byte{}
+    for _, n := range order {
+        data = le.AppendUint32(data, n)
+    }
+    return le.AppendUint32(data, 0xffffffff)
+}
+
+func TestShellBags(t *testing.T) {
+    lw := func(day int) time.Time { return time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC) }
+    bag := func(n string, items ...[]byte) regftest.Value {
+        return regftest.Value{Name: n, Type: "REG_BINARY", Data: shellItemList(items...)}
+    }
+    slot := func(n uint64) regftest.Value { return regftest.Value{Name: "NodeSlot", Type: "REG_DWORD", Integer: n} }
+    mru := func(order ...uint32) regftest.Value { return regftest.Value{Name: "MRUListEx", Type: "REG_BINARY", Data: mruListEx(order...)} }
+    const root = artifacts.ShellBagsNTUser
+    modified := time.Date(2022, 9, 10, 11, 12, 14, 0, time.UTC)
+
+    ntuser := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: root, LastWritten: lw(1), Values: []regftest.Value{
+            bag("0", rootFolderItem("{20D04FE0-3AEA-1069-A2D8-08002B30309D}")),
+            mru(0),
+        }},
+        {Path: root + "\\0", LastWritten: lw(2), Values: []regftest.Value{
+            bag("0", volumeItem("C:\\")),
+            bag("1", volumeItem("D:\\")),
+            mru(1, 0),
+            slot(1),
+        }},
+        {Path: root + "\\0\\0", LastWritten: lw(3), Values: []regftest.Value{
+            bag("0", fileItem(0x31, 0, modified, "Windows", nil)),
+            mru(0),
+            slot(2),
+        }},
+        {Path: root + "\\0\\0\\0", LastWritten: lw(4), Values: []regftest.Value{slot(3)}},
+    }})
+
+    bags, err := artifacts.ShellBags(ntuser, nil)
+    if err != nil { t.Fatal(err) }
+
+    want := []artifacts.ShellBag{
+        {Key: root + "\\0", Path: "My Computer", Slot: 1, MRUPosition: 0, LastInteracted: lw(1), LastWritten: lw(2)},
+        {Key: root + "\\0\\0", Path: "My Computer\\C:\\", Slot: 2, MRUPosition: 1, LastWritten: lw(3)},
+        {Key: root + "\\0\\0\\0", Path: "My Computer\\C:\\Windows", Slot: 3, MRUPosition: 0, FirstInteracted: lw(4), LastInteracted: lw(3), LastWritten: lw(4)},
+        {Key: root + "\\0\\1", Path: "My Computer\\D:\\", Slot: -1, MRUPosition: 0, LastInteracted: lw(2)},
+    }
+    if len(bags) != len(want) { t.Fatalf("got %d bags, want %d: %+v", len(bags), len(want), bags) }
+    for i, w := range want {
+        g := bags[i]
+        if g.Key != w.Key || g.Path != w.Path || g.Slot != w.Slot || g.MRUPosition != w.MRUPosition {
+            t.Errorf("bag %d: got %s %q slot %d position %d, want %s %q slot %d position %d", i, g.Key, g.Path, g.Slot, g.MRUPosition, w.Key, w.Path, w.Slot, w.MRUPosition)
+        }
+        if !g.FirstInteracted.Equal(w.FirstInteracted) || !g.LastInteracted.Equal(w.LastInteracted) || !g.LastWritten.Equal(w.LastWritten) {
+            t.Errorf("%s: got times %v, %v, %v, want %v, %v, %v", w.Key, g.FirstInteracted, g.LastInteracted, g.LastWritten, w.FirstInteracted, w.LastInteracted, w.LastWritten)
+        }
+    }
+    if bags[2].Item.Kind != artifacts.ShellItemFile || bags[2].Item.Name != "Windows" { t.Errorf("Item of Windows: got %+v", bags[2].Item) }
+}
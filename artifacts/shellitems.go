@@ -0,0 +1,238 @@
+package artifacts
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// ShellItemKind tells what a ShellItem stands for.
+type ShellItemKind int
+
+const (
+    ShellItemUnknown      ShellItemKind = iota
+    ShellItemRootFolder                 // a shell folder such as My Computer, by its CLSID
+    ShellItemVolume                     // a drive, such as "C:\"
+    ShellItemFile                       // a file or a directory
+    ShellItemNetwork                    // a network location, such as a share
+    ShellItemURI                        // a URI, such as an FTP site
+    ShellItemControlPanel               // a control panel item, by its CLSID
+    ShellItemZip                        // a file or a directory inside a zip file
+)
+
+var shellItemKindNames = []string{"unknown", "root-folder", "volume", "file", "network", "uri", "control-panel", "zip"}
+
+// String returns the name of the kind.
+func (kind ShellItemKind) String() string {
+    if kind < 0 || int(kind) >= len(shellItemKindNames) { return "unknown" }
+    return shellItemKindNames[kind]
+}
+
+// ShellFolders maps the CLSIDs of the shell folders found in root folder shell
+// items, upper cased and between braces, to their names.
+var ShellFolders = map[string]string{
+    "{018D5C66-4533-4307-9B53-224DE2ED1FE6}": "OneDrive",
+    "{031E4825-7B94-4DC3-B131-E946B44C8DD5}": "Libraries",
+    "{088E3905-0323-4B02-9826-5D99428E115F}": "Downloads",
+    "{0DB7E03F-FC29-4DC6-9020-FF41B59E513A}": "3D Objects",
+    "{208D2C60-3AEA-1069-A2D7-08002B30309D}": "My Network Places",
+    "{20D04FE0-3AEA-1069-A2D8-08002B30309D}": "My Computer",
+    "{21EC2020-3AEA-1069-A2DD-08002B30309D}": "Control Panel",
+    "{24AD3AD4-A569-4530-98E1-AB02F9417AA8}": "Pictures",
+    "{26EE0668-A00A-44D7-9371-BEB064C98683}": "Control Panel",
+    "{3DFDF296-DBEC-4FB4-81D1-6A3438BCF4DE}": "Music",
+    "{450D8FBA-AD25-11D0-98A8-0800361B1103}": "My Documents",
+    "{59031A47-3F72-44A7-89C5-5595FE6B30EE}": "User Files",
+    "{645FF040-5081-101B-9F08-00AA002F954E}": "Recycle Bin",
+    "{679F85CB-0220-4080-B29B-5540CC05AAB6}": "Quick Access",
+    "{A0953C92-50DC-43BF-BE83-3742FED03C9C}": "Videos",
+    "{B4BFCC3A-DB2C-424C-B029-7FE99A87C641}": "Desktop",
+    "{D3162B92-9365-467A-956B-92703ACA08AF}": "Documents",
+    "{F02C1A0D-BE21-4350-88B0-7367FC96EF3C}": "Network",
+    "{F874310E-B6B7-47DC-BC84-B9E6B38F5903}": "Home",
+}
+
+// ShellItem is an item of a shell item list (a PIDL), the way Explorer
+// identifies a folder or a file in ShellBags, MRU lists and shortcuts.
+// Fields that don't apply to its kind are left zero.
+type ShellItem struct {
+    Type        byte          // class type indicator, the byte that tells the layout of the item
+    Kind        ShellItemKind // what the item stands for
+    Name        string        // name of the item in a path: folder or file name, volume, share, URI...
+    CLSID       string        // CLSID of root folders and control panel items
+    Directory   bool          // whether a file item is a directory
+    Size        uint32        // size of a file item, 0 for directories and large files
+    Modified    time.Time     // last modification time of a file item
+    Created     time.Time     // creation time of a file item, from its extension block
+    Accessed    time.Time     // last access time of a file item, from its extension block
+    MFTEntry    uint64        // NTFS MFT entry of a file item, from its extension block
+    MFTSequence uint16        // NTFS MFT sequence number of a file item
+    Data        []byte        // the item itself, including its size
+}
+
+// Layout of the 0xbeef0004 extension block of file items.
+const (
+    beef0004Signature = 0xbeef0004
+    beef0004MinSize   = 20
+)
+
+// ParseShellItems decodes a shell item list, up to its terminating empty item.
+// Items that can't be decoded are returned with the ShellItemUnknown kind.
+func ParseShellItems(data []byte) ([]ShellItem, error) {
+    items := []ShellItem{}
+
+    for offset := 0; offset+2 <= len(data); {
+        size := int(le.Uint16(data[offset:]))
+        if size == 0 { break }
+        if size < 3 || offset+size > len(data) { return items, fmt.Errorf("artifacts: invalid shell item size %d at offset %d", size, offset) }
+
+        items = append(items, ParseShellItem(data[offset:offset+size]))
+        offset += size
+    }
+
+    return items, nil
+}
+
+// ParseShellItem decodes a single shell item, including its size.
+func ParseShellItem(data []byte) ShellItem {
+    item := ShellItem{Data: data}
+    if len(data) < 3 { return item }
+    item.Type = data[2]
+
+    switch {
+    case item.Type == 0x1f:
+        item.Kind = ShellItemRootFolder
+        item.CLSID = guidAt(data, 4)
+        item.Name = shellFolderName(item.CLSID)
+    case item.Type == 0x2e:
+        // volume items of this type hold a shell folder, as for a phone or a camera
+        item.Kind = ShellItemRootFolder
+        item.CLSID = guidAt(data, 4)
+        item.Name = shellFolderName(item.CLSID)
+    case item.Type&0xf0 == 0x20:
+        item.Kind = ShellItemVolume
+        item.Name, _ = asciiAt(data, 3)
+    case item.Type&0xf0 == 0x30:
+        parseFileItem(&item)
+    case item.Type&0xf0 == 0x40:
+        item.Kind = ShellItemNetwork
+        item.Name, _ = asciiAt(data, 5)
+    case item.Type == 0x52:
+        item.Kind = ShellItemZip
+        item.Name, _ = utf16At(data, 36)
+    case item.Type == 0x61:
+        parseURIItem(&item)
+    case item.Type == 0x71:
+        item.Kind = ShellItemControlPanel
+        item.CLSID = guidAt(data, 14)
+        item.Name = shellFolderName(item.CLSID)
+    default:
+        // other items, such as users property views, often embed a file extension block
+        if i := findBeef0004(data, 3); i >= 0 {
+            item.Kind = ShellItemFile
+            parseBeef0004(&item, data[i:])
+        }
+    }
+
+    return item
+}
+
+// shellFolderName returns the name of a shell folder or, failing that, the
+// path of a known folder or the CLSID itself.
+func shellFolderName(clsid string) string {
+    if name, ok := ShellFolders[clsid]; ok { return name }
+    if path, ok := KnownFolders[clsid]; ok { return path }
+    return clsid
+}
+
+// parseFileItem decodes the fields of a file entry shell item, then those of
+// its 0xbeef0004 extension block, whose long name replaces the short name.
+func parseFileItem(item *ShellItem) {
+    data := item.Data
+    item.Kind = ShellItemFile
+    item.Directory = item.Type&0x01 != 0
+    if len(data) < 14 { return }
+
+    item.Size = le.Uint32(data[4:])
+    item.Modified = dosTime(le.Uint16(data[8:]), le.Uint16(data[10:]))
+
+    var end int
+    if item.Type&0x04 != 0 {
+        item.Name, end = utf16At(data, 14)
+    } else {
+        item.Name, end = asciiAt(data, 14)
+    }
+    // the extension block is aligned on 2 bytes
+    if end%2 != 0 { end++ }
+
+    if i := findBeef0004(data, end); i >= 0 { parseBeef0004(item, data[i:]) }
+}
+
+// findBeef0004 returns the offset of the 0xbeef0004 extension block found in
+// data from offset on, or -1.
+func findBeef0004(data []byte, offset int) int {
+    for i := offset; i+8 <= len(data); i++ {
+        if le.Uint32(data[i+4:]) == beef0004Signature { return i }
+    }
+    return -1
+}
+
+// parseBeef0004 decodes a 0xbeef0004 extension block: the creation and last
+// access times, the MFT reference and the long name of a file item.
+func parseBeef0004(item *ShellItem, block []byte) {
+    size := int(le.Uint16(block))
+    if size < beef0004MinSize || size > len(block) { return }
+    block = block[:size]
+    version := le.Uint16(block[2:])
+
+    item.Created = dosTime(le.Uint16(block[8:]), le.Uint16(block[10:]))
+    item.Accessed = dosTime(le.Uint16(block[12:]), le.Uint16(block[14:]))
+
+    offset := 18
+    if version >= 7 {
+        if len(block) < 38 { return }
+        ref := le.Uint64(block[20:])
+        item.MFTEntry = ref & 0xffffffffffff
+        item.MFTSequence = uint16(ref >> 48)
+        offset = 36
+    }
+    if version >= 3 { offset += 2 }
+    if version >= 9 { offset += 4 }
+    if version >= 8 { offset += 4 }
+
+    // the block ends with the offset of its version, which no name overlaps
+    if name, _ := utf16At(block[:len(block)-2], offset); name != "" { item.Name = name }
+}
+
+// parseURIItem decodes a URI shell item, whose string follows a block of data
+// of its own size.
+func parseURIItem(item *ShellItem) {
+    data := item.Data
+    item.Kind = ShellItemURI
+    if len(data) < 6 { return }
+
+    offset := 6 + int(le.Uint16(data[4:]))
+    if data[3]&0x80 != 0 {
+        item.Name, _ = utf16At(data, offset)
+    } else {
+        item.Name, _ = asciiAt(data, offset)
+    }
+}
+
+// ShellPath joins the names of shell items into a path, such as
+// "My Computer\C:\Windows\System32".
+func ShellPath(items []ShellItem) string {
+    path := ""
+    for _, item := range items {
+        path = joinShellPath(path, item.Name)
+    }
+    return path
+}
+
+// joinShellPath appends name to path, adding a separator unless there is one
+// already, as after a volume such as "C:\".
+func joinShellPath(path, name string) string {
+    if path == "" || name == "" { return path + name }
+    if strings.HasSuffix(path, "\\") || strings.HasPrefix(name, "\\") { return path + name }
+    return path + "\\" + name
+}
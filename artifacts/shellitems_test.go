@@ -0,0 +1,161 @@
+package artifacts_test
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/hex"
+    "strings"
+    "testing"
+    "time"
+    "unicode/utf16"
+
+    "github.com/jdrowell/go-libregf/artifacts"
+)
+
+var le = binary.LittleEndian
+
+// guidBytes encodes a GUID written the way Windows does, between braces.
+func guidBytes(guid string) []byte {
+    b, _ := hex.DecodeString(strings.NewReplacer("{", "", "}", "", "-", "").Replace(guid))
+    le.PutUint32(b[0:], binary.BigEndian.Uint32(b[0:]))
+    le.PutUint16(b[4:], binary.BigEndian.Uint16(b[4:]))
+    le.PutUint16(b[6:], binary.BigEndian.Uint16(b[6:]))
+    return b
+}
+
+// utf16z encodes s in UTF-16LE, NUL terminated.
+func utf16z(s string) []byte {
+    b := []byte{}
+    for _, u := range utf16.Encode([]rune(s + "\x00")) {
+        b = le.AppendUint16(b, u)
+    }
+    return b
+}
+
+// dosDateTime encodes t as an MS-DOS date and time.
+func dosDateTime(t time.Time) []byte {
+    b := le.AppendUint16(nil, uint16((t.Year()-1980)<<9|int(t.Month())<<5|t.Day()))
+    return le.AppendUint16(b, uint16(t.Hour()<<11|t.Minute()<<5|t.Second()/2))
+}
+
+// shellItem prefixes body with its size, making a shell item.
+func shellItem(body ...[]byte) []byte {
+    data := bytes.Join(body, nil)
+    return append(le.AppendUint16(nil, uint16(len(data)+2)), data...)
+}
+
+// shellItemList ends items with an empty item.
+func shellItemList(items ...[]byte) []byte {
+    return append(bytes.Join(items, nil), 0, 0)
+}
+
+func rootFolderItem(clsid string) []byte {
+    return shellItem([]byte{0x1f, 0x50}, guidBytes(clsid))
+}
+
+func volumeItem(drive string) []byte {
+    name := make([]byte, 20)
+    copy(name, drive)
+    return shellItem([]byte{0x2f}, name)
+}
+
+// beef0004 returns a 0xbeef0004 extension block of the given version, which
+// starts at offset in its shell item.
+func beef0004(version uint16, created, accessed time.Time, ref uint64, long string, offset int) []byte {
+    body := bytes.Join([][]byte{le.AppendUint16(nil, version), le.AppendUint32(nil, 0xbeef0004), dosDateTime(created), dosDateTime(accessed), {0x2e, 0x00}}, nil)
+    if version >= 7 {
+        body = append(body, 0, 0)
+        body = le.AppendUint64(body, ref)
+        body = append(body, make([]byte, 8)...)
+    }
+    if version >= 3 { body = append(body, 0, 0) }
+    if version >= 9 { body = append(body, make([]byte, 4)...) }
+    if version >= 8 { body = append(body, make([]byte, 4)...) }
+    body = append(body, utf16z(long)...)
+    body = le.AppendUint16(body, uint16(offset))
+    return append(le.AppendUint16(nil, uint16(len(body)+2)), body...)
+}
+
+// fileItem returns a file entry shell item, of type 0x31 for directories, 0x32
+// for files, with 0x04 set for Unicode short names.
+func fileItem(_type byte, size uint32, modified time.Time, short string, ext []byte) []byte {
+    head := bytes.Join([][]byte{{_type, 0}, le.AppendUint32(nil, size), dosDateTime(modified), {0x10, 0}}, nil)
+    var name []byte
+    if _type&0x04 != 0 {
+        name = utf16z(short)
+    } else {
+        name = append([]byte(short), 0)
+    }
+    if (2+len(head)+len(name))%2 != 0 { name = append(name, 0) }
+    return shellItem(head, name, ext)
+}
+
+func TestParseShellItem(t *testing.T) {
+    created := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+    accessed := time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)
+    modified := time.Date(2022, 9, 10, 11, 12, 14, 0, time.UTC)
+    const ref = 0x0003_0000_0001_2345
+
+    // the extension block starts past the short name, 14 bytes in
+    v3 := fileItem(0x31, 0, modified, "PROGRA~1", beef0004(3, created, accessed, 0, "Program Files", 24))
+    v7 := fileItem(0x32, 1234, modified, "REPORT~1.DOC", beef0004(7, created, accessed, ref, "Report for 2022.docx", 28))
+    v9 := fileItem(0x35, 0, modified, "Données", beef0004(9, created, accessed, ref, "Données de l'année", 30))
+
+    for _, test := range []struct {
+        name string
+        data []byte
+        want artifacts.ShellItem
+    }{
+        {"root folder", rootFolderItem("{20D04FE0-3AEA-1069-A2D8-08002B30309D}"),
+            artifacts.ShellItem{Type: 0x1f, Kind: artifacts.ShellItemRootFolder, Name: "My Computer", CLSID: "{20D04FE0-3AEA-1069-A2D8-08002B30309D}"}},
+        {"known folder", rootFolderItem("{F38BF404-1D43-42F2-9305-67DE0B28FC23}"),
+            artifacts.ShellItem{Type: 0x1f, Kind: artifacts.ShellItemRootFolder, Name: "%SystemRoot%", CLSID: "{F38BF404-1D43-42F2-9305-67DE0B28FC23}"}},
+        {"volume", volumeItem("C:\\"),
+            artifacts.ShellItem{Type: 0x2f, Kind: artifacts.ShellItemVolume, Name: "C:\\"}},
+        {"directory, beef0004 version 3", v3,
+            artifacts.ShellItem{Type: 0x31, Kind: artifacts.ShellItemFile, Name: "Program Files", Directory: true, Modified: modified, Created: created, Accessed: accessed}},
+        {"file, beef0004 version 7", v7,
+            artifacts.ShellItem{Type: 0x32, Kind: artifacts.ShellItemFile, Name: "Report for 2022.docx", Size: 1234, Modified: modified, Created: created, Accessed: accessed, MFTEntry: 0x12345, MFTSequence: 3}},
+        {"Unicode directory, beef0004 version 9", v9,
+            artifacts.ShellItem{Type: 0x35, Kind: artifacts.ShellItemFile, Name: "Données de l'année", Directory: true, Modified: modified, Created: created, Accessed: accessed, MFTEntry: 0x12345, MFTSequence: 3}},
+        {"file without extension block", fileItem(0x32, 10, modified, "A.TXT", nil),
+            artifacts.ShellItem{Type: 0x32, Kind: artifacts.ShellItemFile, Name: "A.TXT", Size: 10, Modified: modified}},
+        {"network", shellItem([]byte{0x41, 0, 0}, []byte("\\\\server\\share\x00")),
+            artifacts.ShellItem{Type: 0x41, Kind: artifacts.ShellItemNetwork, Name: "\\\\server\\share"}},
+        {"ASCII URI", shellItem([]byte{0x61, 0x00}, le.AppendUint16(nil, 4), []byte{1, 2, 3, 4}, []byte("ftp://example.com\x00")),
+            artifacts.ShellItem{Type: 0x61, Kind: artifacts.ShellItemURI, Name: "ftp://example.com"}},
+        {"Unicode URI", shellItem([]byte{0x61, 0x80}, le.AppendUint16(nil, 0), utf16z("https://example.com/ü")),
+            artifacts.ShellItem{Type: 0x61, Kind: artifacts.ShellItemURI, Name: "https://example.com/ü"}},
+        {"control panel", shellItem([]byte{0x71, 0}, make([]byte, 10), guidBytes("{26EE0668-A00A-44D7-9371-BEB064C98683}")),
+            artifacts.ShellItem{Type: 0x71, Kind: artifacts.ShellItemControlPanel, Name: "Control Panel", CLSID: "{26EE0668-A00A-44D7-9371-BEB064C98683}"}},
+    } {
+        got := artifacts.ParseShellItem(test.data)
+        test.want.Data = test.data
+        if !equalShellItems(got, test.want) { t.Errorf("%s:\ngot  %+v\nwant %+v", test.name, got, test.want) }
+    }
+}
+
+// equalShellItems compares shell items, with times compared by Equal.
+func equalShellItems(a, b artifacts.ShellItem) bool {
+    if !a.Modified.Equal(b.Modified) || !a.Created.Equal(b.Created) || !a.Accessed.Equal(b.Accessed) { return false }
+    a.Modified, a.Created, a.Accessed = b.Modified, b.Created, b.Accessed
+    return a.Type == b.Type && a.Kind == b.Kind && a.Name == b.Name && a.CLSID == b.CLSID && a.Directory == b.Directory &&
+        a.Size == b.Size && a.MFTEntry == b.MFTEntry && a.MFTSequence == b.MFTSequence && bytes.Equal(a.Data, b.Data)
+}
+
+func TestParseShellItems(t *testing.T) {
+    modified := time.Date(2022, 9, 10, 11, 12, 14, 0, time.UTC)
+    data := shellItemList(
+        rootFolderItem("{20D04FE0-3AEA-1069-A2D8-08002B30309D}"),
+        volumeItem("C:\\"),
+        fileItem(0x31, 0, modified, "Windows", nil),
+        fileItem(0x31, 0, modified, "System32", nil),
+    )
+
+    items, err := artifacts.ParseShellItems(data)
+    if err != nil { t.Fatal(err) }
+    if path := artifacts.ShellPath(items); path != "My Computer\\C:\\Windows\\System32" { t.Errorf("ShellPath: got %q", path) }
+
+    // an item claiming more than there is
+    if _, err := artifacts.ParseShellItems([]byte{0x40, 0x00, 0x1f, 0x50}); err == nil { t.Error("ParseShellItems accepted a truncated item") }
+}
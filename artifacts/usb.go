@@ -0,0 +1,330 @@
+package artifacts
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths read by USBDevices. The Enum paths are relative to a control set of
+// the SYSTEM hive.
+const (
+    USBStorPath                = "Enum\\USBSTOR"
+    USBPath                    = "Enum\\USB"
+    MountedDevicesPath         = "MountedDevices"
+    EMDMgmtPath                = "Microsoft\\Windows NT\\CurrentVersion\\EMDMgmt"
+    WindowsPortableDevicesPath = "Microsoft\\Windows Portable Devices\\Devices"
+    MountPoints2Path           = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\MountPoints2"
+)
+
+// usbDeviceProperties is the property set of the device Keys holding their
+// install and connection times, as FILETIMEs.
+const usbDeviceProperties = "Properties\\{83da6326-97a6-4088-9453-a1923f573b29}"
+
+// USBDevice is a USB device that was connected to a system. Mass storage
+// devices are read from USBSTOR and completed with their USB instance, their
+// volumes and the users who browsed them; other devices only have their USB
+// instance.
+//
+// DriveLetters and Volumes come from MountedDevices, which only keeps the last
+// drive letter given to a device.
+type USBDevice struct {
+    Bus            string    // "USBSTOR" for mass storage devices, "USB" for others
+    Class          string    // name of the Key of the device, such as "Disk&Ven_SanDisk&Prod_Cruzer&Rev_1.26" or "VID_0781&PID_5567"
+    Instance       string    // name of the Key of the instance, usually the serial number followed by "&0" for USBSTOR
+    Serial         string    // serial number of the device, or "" when Windows made one up
+    Vendor         string    // from Class, USBSTOR only
+    Product        string    // from Class, USBSTOR only
+    Revision       string    // from Class, USBSTOR only
+    VID            string    // USB vendor ID, such as "0781"
+    PID            string    // USB product ID
+    FriendlyName   string
+    ContainerID    string
+    FirstInstalled time.Time // first time the device was installed (property 0064)
+    Installed      time.Time // last time its driver was installed (property 0065)
+    LastArrival    time.Time // last time it was connected (property 0066)
+    LastRemoval    time.Time // last time it was removed (property 0067)
+    DriveLetters   []string  // drive letters of the device, such as "E:"
+    Volumes        []string  // GUIDs of the volumes of the device, such as "{2c8a1e39-...}"
+    VolumeSerials  []string  // serial numbers of the volumes of the device, such as "1A2B-3C4D", from EMDMgmt
+    VolumeLabels   []string  // labels of the volumes of the device, from EMDMgmt and Windows Portable Devices
+    Users          []string  // users whose MountPoints2 Key references a volume of the device
+    LastWritten    time.Time // last written time of the Key of the instance
+}
+
+// USBDevices reads the history of the USB devices of a system from its SYSTEM
+// hive, completed with its SOFTWARE hive and the NTUSER.DAT hives of its users
+// by user name, which may all be nil.
+func USBDevices(system, software libregf.Hive, ntusers map[string]libregf.Hive) ([]USBDevice, error) {
+    ccs, err := currentControlSet(system)
+    if err != nil || ccs == "" { return []USBDevice{}, err }
+
+    storage, err := readUSBInstances(system, ccs+"\\"+USBStorPath, "USBSTOR")
+    if err != nil { return nil, err }
+    usb, err := readUSBInstances(system, ccs+"\\"+USBPath, "USB")
+    if err != nil { return nil, err }
+
+    // USB instances of mass storage devices share their serial number
+    bySerial := map[string]*USBDevice{}
+    for i := range usb {
+        if usb[i].Serial != "" { bySerial[strings.ToUpper(usb[i].Serial)] = &usb[i] }
+    }
+    merged := map[*USBDevice]bool{}
+    for i := range storage {
+        d := &storage[i]
+        if u := bySerial[strings.ToUpper(d.Serial)]; u != nil && d.Serial != "" {
+            d.VID, d.PID = u.VID, u.PID
+            if d.FirstInstalled.IsZero() { d.FirstInstalled = u.FirstInstalled }
+            if d.LastArrival.IsZero() { d.LastArrival = u.LastArrival }
+            if d.LastRemoval.IsZero() { d.LastRemoval = u.LastRemoval }
+            merged[u] = true
+        }
+    }
+
+    mounted, err := readMountedDevices(system)
+    if err != nil { return nil, err }
+    for i := range storage {
+        d := &storage[i]
+        id := d.storageID()
+        for _, m := range mounted {
+            if !strings.Contains(m.device, id) { continue }
+            if strings.HasPrefix(m.name, "\\DosDevices\\") {
+                d.DriveLetters = appendUnique(d.DriveLetters, strings.TrimPrefix(m.name, "\\DosDevices\\"))
+            } else if strings.HasPrefix(m.name, "\\??\\Volume") {
+                d.Volumes = appendUnique(d.Volumes, strings.TrimPrefix(m.name, "\\??\\Volume"))
+            }
+        }
+    }
+
+    if software != nil {
+        if err := readUSBVolumes(software, storage); err != nil { return nil, err }
+    }
+
+    if err := readUSBUsers(ntusers, storage); err != nil { return nil, err }
+
+    devices := storage
+    for i := range usb {
+        if !merged[&usb[i]] { devices = append(devices, usb[i]) }
+    }
+
+    return devices, nil
+}
+
+// storageID returns the identifier of a mass storage device as found in
+// MountedDevices, EMDMgmt and Windows Portable Devices, upper cased.
+func (d *USBDevice) storageID() string {
+    return strings.ToUpper("USBSTOR#" + d.Class + "#" + d.Instance + "#")
+}
+
+// readUSBInstances reads the instances of the devices of the Enum Key at path,
+// whose Keys are named after the class of a device for USBSTOR, or its vendor
+// and product IDs for USB.
+func readUSBInstances(system libregf.Hive, path, bus string) ([]USBDevice, error) {
+    devices := []USBDevice{}
+
+    key, err := openKey(system, path)
+    if err != nil || key == nil { return devices, err }
+    defer release(key)
+
+    err = eachSubkey(key, func(class string, classKey libregf.RegistryKey) error {
+        // interfaces of composite devices repeat their parent
+        if bus == "USB" && strings.Contains(strings.ToUpper(class), "&MI_") { return nil }
+
+        return eachSubkey(classKey, func(instance string, instanceKey libregf.RegistryKey) error {
+            d := USBDevice{Bus: bus, Class: class, Instance: instance}
+            d.Serial = usbSerial(instance, bus)
+            d.FriendlyName = libregf.GetOr(instanceKey, "FriendlyName", "")
+            if d.FriendlyName == "" {
+                // such as "@usb.inf,%usb.massstorage.devicedesc%;USB Mass Storage Device"
+                desc := libregf.GetOr(instanceKey, "DeviceDesc", "")
+                d.FriendlyName = desc[strings.LastIndexByte(desc, ';')+1:]
+            }
+            d.ContainerID = libregf.GetOr(instanceKey, "ContainerID", "")
+
+            for _, part := range strings.Split(class, "&") {
+                switch prefix, value, _ := strings.Cut(part, "_"); strings.ToUpper(prefix) {
+                case "VEN":
+                    d.Vendor = value
+                case "PROD":
+                    d.Product = value
+                case "REV":
+                    d.Revision = value
+                case "VID":
+                    d.VID = value
+                case "PID":
+                    d.PID = value
+                }
+            }
+
+            var err error
+            if d.LastWritten, err = lastWritten(instanceKey); err != nil { return err }
+            properties := path + "\\" + class + "\\" + instance + "\\" + usbDeviceProperties
+            for _, p := range []struct {
+                id string
+                t  *time.Time
+            }{
+                {"0064", &d.FirstInstalled},
+                {"0065", &d.Installed},
+                {"0066", &d.LastArrival},
+                {"0067", &d.LastRemoval},
+            } {
+                if *p.t, err = usbPropertyTime(system, properties+"\\"+p.id); err != nil { return err }
+            }
+
+            devices = append(devices, d)
+            return nil
+        })
+    })
+
+    return devices, err
+}
+
+// usbSerial returns the serial number of a device from the name of the Key of
+// its instance. Windows makes one up for devices without a serial number,
+// whose second character is then a '&'; USBSTOR adds a "&0" suffix to serial
+// numbers.
+func usbSerial(instance, bus string) string {
+    if len(instance) > 1 && instance[1] == '&' { return "" }
+    if bus == "USBSTOR" {
+        if i := strings.LastIndexByte(instance, '&'); i > 0 { return instance[:i] }
+    }
+    return instance
+}
+
+// usbPropertyTime reads the FILETIME of a device property, by the path of its
+// Key. Windows 8 and later keep it in the default Value of the Key, Windows 7
+// in the Data Value of its 00000000 sub-Key.
+func usbPropertyTime(system libregf.Hive, path string) (time.Time, error) {
+    for _, p := range []struct{ path, name string }{{path, ""}, {path + "\\00000000", "Data"}} {
+        key, err := openKey(system, p.path)
+        if err != nil { return time.Time{}, err }
+        if key == nil { continue }
+
+        data, err := getBytes(key, p.name)
+        release(key)
+        if err == nil && len(data) >= 8 { return filetimeAt(data, 0), nil }
+    }
+
+    return time.Time{}, nil
+}
+
+// mountedDevice is a Value of the MountedDevices Key: a drive letter or a
+// volume, and the device it is mounted from, upper cased.
+type mountedDevice struct {
+    name, device string
+}
+
+// readMountedDevices reads the Values of the MountedDevices Key of a SYSTEM
+// hive. Fixed disks, whose data is a disk signature and an offset rather than
+// the identifier of a device, are left out.
+func readMountedDevices(system libregf.Hive) ([]mountedDevice, error) {
+    key, err := openKey(system, MountedDevicesPath)
+    if err != nil || key == nil { return nil, err }
+    defer release(key)
+
+    mounted := []mountedDevice{}
+    err = eachValue(key, func(name string, value libregf.RegistryValue) error {
+        data, err := value.Data()
+        if err != nil { return err }
+        if len(data) <= 24 { return nil }
+
+        // such as "_??_USBSTOR#Disk&Ven_SanDisk&Prod_Cruzer&Rev_1.26#4C530001&0#{53f56307-...}"
+        device, _ := utf16At(data, 0)
+        mounted = append(mounted, mountedDevice{name: name, device: strings.ToUpper(device)})
+        return nil
+    })
+
+    return mounted, err
+}
+
+// readUSBVolumes adds the serial numbers and labels of the volumes of storage
+// devices, from the EMDMgmt (ReadyBoost) and Windows Portable Devices Keys of
+// a SOFTWARE hive.
+func readUSBVolumes(software libregf.Hive, devices []USBDevice) error {
+    key, err := openKey(software, EMDMgmtPath)
+    if err != nil { return err }
+    if key != nil {
+        // Keys are named after the device, followed by the label and the
+        // serial number of the volume, in decimal
+        err = eachSubkey(key, func(name string, _ libregf.RegistryKey) error {
+            upper := strings.ToUpper(name)
+            i := strings.LastIndexByte(name, '}')
+            j := strings.LastIndexByte(name, '_')
+            for k := range devices {
+                d := &devices[k]
+                if !strings.Contains(upper, d.storageID()) { continue }
+                if i < 0 || j < i { continue }
+
+                if label := name[i+1 : j]; label != "" { d.VolumeLabels = appendUnique(d.VolumeLabels, label) }
+                if serial, err := strconv.ParseUint(name[j+1:], 10, 32); err == nil {
+                    d.VolumeSerials = appendUnique(d.VolumeSerials, fmt.Sprintf("%04X-%04X", serial>>16, serial&0xffff))
+                }
+            }
+            return nil
+        })
+        release(key)
+        if err != nil { return err }
+    }
+
+    key, err = openKey(software, WindowsPortableDevicesPath)
+    if err != nil || key == nil { return err }
+    defer release(key)
+
+    return eachSubkey(key, func(name string, wpd libregf.RegistryKey) error {
+        upper := strings.ToUpper(name)
+        label := libregf.GetOr(wpd, "FriendlyName", "")
+        if label == "" { return nil }
+
+        for k := range devices {
+            d := &devices[k]
+            if strings.Contains(upper, d.storageID()) { d.VolumeLabels = appendUnique(d.VolumeLabels, label) }
+        }
+        return nil
+    })
+}
+
+// readUSBUsers adds the users whose MountPoints2 Key, in their NTUSER.DAT
+// hive, has a sub-Key named after a volume of a device.
+func readUSBUsers(ntusers map[string]libregf.Hive, devices []USBDevice) error {
+    users := make([]string, 0, len(ntusers))
+    for user := range ntusers {
+        users = append(users, user)
+    }
+    sort.Strings(users)
+
+    for _, user := range users {
+        if ntusers[user] == nil { continue }
+        key, err := openKey(ntusers[user], MountPoints2Path)
+        if err != nil { return err }
+        if key == nil { continue }
+
+        volumes := map[string]bool{}
+        err = eachSubkey(key, func(name string, _ libregf.RegistryKey) error {
+            volumes[strings.ToUpper(name)] = true
+            return nil
+        })
+        release(key)
+        if err != nil { return err }
+
+        for k := range devices {
+            d := &devices[k]
+            for _, volume := range d.Volumes {
+                if volumes[strings.ToUpper(volume)] { d.Users = appendUnique(d.Users, user) }
+            }
+        }
+    }
+
+    return nil
+}
+
+// appendUnique appends s to list unless it already holds it.
+func appendUnique(list []string, s string) []string {
+    for _, l := range list {
+        if l == s { return list }
+    }
+    return append(list, s)
+}
@@ -0,0 +1,104 @@
+package artifacts_test
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+func TestUSBDevices(t *testing.T) {
+    const (
+        class    = "Disk&Ven_SanDisk&Prod_Cruzer&Rev_1.26"
+        instance = "4C530001&0"
+        device   = "USBSTOR#" + class + "#" + instance + "#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}"
+        volume   = "{2c8a1e39-0000-11ef-a000-806e6f6e6963}"
+        props    = "\\Properties\\{83da6326-97a6-4088-9453-a1923f573b29}\\"
+    )
+    lw := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+    first := time.Date(2024, 8, 1, 9, 0, 0, 0, time.UTC)
+    usbFirst := time.Date(2024, 8, 1, 8, 59, 58, 0, time.UTC)
+    arrival := time.Date(2024, 9, 1, 11, 0, 0, 0, time.UTC)
+    removal := time.Date(2024, 9, 1, 11, 30, 0, 0, time.UTC)
+    property := func(t time.Time) []regftest.Value {
+        return []regftest.Value{{Name: "", Type: "REG_BINARY", Data: le.AppendUint64(nil, filetime(t))}}
+    }
+    mounted := func(name string, data []byte) regftest.Value {
+        return regftest.Value{Name: name, Type: "REG_BINARY", Data: data}
+    }
+
+    storage := "ControlSet001\\Enum\\USBSTOR\\" + class + "\\" + instance
+    usb := "ControlSet001\\Enum\\USB\\VID_0781&PID_5567\\4C530001"
+    system := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 1}}},
+        {Path: storage, LastWritten: lw, Values: []regftest.Value{
+            {Name: "FriendlyName", Type: "REG_SZ", String: "SanDisk Cruzer USB Device"},
+            {Name: "ContainerID", Type: "REG_SZ", String: "{a5f0e3c2-1111-2222-3333-444455556666}"},
+        }},
+        // Windows 7 keeps property times in a 00000000 sub-Key
+        {Path: storage + props + "0064\\00000000", Values: []regftest.Value{{Name: "Data", Type: "REG_BINARY", Data: le.AppendUint64(nil, filetime(first))}}},
+        {Path: storage + props + "0066", Values: property(arrival)},
+        {Path: usb, LastWritten: lw, Values: []regftest.Value{{Name: "DeviceDesc", Type: "REG_SZ", String: "@usb.inf,%usb.massstorage.devicedesc%;USB Mass Storage Device"}}},
+        {Path: usb + props + "0064", Values: property(usbFirst)},
+        {Path: usb + props + "0067", Values: property(removal)},
+        {Path: "ControlSet001\\Enum\\USB\\VID_046D&PID_C52B\\6&2a5e2c1&0&2", LastWritten: lw, Values: []regftest.Value{{Name: "DeviceDesc", Type: "REG_SZ", String: "@input.inf,%hid.devicedesc%;USB Input Device"}}},
+        {Path: "ControlSet001\\Enum\\USB\\VID_046D&PID_C52B&MI_00\\7&1b2c3d4&0&0000"},
+        {Path: "ControlSet002\\Enum\\USBSTOR\\Disk&Ven_Old&Prod_Stale&Rev_1.00\\0001&0"},
+        {Path: artifacts.MountedDevicesPath, Values: []regftest.Value{
+            mounted("\\DosDevices\\C:", []byte{0x4d, 0x3c, 0x2b, 0x1a, 0, 0, 0x10, 0, 0, 0, 0, 0}),
+            mounted("\\DosDevices\\E:", utf16z("_??_"+device)),
+            mounted("\\??\\Volume"+volume, utf16z("_??_"+device)),
+        }},
+    }})
+    software := openHive(t, &regftest.Hive{Keys: []regftest.Key{
+        {Path: artifacts.EMDMgmtPath + "\\_??_" + device + "KINGSTON_439041101"},
+        {Path: artifacts.EMDMgmtPath + "\\_??_USBSTOR#Disk&Ven_Other&Prod_Stick&Rev_1.00#0002&0#{53f56307-b6bf-11d0-94f2-00a0c91efb8b}OTHER_1"},
+        {Path: artifacts.WindowsPortableDevicesPath + "\\SWD#WPDBUSENUM#_??_USBSTOR#DISK&VEN_SANDISK&PROD_CRUZER&REV_1.26#4C530001&0#{53F56307-B6BF-11D0-94F2-00A0C91EFB8B}", Values: []regftest.Value{
+            {Name: "FriendlyName", Type: "REG_SZ", String: "BACKUP"},
+        }},
+    }})
+    ntusers := map[string]libregf.Hive{
+        "bob":   openHive(t, &regftest.Hive{Keys: []regftest.Key{{Path: artifacts.MountPoints2Path + "\\" + volume}}}),
+        "alice": openHive(t, &regftest.Hive{Keys: []regftest.Key{{Path: artifacts.MountPoints2Path + "\\{00000000-0000-0000-0000-000000000000}"}}}),
+        "carol": nil,
+        "dave":  openHive(t, &regftest.Hive{Keys: []regftest.Key{{Path: artifacts.MountPoints2Path + "\\" + "{2C8A1E39-0000-11EF-A000-806E6F6E6963}"}}}),
+    }
+
+    devices, err := artifacts.USBDevices(system, software, ntusers)
+    if err != nil { t.Fatal(err) }
+    if len(devices) != 2 { t.Fatalf("got %d devices, want 2: %+v", len(devices), devices) }
+
+    d := devices[0]
+    if d.Bus != "USBSTOR" || d.Class != class || d.Instance != instance || d.Serial != "4C530001" { t.Errorf("USBSTOR: got %+v", d) }
+    if d.Vendor != "SanDisk" || d.Product != "Cruzer" || d.Revision != "1.26" || d.VID != "0781" || d.PID != "5567" { t.Errorf("USBSTOR: got IDs %+v", d) }
+    if d.FriendlyName != "SanDisk Cruzer USB Device" || d.ContainerID != "{a5f0e3c2-1111-2222-3333-444455556666}" { t.Errorf("USBSTOR: got %+v", d) }
+    // times missing from USBSTOR come from the USB instance
+    if !d.FirstInstalled.Equal(first) || !d.Installed.IsZero() || !d.LastArrival.Equal(arrival) || !d.LastRemoval.Equal(removal) || !d.LastWritten.Equal(lw) {
+        t.Errorf("USBSTOR: got times %v, %v, %v, %v, %v", d.FirstInstalled, d.Installed, d.LastArrival, d.LastRemoval, d.LastWritten)
+    }
+    for _, c := range []struct {
+        name      string
+        got, want []string
+    }{
+        {"DriveLetters", d.DriveLetters, []string{"E:"}},
+        {"Volumes", d.Volumes, []string{volume}},
+        {"VolumeSerials", d.VolumeSerials, []string{"1A2B-3C4D"}},
+        {"VolumeLabels", d.VolumeLabels, []string{"KINGSTON", "BACKUP"}},
+        {"Users", d.Users, []string{"bob", "dave"}},
+    } {
+        if !reflect.DeepEqual(c.got, c.want) { t.Errorf("%s: got %q, want %q", c.name, c.got, c.want) }
+    }
+
+    // devices without a serial number aren't matched, and interfaces are left out
+    d = devices[1]
+    if d.Bus != "USB" || d.Class != "VID_046D&PID_C52B" || d.Serial != "" || d.VID != "046D" || d.PID != "C52B" || d.FriendlyName != "USB Input Device" || d.DriveLetters != nil {
+        t.Errorf("USB: got %+v", d)
+    }
+
+    // without SOFTWARE and NTUSER.DAT hives, devices still come with their drive letters
+    devices, err = artifacts.USBDevices(system, nil, nil)
+    if err != nil || len(devices) != 2 || devices[0].VolumeLabels != nil || devices[0].Users != nil || len(devices[0].DriveLetters) != 1 { t.Errorf("SYSTEM only: got %+v, %v", devices, err) }
+}
@@ -0,0 +1,137 @@
+package artifacts
+
+import (
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// UserAssistPath is the path of the UserAssist Key inside an NTUSER.DAT hive.
+const UserAssistPath = "Software\\Microsoft\\Windows\\CurrentVersion\\Explorer\\UserAssist"
+
+// GUIDs of the well known UserAssist Keys.
+const (
+    UserAssistExecutables   = "{CEBFF5CD-ACE2-4F4F-9178-9926F41749EA}" // programs run, Windows 7 and later
+    UserAssistShortcuts     = "{F4E57C4B-2036-45F0-A9AB-443BCFE33D9F}" // shortcuts run, Windows 7 and later
+    UserAssistActiveDesktop = "{75048700-EF1F-11D0-9888-006097DEACF9}" // Windows XP and Vista
+    UserAssistToolbar       = "{5E6AB780-7743-11CF-A12B-00AA004AE837}" // Windows XP and Vista
+)
+
+// Sizes of the data of UserAssist Values.
+const (
+    userAssistXPSize   = 16 // Windows XP and Vista
+    userAssistWin7Size = 72 // Windows 7 and later
+)
+
+// UserAssistEntry is a program, shortcut or control panel item that a user ran
+// from Explorer, as counted by UserAssist.
+type UserAssistEntry struct {
+    GUID         string        // GUID of the UserAssist Key holding the entry
+    Name         string        // name of the Value, decoded from ROT13
+    Path         string        // Name, with its known folder GUID replaced by the path of the folder
+    Session      uint32        // session the entry was last updated in
+    RunCount     uint32        // number of times it was run
+    FocusCount   uint32        // number of times it got the focus, Windows 7 and later
+    FocusTime    time.Duration // how long it had the focus, Windows 7 and later
+    LastExecuted time.Time     // when it was last run, if known
+    LastWritten  time.Time     // last written time of the Count Key
+    Err          error         // set when the data of the Value couldn't be read, leaving the counters zero
+}
+
+// UserAssist decodes the entries of every UserAssist Key of an NTUSER.DAT
+// hive, for both the Windows XP and the Windows 7 and later layouts.
+// Known folder GUIDs in paths are expanded by env unless env is nil; see
+// ResolveKnownFolder. Entries whose data can't be read are still returned,
+// with their Err field set.
+func UserAssist(ntuser libregf.Hive, env *libregf.Environment) ([]UserAssistEntry, error) {
+    entries := []UserAssistEntry{}
+
+    key, err := openKey(ntuser, UserAssistPath)
+    if err != nil || key == nil { return entries, err }
+    defer release(key)
+
+    err = eachSubkey(key, func(guid string, subkey libregf.RegistryKey) error {
+        count, err := subkey.GetSubkey("Count")
+        if errors.Is(err, libregf.ErrNotFound) { return nil }
+        if err != nil { return err }
+        defer release(count)
+
+        lw, err := lastWritten(count)
+        if err != nil { return err }
+
+        return eachValue(count, func(name string, value libregf.RegistryValue) error {
+            name = ROT13(name)
+            if isUserAssistControl(name) { return nil }
+
+            var entry UserAssistEntry
+            if data, err := value.Data(); err == nil {
+                entry = decodeUserAssist(data)
+            } else {
+                entry.Err = err
+            }
+            entry.GUID = guid
+            entry.Name = name
+            entry.Path = ResolveKnownFolder(userAssistPath(name), env)
+            entry.LastWritten = lw
+            entries = append(entries, entry)
+            return nil
+        })
+    })
+
+    return entries, err
+}
+
+// decodeUserAssist decodes the data of a UserAssist Value, telling the layouts
+// apart by their sizes. Data of other sizes leaves the counters at zero.
+func decodeUserAssist(data []byte) UserAssistEntry {
+    var entry UserAssistEntry
+
+    switch {
+    case len(data) >= userAssistWin7Size:
+        entry.Session = le.Uint32(data[0:])
+        entry.RunCount = le.Uint32(data[4:])
+        entry.FocusCount = le.Uint32(data[8:])
+        entry.FocusTime = time.Duration(le.Uint32(data[12:])) * time.Millisecond
+        entry.LastExecuted = filetimeAt(data, 60)
+    case len(data) >= userAssistXPSize:
+        entry.Session = le.Uint32(data[0:])
+        // Windows XP starts counting at 5
+        entry.RunCount = le.Uint32(data[4:])
+        if entry.RunCount >= 5 { entry.RunCount -= 5 }
+        entry.LastExecuted = filetimeAt(data, 8)
+    }
+
+    return entry
+}
+
+// isUserAssistControl tells whether a decoded Value name is one of the Values
+// UserAssist keeps for itself, rather than an entry.
+func isUserAssistControl(name string) bool {
+    return name == "UEME_CTLSESSION" || strings.HasPrefix(name, "UEME_CTLCUACount")
+}
+
+// userAssistPath strips the kind of entry that Windows XP and Vista prefix
+// names with, as in "UEME_RUNPATH:C:\Windows\notepad.exe".
+func userAssistPath(name string) string {
+    if strings.HasPrefix(name, "UEME_") {
+        if i := strings.IndexByte(name, ':'); i >= 0 { return name[i+1:] }
+    }
+    return name
+}
+
+// ROT13 decodes, or encodes, a string the way UserAssist obfuscates the names
+// of its Values: ASCII letters are rotated by 13 places, other characters are
+// left alone.
+func ROT13(s string) string {
+    return strings.Map(func(r rune) rune {
+        switch {
+        case r >= 'a' && r <= 'z':
+            return 'a' + (r-'a'+13)%26
+        case r >= 'A' && r <= 'Z':
+            return 'A' + (r-'A'+13)%26
+        }
+        return r
+    }, s)
+}
@@ -0,0 +1,116 @@
+package artifacts_test
+
+import (
+    "encoding/binary"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/artifacts"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// filetime returns the FILETIME of t.
+func filetime(t time.Time) uint64 {
+    return uint64(t.Unix()+11644473600)*1e7 + uint64(t.Nanosecond()/100)
+}
+
+// userAssistWin7 returns the 72 bytes of a UserAssist Value of Windows 7 and later.
+func userAssistWin7(session, runs, focuses uint32, focusTime time.Duration, ft uint64) []byte {
+    data := make([]byte, 72)
+    binary.LittleEndian.PutUint32(data[0:], session)
+    binary.LittleEndian.PutUint32(data[4:], runs)
+    binary.LittleEndian.PutUint32(data[8:], focuses)
+    binary.LittleEndian.PutUint32(data[12:], uint32(focusTime/time.Millisecond))
+    binary.LittleEndian.PutUint64(data[60:], ft)
+    return data
+}
+
+// userAssistXP returns the 16 bytes of a UserAssist Value of Windows XP, whose
+// run count starts at 5.
+func userAssistXP(session, runs uint32, ft uint64) []byte {
+    data := make([]byte, 16)
+    binary.LittleEndian.PutUint32(data[0:], session)
+    binary.LittleEndian.PutUint32(data[4:], runs)
+    binary.LittleEndian.PutUint64(data[8:], ft)
+    return data
+}
+
+func TestUserAssist(t *testing.T) {
+    lw := time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC)
+    run := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+    value := func(name string, data []byte) regftest.Value {
+        return regftest.Value{Name: artifacts.ROT13(name), Type: "REG_BINARY", Data: data}
+    }
+    ntuser := openHive(t, &regftest.Hive{
+        Keys: []regftest.Key{
+            {Path: artifacts.UserAssistPath + "\\" + artifacts.UserAssistExecutables + "\\Count", LastWritten: lw, Values: []regftest.Value{
+                value("{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\\cmd.exe", userAssistWin7(3, 7, 2, 1500*time.Millisecond, filetime(run))),
+                value("Microsoft.Windows.Explorer", userAssistWin7(1, 1, 0, 0, 0xffffffffffffffff)),
+                value("UEME_CTLSESSION", make([]byte, 1612)),
+                value("Short", []byte{1, 2, 3, 4}),
+                value("Broken", userAssistWin7(1, 1, 1, 0, 0)),
+            }},
+            {Path: artifacts.UserAssistPath + "\\" + artifacts.UserAssistActiveDesktop + "\\Count", LastWritten: lw, Values: []regftest.Value{
+                value("UEME_RUNPATH:C:\\Windows\\notepad.exe", userAssistXP(2, 12, filetime(run))),
+                value("UEME_RUNPIDL:%csidl2%\\Accessories", userAssistXP(2, 3, 0)),
+                value("UEME_CTLCUACount:ctor", userAssistXP(0, 0, 0)),
+            }},
+            {Path: artifacts.UserAssistPath + "\\" + artifacts.UserAssistToolbar},
+        },
+        Corruptions: []regftest.Corruption{{Path: artifacts.UserAssistPath + "\\" + artifacts.UserAssistExecutables + "\\Count", Value: artifacts.ROT13("Broken"), Kind: regftest.BadDataOffset}},
+    })
+
+    entries, err := artifacts.UserAssist(ntuser, libregf.NewEnvironment(map[string]string{"SystemRoot": "C:\\Windows"}))
+    if err != nil { t.Fatal(err) }
+
+    byName := map[string]artifacts.UserAssistEntry{}
+    for _, e := range entries {
+        byName[e.Name] = e
+    }
+    if len(entries) != 6 { t.Errorf("got %d entries, want 6: %+v", len(entries), entries) }
+    for _, name := range []string{"UEME_CTLSESSION", "UEME_CTLCUACount:ctor"} {
+        if _, ok := byName[name]; ok { t.Errorf("%s: the control Value was taken for an entry", name) }
+    }
+
+    e := byName["{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\\cmd.exe"]
+    if e.GUID != artifacts.UserAssistExecutables || e.Path != "C:\\Windows\\System32\\cmd.exe" || e.Err != nil { t.Errorf("cmd.exe: got %+v", e) }
+    if e.Session != 3 || e.RunCount != 7 || e.FocusCount != 2 || e.FocusTime != 1500*time.Millisecond { t.Errorf("cmd.exe: got counters %+v", e) }
+    if !e.LastExecuted.Equal(run) || !e.LastWritten.Equal(lw) { t.Errorf("cmd.exe: got times %v, %v", e.LastExecuted, e.LastWritten) }
+
+    // garbage FILETIMEs give their real date, not a plausible one
+    if e := byName["Microsoft.Windows.Explorer"]; e.LastExecuted.Year() != 60056 { t.Errorf("out of range FILETIME: got %v", e.LastExecuted) }
+
+    if e := byName["Short"]; e.RunCount != 0 || !e.LastExecuted.IsZero() || e.Err != nil { t.Errorf("Short: got %+v", e) }
+    if e := byName["Broken"]; e.Err == nil || e.RunCount != 0 { t.Errorf("Broken: got %+v, want Err set", e) }
+
+    e = byName["UEME_RUNPATH:C:\\Windows\\notepad.exe"]
+    if e.GUID != artifacts.UserAssistActiveDesktop || e.Path != "C:\\Windows\\notepad.exe" { t.Errorf("notepad.exe: got %+v", e) }
+    if e.Session != 2 || e.RunCount != 7 || e.FocusCount != 0 || !e.LastExecuted.Equal(run) { t.Errorf("notepad.exe: got counters %+v", e) }
+    if e := byName["UEME_RUNPIDL:%csidl2%\\Accessories"]; e.RunCount != 3 || e.Path != "%csidl2%\\Accessories" { t.Errorf("RUNPIDL: got %+v", e) }
+}
+
+func TestROT13(t *testing.T) {
+    for s, want := range map[string]string{
+        "HRZR_EHACNGU:P:\\Jvaqbjf": "UEME_RUNPATH:C:\\Windows",
+        "{1NP14R77-02R7}\\pzq.rkr": "{1AC14E77-02E7}\\cmd.exe",
+        "Zürich 42":                "Müevpu 42",
+    } {
+        if got := artifacts.ROT13(s); got != want { t.Errorf("ROT13(%q): got %q, want %q", s, got, want) }
+        if got := artifacts.ROT13(want); got != s { t.Errorf("ROT13(%q): got %q, want %q", want, got, s) }
+    }
+}
+
+func TestResolveKnownFolder(t *testing.T) {
+    env := libregf.NewEnvironment(map[string]string{"SystemRoot": "C:\\Windows", "ProgramFiles(x86)": "C:\\Program Files (x86)"})
+    for path, want := range map[string]string{
+        "{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\\cmd.exe":    "C:\\Windows\\System32\\cmd.exe",
+        "{7c5a40ef-a0fb-4bfc-874a-c0f2e0b9fa8e}\\App\\a.exe": "C:\\Program Files (x86)\\App\\a.exe",
+        "{00000000-0000-0000-0000-000000000000}\\x.exe":      "{00000000-0000-0000-0000-000000000000}\\x.exe",
+        "C:\\Windows\\notepad.exe":                           "C:\\Windows\\notepad.exe",
+        "{short}":                                            "{short}",
+    } {
+        if got := artifacts.ResolveKnownFolder(path, env); got != want { t.Errorf("ResolveKnownFolder(%q): got %q, want %q", path, got, want) }
+    }
+    if got := artifacts.ResolveKnownFolder("{F38BF404-1D43-42F2-9305-67DE0B28FC23}\\x", nil); got != "%SystemRoot%\\x" { t.Errorf("without an Environment: got %q", got) }
+}
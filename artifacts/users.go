@@ -0,0 +1,124 @@
+package artifacts
+
+import (
+    "bytes"
+    "fmt"
+    "strings"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// Paths read by ReadUserNames.
+const (
+    ProfileListPath  = "Microsoft\\Windows NT\\CurrentVersion\\ProfileList"
+    SAMAccountPath   = "SAM\\Domains\\Account"
+    SAMUserNamesPath = "SAM\\Domains\\Account\\Users\\Names"
+)
+
+// UserNames maps security identifiers (SIDs), such as
+// "S-1-5-21-1004336348-1177238915-682003330-1001", to user names.
+type UserNames map[string]string
+
+// wellKnownUsers are the accounts that Windows runs services as.
+var wellKnownUsers = map[string]string{
+    "S-1-5-18": "SYSTEM",
+    "S-1-5-19": "LOCAL SERVICE",
+    "S-1-5-20": "NETWORK SERVICE",
+}
+
+// ReadUserNames reads the names of the users of a system from its SAM and
+// SOFTWARE hives, either of which may be nil. The SAM hive names the local
+// accounts, while the ProfileList of the SOFTWARE hive names every user who
+// logged on, domain users included, after their profile directory.
+func ReadUserNames(sam, software libregf.Hive) (UserNames, error) {
+    names := UserNames{}
+    for sid, name := range wellKnownUsers {
+        names[sid] = name
+    }
+
+    if software != nil {
+        key, err := openKey(software, ProfileListPath)
+        if err != nil { return nil, err }
+        if key != nil {
+            err = eachSubkey(key, func(sid string, profile libregf.RegistryKey) error {
+                path := libregf.GetOr(profile, "ProfileImagePath", "")
+                if i := strings.LastIndexByte(path, '\\'); i >= 0 && i+1 < len(path) { names[sid] = path[i+1:] }
+                return nil
+            })
+            release(key)
+            if err != nil { return nil, err }
+        }
+    }
+
+    if sam != nil {
+        domain, err := samDomainSID(sam)
+        if err != nil { return nil, err }
+        key, err := openKey(sam, SAMUserNamesPath)
+        if err != nil { return nil, err }
+        if domain != "" && key != nil {
+            err = eachSubkey(key, func(name string, user libregf.RegistryKey) error {
+                // the type of the default Value of the Key of a user is its RID
+                value, err := user.GetValue("")
+                if err != nil { return nil }
+                rid, err := value.Type()
+                release(value)
+                if err != nil { return err }
+
+                names[fmt.Sprintf("%s-%d", domain, uint32(rid))] = name
+                return nil
+            })
+        }
+        if key != nil { release(key) }
+        if err != nil { return nil, err }
+    }
+
+    return names, nil
+}
+
+// Lookup returns the name of the user whose SID is sid, or "" when unknown.
+// It is safe to call on nil UserNames.
+func (names UserNames) Lookup(sid string) string {
+    return names[strings.ToUpper(sid)]
+}
+
+// samDomainSID returns the SID of the machine, which prefixes the SIDs of its
+// local accounts, from the V Value of the Account Key of a SAM hive. It gives
+// "" when the hive has no such Value.
+func samDomainSID(sam libregf.Hive) (string, error) {
+    key, err := openKey(sam, SAMAccountPath)
+    if err != nil || key == nil { return "", err }
+    defer release(key)
+
+    v, err := getBytes(key, "V")
+    if err != nil { return "", nil }
+
+    // the V Value ends with the SID, whose 3 sub authorities follow 21 (NT non unique)
+    prefix := []byte{1, 4, 0, 0, 0, 0, 0, 5, 21, 0, 0, 0}
+    i := bytes.LastIndex(v, prefix)
+    if i < 0 { return "", nil }
+    sid, _ := parseSID(v[i:])
+    return sid, nil
+}
+
+// parseSID decodes a binary SID, returning it in its string form along with
+// its size, or "" when data is too short.
+func parseSID(data []byte) (string, int) {
+    if len(data) < 8 { return "", 0 }
+
+    n := int(data[1])
+    size := 8 + 4*n
+    if len(data) < size { return "", 0 }
+
+    var authority uint64
+    for _, b := range data[2:8] {
+        authority = authority<<8 | uint64(b)
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, "S-%d-%d", data[0], authority)
+    for i := 0; i < n; i++ {
+        fmt.Fprintf(&b, "-%d", le.Uint32(data[8+4*i:]))
+    }
+
+    return b.String(), size
+}
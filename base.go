@@ -1,3 +1,5 @@
+//go:build cgo && !purego
+
 package libregf
 
 /*
@@ -21,6 +23,44 @@ func Version() string {
     return C.GoString(cVersion)
 }
 
+// Codepage returns the codepage libregf uses for narrow strings, such as the
+// paths of files. 0 means UTF-8.
+// It wraps libregf_get_codepage().
+func Codepage() (int, error) {
+    var codepage C.int
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_get_codepage(&codepage, (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(codepage), nil
+    }
+}
+
+// SetCodepage sets the codepage libregf uses for narrow strings, such as the
+// paths of files. It doesn't apply to the names inside registry files: use
+// WithCodepage or (*File).SetCodepage for those.
+// It wraps libregf_set_codepage().
+func SetCodepage(codepage int) error {
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_set_codepage(C.int(codepage), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return fmt.Errorf("%s", pe.String())
+    } else {
+        return nil
+    }
+}
+
 // String returns the string representation of an error.
 // It wraps libregf_error_sprint().
 func (err *Error) String() string {
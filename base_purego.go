@@ -0,0 +1,47 @@
+//go:build !cgo || purego
+
+package libregf
+
+import "sync/atomic"
+
+// Error describes a failure of the pure Go backend. It only exists so that
+// code written against the libregf backend keeps compiling.
+type Error struct {
+    msg string
+}
+
+// Version returns the version of the pure Go backend, which replaces the
+// libregf C library when cgo is disabled or the purego build tag is set.
+func Version() string {
+    return "purego"
+}
+
+// narrowCodepage is the codepage of narrow strings, as set by SetCodepage.
+// It is atomic since Files may be used from several goroutines.
+var narrowCodepage atomic.Int32
+
+// Codepage returns the codepage set by SetCodepage. 0 means UTF-8.
+func Codepage() (int, error) {
+    return int(narrowCodepage.Load()), nil
+}
+
+// SetCodepage exists for parity with the libregf backend, where it sets the
+// codepage of narrow strings such as the paths of files. Paths are Go strings
+// in the pure Go backend, so it has no effect. It doesn't apply to the names
+// inside registry files: use WithCodepage or (*File).SetCodepage for those.
+func SetCodepage(cp int) error {
+    if _, ok := codepages[cp]; !ok && cp != 0 { return regfError("unsupported codepage %d", cp) }
+
+    narrowCodepage.Store(int32(cp))
+    return nil
+}
+
+// String returns the string representation of an error.
+func (err *Error) String() string {
+    if err == nil { return "!!! Can't describe error !!!" }
+    return "libregf error: " + err.msg
+}
+
+// Free does nothing, since there is no memory allocated in C to free.
+func (err *Error) Free() {
+}
@@ -0,0 +1,40 @@
+package libregf_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// "Zürich" is stored compressed, with "ü" as the byte 0xfc.
+func TestWithCodepage(t *testing.T) {
+    for codepage, want := range map[int]string{
+        libregf.CodepageWindows1252: "Zürich",
+        libregf.CodepageWindows1251: "Zьrich",
+        libregf.CodepageASCII:       "Z�rich",
+    } {
+        file := openHive(t, regftest.Standard(), libregf.WithCodepage(codepage))
+        key, err := file.Key("Names")
+        if err != nil { t.Fatal(err) }
+        subkey, err := key.SubkeyAt(0)
+        if err != nil { t.Fatal(err) }
+
+        if name, err := subkey.Name(); err != nil || name != want { t.Errorf("codepage %d: got %q, %v, want %q", codepage, name, err, want) }
+        subkey.Free()
+        key.Free()
+    }
+}
+
+func TestWithDoubleByteCodepage(t *testing.T) {
+    if libregf.Version() != "purego" { t.Skip("the libregf backend supports double byte codepages") }
+
+    path, err := regftest.Standard().TempFile(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    for _, codepage := range []int{libregf.CodepageWindows932, libregf.CodepageWindows936, libregf.CodepageWindows949, libregf.CodepageWindows950} {
+        if file, err := libregf.OpenFile(path, libregf.WithCodepage(codepage)); err == nil {
+            file.Close()
+            t.Errorf("codepage %d: OpenFile succeeded", codepage)
+        }
+    }
+}
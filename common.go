@@ -0,0 +1,167 @@
+package libregf
+
+import (
+    "context"
+    "fmt"
+    "strings"
+)
+
+// Value types, as returned by (*Value).Type().
+// They match the LIBREGF_VALUE_TYPE_* constants of libregf, which are in turn
+// the REG_* constants of the Windows API.
+const (
+    ValueTypeUndefined                  = 0
+    ValueTypeString                     = 1
+    ValueTypeExpandableString           = 2
+    ValueTypeBinaryData                 = 3
+    ValueTypeInteger32BitLittleEndian   = 4
+    ValueTypeInteger32BitBigEndian      = 5
+    ValueTypeSymbolicLink               = 6
+    ValueTypeMultiValueString           = 7
+    ValueTypeResourceList               = 8
+    ValueTypeFullResourceDescriptor     = 9
+    ValueTypeResourceRequirementsList   = 10
+    ValueTypeInteger64BitLittleEndian   = 11
+)
+
+// OpenFile opens a registry file by its path, configured by opts.
+func OpenFile(path string, opts ...Option) (*File, error) {
+    return OpenFileContext(context.Background(), path, opts...)
+}
+
+// aborter is implemented by Hives whose long running operations can be
+// interrupted, such as a File.
+type aborter interface {
+    SignalAbort() error
+}
+
+// abortOnDone calls SignalAbort() on a once ctx is done. The returned
+// function must be called when the guarded operation is over; it waits for the
+// watching goroutine to exit, so no abort can be signalled after it returns.
+//
+// libregf never clears an abort request, so this is only meant for operations
+// whose File is discarded when ctx is cancelled, such as OpenFileContext.
+func abortOnDone(ctx context.Context, a aborter) func() {
+    if ctx.Done() == nil { return func() {} }
+
+    done := make(chan struct{})
+    exited := make(chan struct{})
+    go func() {
+        defer close(exited)
+        select {
+        case <-ctx.Done():
+            a.SignalAbort()
+        case <-done:
+        }
+    }()
+
+    return func() {
+        close(done)
+        <-exited
+    }
+}
+
+// ctxErr returns ctx.Err() instead of err once ctx is cancelled, as errors
+// met then are most likely caused by the cancellation.
+func ctxErr(ctx context.Context, err error) error {
+    if err != nil && ctx.Err() != nil { return ctx.Err() }
+    return err
+}
+
+// Value returns the string representation of a "value of a Value" by its path inside the registry.
+// It does so by considering that the last part of the path if the Value's name.
+// This is a quick way to get a displayable value for a full registry path in one call.
+func (file *File) Value(path string) (string, error) { 
+    return HiveValue(file, path)
+}
+
+// HiveValue is File.Value for any Hive.
+func HiveValue(h Hive, path string) (string, error) {
+    key, value, err := lookupValue(h, path)
+    if err != nil { return "", err }
+    defer release(key)
+    defer release(value)
+    s, err := FormatValue(value)
+    if err != nil { return "", err }
+
+    return s, nil
+}
+
+// String returns any possible value as a string. These results may be
+// truncated depending on the type and size of the underlying value.
+func (value *Value) String() (string, error) {
+    return FormatValue(value)
+}
+
+// FormatValue is Value.String for any RegistryValue.
+func FormatValue(value RegistryValue) (string, error) {
+    _type, err := value.Type()
+    if err != nil { return "", err }
+
+    switch _type {
+    case ValueTypeString:
+        tstr, err := value.TString()
+        if err != nil { return "", err }
+        return tstr, nil
+    case ValueTypeExpandableString:
+        tstr, err := value.TString()
+        if err != nil { return "", err }
+        return tstr, nil
+    case ValueTypeMultiValueString:
+        strs, err := value.TStrings()
+        if err != nil { return "", err }
+        l := len(strs)
+        extra := ""
+        if l > 4 {
+            l = 4
+            extra = "…"
+        }
+        return strings.Join(strs[:l], ", ") + extra, nil
+    case ValueTypeBinaryData:
+        tbin, err := value.TBinary()
+        if err != nil { return "", err }
+        l := len(tbin)
+        extra := ""
+        if l > 40 {
+            l = 40
+            extra = "…"
+        }
+        return fmt.Sprintf("%x", tbin[:l]) + extra, nil
+    case ValueTypeInteger32BitLittleEndian:
+        i, err := value.Tint32()
+        if err != nil { return "", err }
+        return fmt.Sprintf("%d", i), nil
+    case ValueTypeInteger64BitLittleEndian:
+        i, err := value.Tint64()
+        if err != nil { return "", err }
+        return fmt.Sprintf("%d", i), nil
+    default:
+        return fmt.Sprintf("[Please implement value type %d]", _type), nil
+    }
+}
+
+// TStrings returns a value of type LIBREGF_VALUE_TYPE_MULTI_VALUE_STRING as a
+// []string, taking care of the MultiString in between.
+func (value *Value) TStrings() ([]string, error) {
+    ms, err := value.TMultiString()
+    if err != nil { return []string{}, err }
+    defer ms.Free()
+
+    return ms.Strings()
+}
+
+// Strings returns all of the strings inside a MultiString as a []string.
+func (ms *MultiString) Strings() ([]string, error) {
+    slen, err := ms.StringsLen()
+    if err != nil { return []string{}, err }
+
+    strs := make([]string, slen)
+    for i := 0; i < slen; i++ {
+        s, err := ms.StringAt(i)
+        if err != nil { return []string{}, nil }
+
+        strs[i] = s
+    }
+
+    return strs, nil
+} 
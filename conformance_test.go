@@ -0,0 +1,232 @@
+package libregf_test
+
+// The tests of this file only go through the API both backends share, and
+// check the Keys and Values read from a hive against its description, so that
+// running them with and without the purego build tag shows the two backends
+// read the same hives the same way:
+//
+//   go test ./...
+//   go test -tags purego ./...
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+var valueTypes = map[string]int{
+    "REG_NONE":             libregf.ValueTypeUndefined,
+    "REG_SZ":               libregf.ValueTypeString,
+    "REG_EXPAND_SZ":        libregf.ValueTypeExpandableString,
+    "REG_BINARY":           libregf.ValueTypeBinaryData,
+    "REG_DWORD":            libregf.ValueTypeInteger32BitLittleEndian,
+    "REG_DWORD_BIG_ENDIAN": libregf.ValueTypeInteger32BitBigEndian,
+    "REG_LINK":             libregf.ValueTypeSymbolicLink,
+    "REG_MULTI_SZ":         libregf.ValueTypeMultiValueString,
+    "REG_RESOURCE_LIST":    libregf.ValueTypeResourceList,
+    "REG_QWORD":            libregf.ValueTypeInteger64BitLittleEndian,
+}
+
+func TestConformanceKeys(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    want := []string{"", "Names", "Names\\Zürich", "Names\\Zürich\\Ελληνικά", "Names\\Zürich\\Ελληνικά\\日本語", "Types"}
+    if got := keyPaths(t, file); !reflect.DeepEqual(got, want) {
+        t.Errorf("Walk: got %q, want %q", got, want)
+    }
+
+    for _, k := range regftest.Standard().Keys {
+        key, err := file.Key(k.Path)
+        if k.Deleted {
+            if !errors.Is(err, libregf.ErrNotFound) { t.Errorf("deleted key %s: got %v, want ErrNotFound", k.Path, err) }
+            continue
+        }
+        if err != nil {
+            t.Errorf("key %s: %v", k.Path, err)
+            continue
+        }
+
+        if class, err := key.ClassName(); err != nil || class != k.Class {
+            t.Errorf("key %s: class name %q, %v, want %q", k.Path, class, err, k.Class)
+        }
+        if !k.LastWritten.IsZero() {
+            if lw, err := key.LastWritten(); err != nil || !lw.Equal(k.LastWritten) {
+                t.Errorf("key %s: last written %v, %v, want %v", k.Path, lw, err, k.LastWritten)
+            }
+        }
+        for _, v := range k.Values {
+            checkValue(t, key, k.Path, v)
+        }
+        key.Free()
+    }
+}
+
+// checkValue compares the Value of key by the name of v to v.
+func checkValue(t *testing.T, key *libregf.Key, path string, v regftest.Value) {
+    t.Helper()
+
+    value, err := key.Value(v.Name)
+    if v.Deleted {
+        if !errors.Is(err, libregf.ErrNotFound) { t.Errorf("deleted value %s\\%s: got %v, want ErrNotFound", path, v.Name, err) }
+        return
+    }
+    if err != nil {
+        t.Errorf("value %s\\%s: %v", path, v.Name, err)
+        return
+    }
+    defer value.Free()
+
+    if _type, err := value.Type(); err != nil || _type != valueTypes[v.Type] {
+        t.Errorf("value %s\\%s: type %d, %v, want %s", path, v.Name, _type, err, v.Type)
+    }
+
+    var got, want interface{}
+    switch v.Type {
+    case "REG_SZ", "REG_EXPAND_SZ", "REG_LINK":
+        got, err = value.TString()
+        want = v.String
+    case "REG_MULTI_SZ":
+        got, err = libregf.Get[[]string](key, v.Name)
+        want = v.Strings
+    case "REG_DWORD", "REG_DWORD_BIG_ENDIAN":
+        got, err = libregf.Get[uint32](key, v.Name)
+        want = uint32(v.Integer)
+    case "REG_QWORD":
+        got, err = libregf.Get[uint64](key, v.Name)
+        want = v.Integer
+    default:
+        got, err = value.Data()
+        want = v.Data
+        if v.Size > 0 { want = pattern(v.Size) }
+    }
+    if err != nil {
+        t.Errorf("value %s\\%s: %v", path, v.Name, err)
+    } else if !reflect.DeepEqual(got, want) {
+        t.Errorf("value %s\\%s: got %.40q, want %.40q", path, v.Name, got, want)
+    }
+}
+
+// pattern is the data regftest gives Values described by their size only.
+func pattern(size int) []byte {
+    data := make([]byte, size)
+    for i := range data {
+        data[i] = byte(i % 251)
+    }
+    return data
+}
+
+func TestConformanceNames(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    // names are looked up without regard to case, the way Windows compares them
+    key, err := file.Key("NAMES\\zürich\\ΕΛΛΗΝΙΚΆ\\日本語")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    name, err := key.Name()
+    if err != nil || name != "日本語" { t.Errorf("Name: got %q, %v", name, err) }
+    if n, err := libregf.Get[uint32](key, "GRÖSSE"); err == nil {
+        t.Errorf("Größe matched GRÖSSE, got %d", n)
+    }
+    if n, err := libregf.Get[uint32](key, "GRÖßE"); err != nil || n != 1 {
+        t.Errorf("Get GRÖßE: got %d, %v", n, err)
+    }
+}
+
+// Big and BigString are larger than the 16344 bytes a data cell can hold, so
+// they are stored as big data (db) cells, in segments.
+func TestConformanceBigData(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    big, err := key.Value("Big")
+    if err != nil { t.Fatal(err) }
+    defer big.Free()
+    if n, err := big.DataLen(); err != nil || n != 40000 { t.Errorf("DataLen: got %d, %v, want 40000", n, err) }
+    if data, err := big.Data(); err != nil || !bytes.Equal(data, pattern(40000)) { t.Errorf("Data: got %d bytes, %v, not the pattern written", len(data), err) }
+    if data, err := big.TBinary(); err != nil || !bytes.Equal(data, pattern(40000)) { t.Errorf("TBinary: got %d bytes, %v, not the pattern written", len(data), err) }
+
+    r, err := big.Reader()
+    if err != nil { t.Fatal(err) }
+    if r.Size() != 40000 { t.Errorf("Reader: got a size of %d", r.Size()) }
+    // a read across the boundary of the first two segments
+    buf := make([]byte, 100)
+    if _, err := r.ReadAt(buf, 16300); err != nil || !bytes.Equal(buf, pattern(40000)[16300:16400]) { t.Errorf("ReadAt: got %x, %v", buf, err) }
+    if data, err := io.ReadAll(r); err != nil || !bytes.Equal(data, pattern(40000)) { t.Errorf("Reader: got %d bytes, %v, not the pattern written", len(data), err) }
+
+    bigString, err := key.Value("BigString")
+    if err != nil { t.Fatal(err) }
+    defer bigString.Free()
+    if s, err := bigString.TString(); err != nil || s != strings.Repeat("big data ", 3000) { t.Errorf("TString: got %d characters, %v", len(s), err) }
+
+    if data, err := file.GetBytes("Types\\Big"); err != nil || !bytes.Equal(data, pattern(40000)) { t.Errorf("GetBytes: got %d bytes, %v", len(data), err) }
+    if s, err := file.GetString("Types\\BigString"); err != nil || s != strings.Repeat("big data ", 3000) { t.Errorf("GetString: got %d characters, %v", len(s), err) }
+}
+
+func TestConformanceMaxValueSize(t *testing.T) {
+    file := openHive(t, regftest.Standard(), libregf.WithMaxValueSize(20000))
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    big, err := key.Value("Big")
+    if err != nil { t.Fatal(err) }
+    defer big.Free()
+    if _, err := big.Data(); !errors.Is(err, libregf.ErrValueTooLarge) { t.Errorf("Data: got %v, want ErrValueTooLarge", err) }
+    if _, err := big.TBinary(); !errors.Is(err, libregf.ErrValueTooLarge) { t.Errorf("TBinary: got %v, want ErrValueTooLarge", err) }
+    if _, err := file.GetString("Types\\BigString"); !errors.Is(err, libregf.ErrValueTooLarge) { t.Errorf("GetString: got %v, want ErrValueTooLarge", err) }
+
+    // only the pure Go backend streams data past the limit
+    r, err := big.Reader()
+    if libregf.Version() == "purego" {
+        if err != nil { t.Fatalf("Reader: %v", err) }
+        if data, err := io.ReadAll(r); err != nil || !bytes.Equal(data, pattern(40000)) { t.Errorf("Reader: got %d bytes, %v, not the pattern written", len(data), err) }
+    } else if !errors.Is(err, libregf.ErrValueTooLarge) {
+        t.Errorf("Reader: got %v, want ErrValueTooLarge", err)
+    }
+
+    // data under the limit is still read
+    if s, err := file.GetString("Types\\String"); err != nil || s != "hello, world" { t.Errorf("GetString: got %q, %v", s, err) }
+}
+
+// A corrupted size of 1GB is refused by the default limit, rather than read.
+func TestConformanceBadDataSize(t *testing.T) {
+    h := regftest.Standard()
+    h.Corruptions = []regftest.Corruption{{Path: "Types", Value: "Big", Kind: regftest.BadDataSize}}
+    file := openHive(t, h)
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    big, err := key.Value("Big")
+    if err != nil { t.Fatal(err) }
+    defer big.Free()
+    if _, err := big.Data(); !errors.Is(err, libregf.ErrValueTooLarge) { t.Errorf("Data: got %v, want ErrValueTooLarge", err) }
+    if _, err := big.TBinary(); !errors.Is(err, libregf.ErrValueTooLarge) { t.Errorf("TBinary: got %v, want ErrValueTooLarge", err) }
+
+    // without a limit, reading fails on the missing segments
+    file = openHive(t, h, libregf.WithMaxValueSize(0))
+    if _, err := file.GetBytes("Types\\Big"); err == nil { t.Error("GetBytes of a corrupted size succeeded") }
+}
+
+func TestConformanceSubkeyLoop(t *testing.T) {
+    h := regftest.Standard()
+    h.Keys = append(h.Keys, regftest.Key{Path: "Loop\\Child\\Grandchild"})
+    h.Corruptions = []regftest.Corruption{{Path: "Loop\\Child", Kind: regftest.SubkeyLoop}}
+    file := openHive(t, h)
+
+    if err := file.Walk(func(string, libregf.RegistryKey) error { return nil }); !errors.Is(err, libregf.ErrKeyLoop) {
+        t.Errorf("Walk: got %v, want ErrKeyLoop", err)
+    }
+    if _, err := libregf.EditHive(file); !errors.Is(err, libregf.ErrKeyLoop) {
+        t.Errorf("EditHive: got %v, want ErrKeyLoop", err)
+    }
+}
@@ -0,0 +1,24 @@
+// Package libregf provides Go bindings for the libregf C library, which
+// exposes an API for handling Windows Registry files.
+//
+// # Concurrency
+//
+// A File, and every Key, Value and MultiString obtained from it, share the
+// underlying libregf file handle, which is not safe for concurrent use.
+// Only one goroutine at a time may use a File or anything it returned.
+//
+// To read the same registry file from several goroutines, give each goroutine
+// its own File. A Pool opens those handles on demand and hands them out, so
+// goroutines only have to Get() a File, use it, and Put() it back:
+//
+//	pool, err := libregf.NewPool("SOFTWARE", 4)
+//	if err != nil { return err }
+//	defer pool.Close()
+//
+//	file, err := pool.Get()
+//	if err != nil { return err }
+//	defer pool.Put(file)
+//
+// Keys and Values must not outlive the File they came from, nor be used after
+// that File has been put back in the Pool.
+package libregf
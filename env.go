@@ -0,0 +1,240 @@
+package libregf
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// Environment holds environment variables used to expand REG_EXPAND_SZ Values,
+// such as a service's ImagePath, offline. Variable names are matched without
+// regard to case, as on Windows.
+// The zero value is not usable; build one with NewEnvironment or SystemEnvironment.
+type Environment struct {
+    vars map[string]string
+}
+
+// NewEnvironment returns an Environment holding vars, which may be nil.
+func NewEnvironment(vars map[string]string) *Environment {
+    env := &Environment{vars: make(map[string]string, len(vars))}
+    for name, value := range vars {
+        env.Set(name, value)
+    }
+    return env
+}
+
+// Set sets a variable, which may itself reference other variables.
+func (env *Environment) Set(name, value string) {
+    env.vars[UpcaseName(name)] = value
+}
+
+// Get returns a variable, expanded, and whether it is set.
+func (env *Environment) Get(name string) (string, bool) {
+    value, ok := env.vars[UpcaseName(name)]
+    if !ok { return "", false }
+    return env.expand(value, 0), true
+}
+
+// Vars returns all of the variables, expanded, indexed by their upper case name.
+func (env *Environment) Vars() map[string]string {
+    vars := make(map[string]string, len(env.vars))
+    for name, value := range env.vars {
+        vars[name] = env.expand(value, 0)
+    }
+    return vars
+}
+
+// maxExpandDepth bounds the expansion of variables referencing other variables,
+// so that circular references don't loop forever.
+const maxExpandDepth = 8
+
+// Expand replaces the %NAME% references in s by the value of the variables.
+// References to variables that aren't set are left verbatim, as the Windows
+// ExpandEnvironmentStrings() function does.
+func (env *Environment) Expand(s string) string {
+    return env.expand(s, 0)
+}
+
+func (env *Environment) expand(s string, depth int) string {
+    var b strings.Builder
+    for {
+        start := strings.IndexByte(s, '%')
+        if start < 0 { break }
+        end := strings.IndexByte(s[start+1:], '%')
+        if end < 0 { break }
+        end += start + 1
+
+        name := s[start+1 : end]
+        value, ok := env.vars[UpcaseName(name)]
+        if !ok || name == "" {
+            // keep the first % and look for a reference starting at the second one
+            b.WriteString(s[:end])
+            s = s[end:]
+            continue
+        }
+
+        b.WriteString(s[:start])
+        if depth < maxExpandDepth {
+            b.WriteString(env.expand(value, depth+1))
+        } else {
+            b.WriteString(value)
+        }
+        s = s[end+1:]
+    }
+    b.WriteString(s)
+
+    return b.String()
+}
+
+// FormatValue is the package's FormatValue, but expands REG_EXPAND_SZ Values.
+func (env *Environment) FormatValue(value RegistryValue) (string, error) {
+    _type, err := value.Type()
+    if err != nil { return "", err }
+    if _type != ValueTypeExpandableString { return FormatValue(value) }
+
+    s, err := value.TString()
+    if err != nil { return "", err }
+
+    return env.Expand(s), nil
+}
+
+// HiveValue is the package's HiveValue, but expands REG_EXPAND_SZ Values.
+func (env *Environment) HiveValue(h Hive, path string) (string, error) {
+    key, value, err := lookupValue(h, path)
+    if err != nil { return "", err }
+    defer release(key)
+    defer release(value)
+
+    return env.FormatValue(value)
+}
+
+// CurrentControlSet returns the path of the control set in use, such as
+// "ControlSet001", read from the Select Key of a SYSTEM hive. This is what the
+// CurrentControlSet link points to on a running system.
+func CurrentControlSet(system Hive) (string, error) {
+    current, err := HiveGet[uint32](system, "Select\\Current")
+    if err != nil { return "", err }
+
+    return fmt.Sprintf("ControlSet%03d", current), nil
+}
+
+// SystemEnvironment builds the Environment of the system from its SYSTEM and
+// SOFTWARE hives, either of which may be nil:
+//
+//   - the variables of Control\Session Manager\Environment in the current
+//     control set of the SYSTEM hive, such as windir, ComSpec or TEMP, unless
+//     the hive has no Select\Current Value to tell which control set that is;
+//   - SystemRoot and SystemDrive, from Microsoft\Windows NT\CurrentVersion in
+//     the SOFTWARE hive;
+//   - ProgramFiles, ProgramFiles(x86), ProgramW6432, CommonProgramFiles and
+//     CommonProgramFiles(x86), from Microsoft\Windows\CurrentVersion;
+//   - ProgramData, ALLUSERSPROFILE and PUBLIC, from the ProfileList.
+//
+// Variables found nowhere are left unset. Use WithProfile to add the variables
+// of a user.
+func SystemEnvironment(system, software Hive) (*Environment, error) {
+    env := NewEnvironment(nil)
+
+    if system != nil {
+        // hives without Select\Current don't tell which control set to read
+        ccs, err := CurrentControlSet(system)
+        if err != nil && !errors.Is(err, ErrNotFound) { return nil, err }
+        if err == nil {
+            if err := env.SetValues(system, ccs+"\\Control\\Session Manager\\Environment"); err != nil { return nil, err }
+        }
+    }
+
+    if software != nil {
+        const nt = "Microsoft\\Windows NT\\CurrentVersion"
+        const win = "Microsoft\\Windows\\CurrentVersion"
+        for _, v := range []struct{ name, path string }{
+            {"SystemRoot", nt + "\\SystemRoot"},
+            {"ProgramFiles", win + "\\ProgramFilesDir"},
+            {"ProgramFiles(x86)", win + "\\ProgramFilesDir (x86)"},
+            {"ProgramW6432", win + "\\ProgramW6432Dir"},
+            {"CommonProgramFiles", win + "\\CommonFilesDir"},
+            {"CommonProgramFiles(x86)", win + "\\CommonFilesDir (x86)"},
+            {"CommonProgramW6432", win + "\\CommonW6432Dir"},
+            {"ProgramData", nt + "\\ProfileList\\ProgramData"},
+            {"ALLUSERSPROFILE", nt + "\\ProfileList\\ProgramData"},
+            {"PUBLIC", nt + "\\ProfileList\\Public"},
+        } {
+            if err := env.setValue(software, v.name, v.path); err != nil { return nil, err }
+        }
+    }
+
+    if root, ok := env.Get("SystemRoot"); ok && len(root) >= 2 && root[1] == ':' {
+        if _, ok := env.Get("SystemDrive"); !ok { env.Set("SystemDrive", root[:2]) }
+    }
+    if root, ok := env.vars["SYSTEMROOT"]; ok {
+        if _, ok := env.vars["WINDIR"]; !ok { env.Set("windir", root) }
+    }
+
+    return env, nil
+}
+
+// WithProfile returns a copy of the Environment with the variables of the user
+// whose security identifier is sid, such as USERPROFILE, APPDATA or TEMP, based
+// on the ProfileImagePath of the ProfileList in the SOFTWARE hive.
+// Variables of the user's own Environment Key, found in its NTUSER.DAT hive,
+// can then be added with SetValues.
+func (env *Environment) WithProfile(software Hive, sid string) (*Environment, error) {
+    profile, err := HiveGet[string](software, "Microsoft\\Windows NT\\CurrentVersion\\ProfileList\\"+sid+"\\ProfileImagePath")
+    if err != nil { return nil, err }
+
+    user := NewEnvironment(env.vars)
+    user.Set("USERPROFILE", profile)
+    expanded := user.Expand(profile)
+    if len(expanded) >= 2 && expanded[1] == ':' {
+        user.Set("HOMEDRIVE", expanded[:2])
+        user.Set("HOMEPATH", expanded[2:])
+    }
+    if i := strings.LastIndexByte(expanded, '\\'); i >= 0 { user.Set("USERNAME", expanded[i+1:]) }
+    user.Set("APPDATA", "%USERPROFILE%\\AppData\\Roaming")
+    user.Set("LOCALAPPDATA", "%USERPROFILE%\\AppData\\Local")
+    user.Set("TEMP", "%USERPROFILE%\\AppData\\Local\\Temp")
+    user.Set("TMP", "%USERPROFILE%\\AppData\\Local\\Temp")
+
+    return user, nil
+}
+
+// SetValues sets a variable for each of the string Values of the Key by its
+// path inside h, as found in Environment Keys. A missing Key sets nothing.
+func (env *Environment) SetValues(h Hive, path string) error {
+    key, err := h.GetKey(path)
+    if errors.Is(err, ErrNotFound) { return nil }
+    if err != nil { return err }
+    defer release(key)
+
+    n, err := key.ValuesLen()
+    if err != nil { return err }
+
+    for i := 0; i < n; i++ {
+        value, err := key.GetValueAt(i)
+        if err != nil { return err }
+        name, err := value.Name()
+        if err == nil && name != "" {
+            var s string
+            if err = getValue(value, &s); err == nil {
+                env.Set(name, s)
+            } else if errors.Is(err, ErrWrongType) {
+                err = nil
+            }
+        }
+        release(value)
+        if err != nil { return err }
+    }
+
+    return nil
+}
+
+// setValue sets the variable name to the string Value by its path inside h,
+// unless it is missing.
+func (env *Environment) setValue(h Hive, name, path string) error {
+    s, err := HiveGet[string](h, path)
+    if errors.Is(err, ErrNotFound) || errors.Is(err, ErrWrongType) { return nil }
+    if err != nil { return err }
+
+    env.Set(name, s)
+    return nil
+}
@@ -0,0 +1,121 @@
+package libregf_test
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+func TestExpand(t *testing.T) {
+    env := libregf.NewEnvironment(map[string]string{
+        "SystemRoot": "C:\\Windows",
+        "windir":     "%SystemRoot%",
+        "A":          "%B%",
+        "B":          "%A%",
+    })
+
+    for s, want := range map[string]string{
+        "%SYSTEMROOT%\\System32": "C:\\Windows\\System32",
+        "%windir%\\%windir%":     "C:\\Windows\\C:\\Windows",
+        "%Unknown%\\x":           "%Unknown%\\x",
+        "100%% done":             "100%% done",
+        "50% of %windir%":        "50% of C:\\Windows",
+        "%Unknown%windir%":       "%UnknownC:\\Windows",
+        "no reference":           "no reference",
+        "trailing %":             "trailing %",
+    } {
+        if got := env.Expand(s); got != want { t.Errorf("Expand(%q): got %q, want %q", s, got, want) }
+    }
+
+    // circular references stop expanding after a while
+    if got := env.Expand("%A%"); got != "%A%" && got != "%B%" { t.Errorf("Expand of a circular reference: got %q", got) }
+}
+
+// systemHives returns the SYSTEM and SOFTWARE hives of a system installed in
+// C:\Windows, with a user whose SID is S-1-5-21-1-1001.
+func systemHives(withSelect bool) (*regftest.Hive, *regftest.Hive) {
+    system := &regftest.Hive{Keys: []regftest.Key{
+        {Path: "ControlSet001\\Control\\Session Manager\\Environment", Values: []regftest.Value{
+            {Name: "ComSpec", Type: "REG_EXPAND_SZ", String: "%SystemRoot%\\system32\\cmd.exe"},
+            {Name: "TEMP", Type: "REG_EXPAND_SZ", String: "%SystemRoot%\\TEMP"},
+            {Name: "NUMBER_OF_PROCESSORS", Type: "REG_DWORD", Integer: 4},
+        }},
+    }}
+    if withSelect {
+        system.Keys = append(system.Keys, regftest.Key{Path: "Select", Values: []regftest.Value{{Name: "Current", Type: "REG_DWORD", Integer: 1}}})
+    }
+
+    const nt = "Microsoft\\Windows NT\\CurrentVersion"
+    software := &regftest.Hive{Keys: []regftest.Key{
+        {Path: nt, Values: []regftest.Value{{Name: "SystemRoot", Type: "REG_SZ", String: "C:\\Windows"}}},
+        {Path: "Microsoft\\Windows\\CurrentVersion", Values: []regftest.Value{{Name: "ProgramFilesDir", Type: "REG_SZ", String: "C:\\Program Files"}}},
+        {Path: nt + "\\ProfileList", Values: []regftest.Value{
+            {Name: "ProgramData", Type: "REG_EXPAND_SZ", String: "%SystemDrive%\\ProgramData"},
+            {Name: "Public", Type: "REG_EXPAND_SZ", String: "%SystemDrive%\\Users\\Public"},
+        }},
+        {Path: nt + "\\ProfileList\\S-1-5-21-1-1001", Values: []regftest.Value{{Name: "ProfileImagePath", Type: "REG_EXPAND_SZ", String: "%SystemDrive%\\Users\\alice"}}},
+    }}
+
+    return system, software
+}
+
+func TestSystemEnvironment(t *testing.T) {
+    system, software := systemHives(true)
+    env, err := libregf.SystemEnvironment(openHive(t, system), openHive(t, software))
+    if err != nil { t.Fatal(err) }
+
+    for name, want := range map[string]string{
+        "ComSpec":         "C:\\Windows\\system32\\cmd.exe",
+        "TEMP":            "C:\\Windows\\TEMP",
+        "SystemRoot":      "C:\\Windows",
+        "windir":          "C:\\Windows",
+        "SystemDrive":     "C:",
+        "ProgramFiles":    "C:\\Program Files",
+        "ProgramData":     "C:\\ProgramData",
+        "ALLUSERSPROFILE": "C:\\ProgramData",
+        "PUBLIC":          "C:\\Users\\Public",
+    } {
+        if got, ok := env.Get(name); !ok || got != want { t.Errorf("%s: got %q, %v, want %q", name, got, ok, want) }
+    }
+    for _, name := range []string{"NUMBER_OF_PROCESSORS", "ProgramFiles(x86)"} {
+        if got, ok := env.Get(name); ok { t.Errorf("%s: got %q, want it unset", name, got) }
+    }
+
+    // a hive without Select\Current gives the variables of SOFTWARE only
+    system, software = systemHives(false)
+    env, err = libregf.SystemEnvironment(openHive(t, system), openHive(t, software))
+    if err != nil { t.Fatalf("without Select\\Current: %v", err) }
+    if _, ok := env.Get("ComSpec"); ok { t.Error("without Select\\Current: ComSpec is set") }
+    if got, ok := env.Get("windir"); !ok || got != "C:\\Windows" { t.Errorf("without Select\\Current: windir is %q, %v", got, ok) }
+}
+
+func TestWithProfile(t *testing.T) {
+    system, software := systemHives(true)
+    softwareFile := openHive(t, software)
+    env, err := libregf.SystemEnvironment(openHive(t, system), softwareFile)
+    if err != nil { t.Fatal(err) }
+
+    user, err := env.WithProfile(softwareFile, "S-1-5-21-1-1001")
+    if err != nil { t.Fatal(err) }
+    for name, want := range map[string]string{
+        "USERPROFILE":  "C:\\Users\\alice",
+        "HOMEDRIVE":    "C:",
+        "HOMEPATH":     "\\Users\\alice",
+        "USERNAME":     "alice",
+        "APPDATA":      "C:\\Users\\alice\\AppData\\Roaming",
+        "LOCALAPPDATA": "C:\\Users\\alice\\AppData\\Local",
+        "TEMP":         "C:\\Users\\alice\\AppData\\Local\\Temp",
+        "windir":       "C:\\Windows",
+    } {
+        if got, ok := user.Get(name); !ok || got != want { t.Errorf("%s: got %q, %v, want %q", name, got, ok, want) }
+    }
+
+    // the Environment of the system is left as it was
+    if got, _ := env.Get("TEMP"); got != "C:\\Windows\\TEMP" { t.Errorf("TEMP of the system: got %q", got) }
+    if _, ok := env.Get("USERPROFILE"); ok { t.Error("USERPROFILE was set in the Environment of the system") }
+
+    if _, err := env.WithProfile(softwareFile, "S-1-5-21-1-1002"); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("unknown SID: got %v, want ErrNotFound", err) }
+}
+
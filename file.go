@@ -1,3 +1,5 @@
+//go:build cgo && !purego
+
 package libregf
 
 /*
@@ -7,23 +9,26 @@ package libregf
 import "C"
 
 import (
+	"context"
 	"fmt"
-	"strings"
 	"unsafe"
 )
 
-// File is an opaque struct to group related method calls
-type File C.libregf_file_t
+// File is a registry file opened by libregf, along with the Options it was opened with.
+type File struct {
+    ptr     *C.libregf_file_t
+    options fileOptions
+}
 
-// OpenFile opens a registry file by its path.
-// It wraps libregf_file_initialize() and libregf_file_open().
-func OpenFile(path string) (*File, error) {
-    var file File
-    ppfile := unsafe.Pointer(&file)
+// OpenFileContext opens a registry file by its path, like OpenFile, but gives up
+// as soon as ctx is cancelled. In that case it returns ctx.Err().
+// It wraps libregf_file_initialize(), libregf_file_open() and libregf_file_signal_abort().
+func OpenFileContext(ctx context.Context, path string, opts ...Option) (*File, error) {
+    var ptr *C.libregf_file_t
     var err Error
     ppe := unsafe.Pointer(&err)
 
-    res := int(C.libregf_file_initialize((**C.libregf_file_t)(ppfile), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_file_initialize(&ptr, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
     
@@ -31,85 +36,148 @@ func OpenFile(path string) (*File, error) {
         return nil, fmt.Errorf("%s", pe.String())
     }
 
-    // pfile is just *ppfile
-    //pfile := (*C.libregf_file_t)(*(**C.libregf_file_t)(ppfile))
-    pfile := *(**File)(ppfile)
+    pfile := &File{ptr: ptr, options: fileOptionsOf(opts)}
+
+    if err := ctx.Err(); err != nil {
+        pfile.free()
+        return nil, err
+    }
+
+    if pfile.options.codepage != 0 {
+        if err := pfile.SetCodepage(pfile.options.codepage); err != nil {
+            pfile.free()
+            return nil, err
+        }
+    }
 
-    res = int(C.libregf_file_open((*C.libregf_file_t)(pfile), C.CString(path), C.LIBREGF_ACCESS_FLAG_READ | C.LIBREGF_FILE_TYPE_REGISTRY, (**C.libregf_error_t)(ppe)))
+    cpath := C.CString(path)
+    defer C.free(unsafe.Pointer(cpath))
+    stop := abortOnDone(ctx, pfile)
+    res = int(C.libregf_file_open(pfile.ptr, cpath, C.LIBREGF_ACCESS_FLAG_READ | C.LIBREGF_FILE_TYPE_REGISTRY, (**C.libregf_error_t)(ppe)))
+    stop()
     pe = *(**Error)(ppe)
     defer pe.Free()
 
+    if err := ctx.Err(); err != nil {
+        if res == 1 { pfile.Close() }
+        pfile.free()
+        return nil, err
+    }
+
     if res != 1 {
+        pfile.free()
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*File)(pfile), nil
+        return pfile, nil
     }
 }
 
 // Close closes a registry file.
 // It wraps libregf_file_close().
 func (file *File) Close() {
-    C.libregf_file_close((*C.libregf_file_t)(file), nil)
+    C.libregf_file_close(file.ptr, nil)
+}
+
+// Codepage returns the codepage of the names stored in the compressed (one byte
+// per character) form.
+// It wraps libregf_file_get_ascii_codepage().
+func (file *File) Codepage() (int, error) {
+    var codepage C.int
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_file_get_ascii_codepage(file.ptr, &codepage, (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(codepage), nil
+    }
+}
+
+// SetCodepage sets the codepage of the names stored in the compressed (one byte
+// per character) form, one of the Codepage* constants. It applies to the Keys
+// and Values obtained afterwards; see also WithCodepage.
+// It wraps libregf_file_set_ascii_codepage().
+func (file *File) SetCodepage(codepage int) error {
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_file_set_ascii_codepage(file.ptr, C.int(codepage), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return fmt.Errorf("%s", pe.String())
+    } else {
+        return nil
+    }
+}
+
+// SignalAbort asks libregf to stop whatever it is doing with the file, such
+// as reading the hive bins while opening it. It is safe to call from another goroutine.
+// It wraps libregf_file_signal_abort().
+func (file *File) SignalAbort() error {
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_file_signal_abort(file.ptr, (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return fmt.Errorf("%s", pe.String())
+    } else {
+        return nil
+    }
+}
+
+// free frees memory allocated in C for the hidden File struct.
+// It wraps libregf_file_free().
+func (file *File) free() {
+    C.libregf_file_free(&file.ptr, nil)
 }
 
 // RootKey returns the root Key of a registry file.
 // It wraps libregf_file_get_root_key().
 func (file *File) RootKey() (*Key, error) { 
-    var key Key
-    ppkey := unsafe.Pointer(&key)
+    var pkey *C.libregf_key_t
     var err Error
     ppe := unsafe.Pointer(&err)
 
-    res := int(C.libregf_file_get_root_key((*C.libregf_file_t)(file), (**C.libregf_key_t)(ppkey), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_file_get_root_key(file.ptr, &pkey, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    // pkey is just *ppkey
-    //pkey := (*C.libregf_key_t)(*(**C.libregf_key_t)(ppkey))
-    pkey := *(**Key)(ppkey)
 
     if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Key)(pkey), nil
+        return &Key{ptr: pkey, file: file}, nil
     }
 }
 
 // Key returns a Key by its path inside the registry.
+// Names are compared the way Windows does; see LookupKey for other separators.
 // It wraps libregf_file_get_key_by_utf8_path().
 func (file *File) Key(path string) (*Key, error) { 
-    var key Key
-    ppkey := unsafe.Pointer(&key)
+    var pkey *C.libregf_key_t
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
     bpath := append([]byte(path), 0)
 
-    res := int(C.libregf_file_get_key_by_utf8_path((*C.libregf_file_t)(file), (*C.uint8_t)(unsafe.Pointer(&bpath[0])), C.ulong(len(bpath)-1), (**C.libregf_key_t)(ppkey), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_file_get_key_by_utf8_path(file.ptr, (*C.uint8_t)(unsafe.Pointer(&bpath[0])), C.ulong(len(bpath)-1), &pkey, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    pkey := *(**Key)(ppkey)
 
-    if res != 1 {
+    if res == 0 {
+        key, err := LookupKey(file, path, "\\")
+        if err != nil { return nil, err }
+        return key.(*Key), nil
+    } else if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Key)(pkey), nil
+        return &Key{ptr: pkey, file: file}, nil
     }
 }
-
-// Value returns the string representation of a "value of a Value" by its path inside the registry.
-// It does so by considering that the last part of the path if the Value's name.
-// This is a quick way to get a displayable value for a full registry path in one call.
-func (file *File) Value(path string) (string, error) { 
-    parts := strings.Split(path, "\\")
-    l := len(parts)
-    k := strings.Join(parts[:l-1], "\\")
-    v := parts[l-1]
-    key, err := file.Key(k)
-    if err != nil { return "", err }
-    value, err := key.Value(v) 
-    if err != nil { return "", err }
-    s, err := value.String()
-    if err != nil { return "", err }
-
-    return s, nil
-}
-
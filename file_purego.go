@@ -0,0 +1,109 @@
+//go:build !cgo || purego
+
+package libregf
+
+import (
+    "context"
+    "os"
+    "strings"
+)
+
+// File is a registry file opened by the pure Go backend.
+type File struct {
+    f        *os.File
+    hive     *hive
+    codepage int
+    table    *[128]rune
+    options  fileOptions
+}
+
+// OpenFileContext opens a registry file by its path, like OpenFile, but gives up
+// if ctx is cancelled before the file is open. In that case it returns ctx.Err().
+// Only the single byte codepages are supported by WithCodepage.
+func OpenFileContext(ctx context.Context, path string, opts ...Option) (*File, error) {
+    if err := ctx.Err(); err != nil { return nil, err }
+
+    file := &File{codepage: CodepageWindows1252, table: codepages[CodepageWindows1252], options: fileOptionsOf(opts)}
+    if file.options.codepage != 0 {
+        if err := file.SetCodepage(file.options.codepage); err != nil { return nil, err }
+    }
+
+    f, err := os.Open(path)
+    if err != nil { return nil, regfError("unable to open file: %v", err) }
+
+    st, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, regfError("unable to stat file: %v", err)
+    }
+
+    h, err := newHive(f, st.Size())
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+
+    if err := ctx.Err(); err != nil {
+        f.Close()
+        return nil, err
+    }
+
+    file.f = f
+    file.hive = h
+    return file, nil
+}
+
+// Close closes a registry file.
+func (file *File) Close() {
+    file.f.Close()
+}
+
+// Codepage returns the codepage of the names stored in the compressed (one byte
+// per character) form.
+func (file *File) Codepage() (int, error) {
+    return file.codepage, nil
+}
+
+// SetCodepage sets the codepage of the names stored in the compressed (one byte
+// per character) form, one of the single byte Codepage* constants. It applies
+// to the Keys and Values obtained afterwards; see also WithCodepage.
+func (file *File) SetCodepage(codepage int) error {
+    table, ok := codepages[codepage]
+    if !ok { return regfError("unsupported codepage %d", codepage) }
+
+    file.codepage = codepage
+    file.table = table
+    return nil
+}
+
+// SignalAbort exists for parity with the libregf backend. Opening a file only
+// reads its base block in the pure Go backend, so there is nothing to abort.
+func (file *File) SignalAbort() error {
+    return nil
+}
+
+// free does nothing, since there is no memory allocated in C to free.
+func (file *File) free() {
+}
+
+// RootKey returns the root Key of a registry file.
+func (file *File) RootKey() (*Key, error) {
+    return file.key(file.hive.root)
+}
+
+// Key returns a Key by its path inside the registry.
+// The path is relative to the root Key and names are compared the way Windows does;
+// see LookupKey for other separators.
+func (file *File) Key(path string) (*Key, error) {
+    key, err := file.RootKey()
+    if err != nil { return nil, err }
+
+    for _, name := range strings.Split(path, "\\") {
+        if name == "" { continue }
+
+        key, err = key.SubkeyByName(name)
+        if err != nil { return nil, err }
+    }
+
+    return key, nil
+}
@@ -0,0 +1,219 @@
+package libregf_test
+
+import (
+    "errors"
+    "path/filepath"
+    "reflect"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+func TestOpenFile(t *testing.T) {
+    if _, err := libregf.OpenFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+        t.Error("OpenFile succeeded on a missing file")
+    }
+
+    file := openHive(t, regftest.Standard())
+    if root, err := file.RootKey(); err != nil || root == nil { t.Errorf("RootKey: %v", err) }
+}
+
+func TestRootKey(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    root, err := file.RootKey()
+    if err != nil { t.Fatal(err) }
+    defer root.Free()
+
+    // the deleted Key isn't listed anymore
+    if n, err := root.SubkeysLen(); err != nil || n != 2 { t.Errorf("SubkeysLen: got %d, %v, want 2", n, err) }
+    names := []string{}
+    for i := 0; i < 2; i++ {
+        subkey, err := root.SubkeyAt(i)
+        if err != nil { t.Fatal(err) }
+        name, err := subkey.Name()
+        if err != nil { t.Fatal(err) }
+        names = append(names, name)
+        subkey.Free()
+    }
+    if !reflect.DeepEqual(names, []string{"Names", "Types"}) { t.Errorf("sub keys: got %q", names) }
+
+    if _, err := root.SubkeyAt(2); err == nil { t.Error("SubkeyAt past the last sub key succeeded") }
+}
+
+func TestKey(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    key, err := file.Key("types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    if name, err := key.Name(); err != nil || name != "Types" { t.Errorf("Name: got %q, %v", name, err) }
+    if class, err := key.ClassName(); err != nil || class != "TypesClass" { t.Errorf("ClassName: got %q, %v", class, err) }
+    if n, err := key.ValuesLen(); err != nil || n != 13 { t.Errorf("ValuesLen: got %d, %v, want 13", n, err) }
+    if sd, err := key.SecurityDescriptor(); err != nil || len(sd) == 0 { t.Errorf("SecurityDescriptor: got %d bytes, %v", len(sd), err) }
+
+    names, err := file.Key("Names")
+    if err != nil { t.Fatal(err) }
+    defer names.Free()
+    subkey, err := names.SubkeyByName("ZÜRICH")
+    if err != nil { t.Fatalf("SubkeyByName: %v", err) }
+    subkey.Free()
+
+    for _, path := range []string{"Missing", "Types\\Missing", "Deleted"} {
+        if _, err := file.Key(path); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("Key(%q): got %v, want ErrNotFound", path, err) }
+    }
+}
+
+func TestValue(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    for path, want := range map[string]string{
+        "Types\\":           "default value",
+        "Types\\String":     "hello, world",
+        "Types\\Expandable": "%SystemRoot%\\System32",
+        "Types\\Binary":     "deadbeef0001",
+        "Types\\Dword":      "3735928559",
+        "Types\\Qword":      "1099511627776",
+        "Types\\Multi":      "one, two, three",
+    } {
+        if got, err := file.Value(path); err != nil || got != want { t.Errorf("Value(%q): got %q, %v, want %q", path, got, err, want) }
+    }
+
+    if _, err := file.Value("Types\\Gone"); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("deleted value: got %v, want ErrNotFound", err) }
+}
+
+func TestValueAccessors(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    value := func(name string) *libregf.Value {
+        t.Helper()
+        v, err := key.Value(name)
+        if err != nil { t.Fatalf("Value(%q): %v", name, err) }
+        t.Cleanup(func() { v.Free() })
+        return v
+    }
+
+    if s, err := value("String").TString(); err != nil || s != "hello, world" { t.Errorf("TString: got %q, %v", s, err) }
+    if b, err := value("Binary").TBinary(); err != nil || !reflect.DeepEqual(b, []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x01}) {
+        t.Errorf("TBinary: got %x, %v", b, err)
+    }
+    if n, err := value("Binary").DataLen(); err != nil || n != 6 { t.Errorf("DataLen: got %d, %v", n, err) }
+    if i, err := value("Dword").Tint32(); err != nil || uint32(i) != 0xdeadbeef { t.Errorf("Tint32: got %x, %v", i, err) }
+    if i, err := value("Qword").Tint64(); err != nil || i != 1<<40 { t.Errorf("Tint64: got %x, %v", i, err) }
+
+    // accessors fail on Values of another type
+    if _, err := value("Dword").TString(); err == nil { t.Error("TString of a REG_DWORD succeeded") }
+    if _, err := value("String").Tint32(); err == nil { t.Error("Tint32 of a REG_SZ succeeded") }
+}
+
+func TestMultiString(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+    value, err := key.Value("Multi")
+    if err != nil { t.Fatal(err) }
+    defer value.Free()
+
+    ms, err := value.TMultiString()
+    if err != nil { t.Fatal(err) }
+    defer ms.Free()
+
+    if n, err := ms.StringsLen(); err != nil || n != 3 { t.Errorf("StringsLen: got %d, %v, want 3", n, err) }
+    if s, err := ms.StringAt(1); err != nil || s != "two" { t.Errorf("StringAt(1): got %q, %v", s, err) }
+    if n, err := ms.StringLenAt(2); err != nil || n != len("three")+1 { t.Errorf("StringLenAt(2): got %d, %v", n, err) }
+    if _, err := ms.StringAt(3); err == nil { t.Error("StringAt past the last string succeeded") }
+    if strs, err := ms.Strings(); err != nil || !reflect.DeepEqual(strs, []string{"one", "two", "three"}) { t.Errorf("Strings: got %q, %v", strs, err) }
+}
+
+func TestGetters(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+    key, err := file.Key("Types")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    if s, err := key.GetString("Expandable"); err != nil || s != "%SystemRoot%\\System32" { t.Errorf("GetString: got %q, %v", s, err) }
+    if strs, err := key.GetStrings("Multi"); err != nil || len(strs) != 3 { t.Errorf("GetStrings: got %q, %v", strs, err) }
+    if b, err := key.GetBytes("None"); err != nil || !reflect.DeepEqual(b, []byte{1, 2, 3}) { t.Errorf("GetBytes: got %x, %v", b, err) }
+    if b, err := key.GetBytes("ResourceList"); err != nil || len(b) != 4 { t.Errorf("GetBytes of a REG_RESOURCE_LIST: got %x, %v", b, err) }
+    if n, err := key.GetUint32("Dword"); err != nil || n != 0xdeadbeef { t.Errorf("GetUint32: got %x, %v", n, err) }
+    if n, err := key.GetUint32("DwordBigEndian"); err != nil || n != 0x01020304 { t.Errorf("GetUint32 of a REG_DWORD_BIG_ENDIAN: got %x, %v", n, err) }
+    if n, err := key.GetUint64("Qword"); err != nil || n != 1<<40 { t.Errorf("GetUint64: got %x, %v", n, err) }
+    if n, err := key.GetUint64("Dword"); err != nil || n != 0xdeadbeef { t.Errorf("GetUint64 of a REG_DWORD: got %x, %v", n, err) }
+
+    if s, err := file.GetString("Types\\String"); err != nil || s != "hello, world" { t.Errorf("File.GetString: got %q, %v", s, err) }
+    if n, err := file.GetUint32("Names\\Zürich\\Ελληνικά\\日本語\\Größe"); err != nil || n != 1 { t.Errorf("File.GetUint32: got %d, %v", n, err) }
+    if n, err := libregf.HiveGet[uint64](file, "Types\\Qword"); err != nil || n != 1<<40 { t.Errorf("HiveGet: got %x, %v", n, err) }
+    if s := libregf.HiveGetOr(file, "Types\\Missing", "default"); s != "default" { t.Errorf("HiveGetOr: got %q", s) }
+    if n := libregf.GetOr[uint32](key, "String", 7); n != 7 { t.Errorf("GetOr of a REG_SZ: got %d", n) }
+
+    if _, err := key.GetString("Dword"); !errors.Is(err, libregf.ErrWrongType) { t.Errorf("GetString of a REG_DWORD: got %v, want ErrWrongType", err) }
+    if _, err := key.GetUint32("Qword"); !errors.Is(err, libregf.ErrWrongType) { t.Errorf("GetUint32 of a REG_QWORD: got %v, want ErrWrongType", err) }
+    if _, err := key.GetBytes("String"); !errors.Is(err, libregf.ErrWrongType) { t.Errorf("GetBytes of a REG_SZ: got %v, want ErrWrongType", err) }
+    if _, err := key.GetString("Missing"); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("GetString of a missing value: got %v, want ErrNotFound", err) }
+}
+
+func TestCorruptions(t *testing.T) {
+    for _, test := range []struct {
+        corruption regftest.Corruption
+        read       func(*libregf.File) error
+    }{
+        {regftest.Corruption{Path: "Types", Kind: regftest.BadSignature}, readKey("Types")},
+        {regftest.Corruption{Path: "Types", Kind: regftest.BadCellSize}, readKey("Types")},
+        {regftest.Corruption{Path: "Types", Kind: regftest.BadNameLength}, readKey("Types")},
+        {regftest.Corruption{Path: "Types", Value: "Dword", Kind: regftest.BadSignature}, readValue("Types\\Dword")},
+        {regftest.Corruption{Path: "Types", Value: "String", Kind: regftest.BadCellSize}, readValue("Types\\String")},
+        {regftest.Corruption{Path: "Types", Value: "String", Kind: regftest.BadNameLength}, readValue("Types\\String")},
+        {regftest.Corruption{Path: "Types", Value: "String", Kind: regftest.BadDataOffset}, readValue("Types\\String")},
+        {regftest.Corruption{Path: "Types", Value: "Big", Kind: regftest.BadDataSize}, readValue("Types\\Big")},
+    } {
+        h := regftest.Standard()
+        h.Corruptions = []regftest.Corruption{test.corruption}
+        file := openHive(t, h)
+
+        if err := test.read(file); err == nil { t.Errorf("%+v: reading the corrupted record succeeded", test.corruption) }
+
+        // the rest of the hive is still readable
+        if n, err := file.GetUint32("Names\\Zürich\\Ελληνικά\\日本語\\Größe"); err != nil || n != 1 {
+            t.Errorf("%+v: reading an intact value: got %d, %v", test.corruption, n, err)
+        }
+    }
+}
+
+func TestBadChecksum(t *testing.T) {
+    h := regftest.Standard()
+    h.Corruptions = []regftest.Corruption{{Kind: regftest.BadChecksum}}
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatal(err) }
+
+    // whether the checksum of the base block is checked is up to the backend,
+    // but when the file opens, it must read as usual
+    file, err := libregf.OpenFile(path)
+    if err != nil { return }
+    defer file.Close()
+    if s, err := file.GetString("Types\\String"); err != nil || s != "hello, world" { t.Errorf("GetString: got %q, %v", s, err) }
+}
+
+// readKey returns a function reading the Key at path and its name.
+func readKey(path string) func(*libregf.File) error {
+    return func(file *libregf.File) error {
+        key, err := file.Key(path)
+        if err != nil { return err }
+        defer key.Free()
+        _, err = key.Name()
+        return err
+    }
+}
+
+// readValue returns a function reading the data of the Value at path.
+func readValue(path string) func(*libregf.File) error {
+    return func(file *libregf.File) error {
+        _, err := file.Value(path)
+        return err
+    }
+}
@@ -0,0 +1,149 @@
+package libregf
+
+import (
+    "encoding/binary"
+    "time"
+    "unicode/utf16"
+    "unicode/utf8"
+)
+
+// Layout of REGF files, as documented by libregf in
+// "Windows NT Registry File (REGF) format specification".
+// Offsets of cells are relative to the start of the first hive bin, which
+// comes right after the base block.
+const (
+    baseBlockSize  = 4096
+    hbinHeaderSize = 32
+    hbinAlignment  = 4096
+    cellAlignment  = 8
+
+    nkHeaderSize = 76
+    vkHeaderSize = 20
+    skHeaderSize = 20
+    dbHeaderSize = 12
+
+    // Largest chunk of value data stored in a single cell, beyond which
+    // hives of version 1.4 and later use a big data (db) record.
+    bigDataSegmentSize = 16344
+
+    noOffset = 0xffffffff
+)
+
+// Flags of key (nk) records.
+const (
+    keyFlagVolatile       = 0x0001
+    keyFlagHiveExit       = 0x0002
+    keyFlagHiveEntry      = 0x0004
+    keyFlagNoDelete       = 0x0008
+    keyFlagSymLink        = 0x0010
+    keyFlagCompressedName = 0x0020
+)
+
+// Flags of value (vk) records.
+const (
+    valueFlagCompressedName = 0x0001
+)
+
+// Value data of at most 4 bytes is stored in the data offset field of the
+// value (vk) record, which is flagged by this bit in the data size field.
+const valueDataInline = 0x80000000
+
+var le = binary.LittleEndian
+
+// FILETIMEs count 100 nanoseconds intervals since January 1, 1601 UTC;
+// this is how many seconds there are from then up to the Unix epoch.
+const filetimeUnixEpoch = 11644473600
+
+// FiletimeToTime converts a FILETIME, as stored in registry files and in the
+// data of many Values, to a time.Time in UTC. A zero FILETIME gives a zero
+// time.Time. Seconds and the remaining intervals are converted apart, as
+// FILETIMEs cover about 58000 years while a time.Duration only covers 292, so
+// that garbage or out of range FILETIMEs give their real date rather than a
+// plausible wrong one.
+func FiletimeToTime(ft uint64) time.Time {
+    if ft == 0 { return time.Time{} }
+    return time.Unix(int64(ft/1e7)-filetimeUnixEpoch, int64(ft%1e7)*100).UTC()
+}
+
+// timeToFiletime converts a time.Time to a FILETIME. A zero time.Time, or one
+// before 1601, gives a zero FILETIME.
+func timeToFiletime(t time.Time) uint64 {
+    if t.IsZero() || t.Unix() < -filetimeUnixEpoch { return 0 }
+    return uint64(t.Unix()+filetimeUnixEpoch)*1e7 + uint64(t.Nanosecond()/100)
+}
+
+// baseBlockChecksum computes the XOR-32 checksum stored at offset 508 of the
+// base block, over the 508 bytes that precede it.
+func baseBlockChecksum(block []byte) uint32 {
+    var sum uint32
+    for i := 0; i < 508; i += 4 {
+        sum ^= le.Uint32(block[i:])
+    }
+    if sum == 0 { return 1 }
+    if sum == 0xffffffff { return 0xfffffffe }
+    return sum
+}
+
+// decodeUTF16 decodes UTF-16LE bytes into a Go string, up to the first NUL.
+func decodeUTF16(b []byte) string {
+    u := make([]uint16, 0, len(b)/2)
+    for i := 0; i+1 < len(b); i += 2 {
+        c := le.Uint16(b[i:])
+        if c == 0 { break }
+        u = append(u, c)
+    }
+    return string(utf16.Decode(u))
+}
+
+// encodeUTF16 encodes a Go string as UTF-16LE bytes, without a terminating NUL.
+func encodeUTF16(s string) []byte {
+    return encodeUTF16Units(utf16.Encode([]rune(s)))
+}
+
+// decodeMultiUTF16 decodes a UTF-16LE REG_MULTI_SZ into its strings. The list
+// ends at the first empty string or at the end of the data.
+func decodeMultiUTF16(b []byte) []string {
+    strs := []string{}
+    start := 0
+    for i := 0; i+1 < len(b); i += 2 {
+        if le.Uint16(b[i:]) != 0 { continue }
+        if i == start { break }
+        strs = append(strs, decodeUTF16(b[start:i]))
+        start = i + 2
+    }
+    if start+1 < len(b) && le.Uint16(b[start:]) != 0 {
+        strs = append(strs, decodeUTF16(b[start:]))
+    }
+    return strs
+}
+
+// decodeCompressedName decodes a name stored in the compressed (one byte per
+// character) form, using table, one of the single byte codepages.
+func decodeCompressedName(b []byte, table *[128]rune) string {
+    buf := make([]byte, 0, len(b))
+    for _, c := range b {
+        r := rune(c)
+        if c >= 0x80 { r = table[c-0x80] }
+        buf = utf8.AppendRune(buf, r)
+    }
+    return string(buf)
+}
+
+// decodeUTF16Units returns the UTF-16 code units of UTF-16LE bytes, without
+// checking that surrogates are paired.
+func decodeUTF16Units(b []byte) []uint16 {
+    u := make([]uint16, len(b)/2)
+    for i := range u {
+        u[i] = le.Uint16(b[2*i:])
+    }
+    return u
+}
+
+// encodeUTF16Units encodes UTF-16 code units as UTF-16LE bytes.
+func encodeUTF16Units(u []uint16) []byte {
+    b := make([]byte, 2*len(u))
+    for i, c := range u {
+        le.PutUint16(b[2*i:], c)
+    }
+    return b
+}
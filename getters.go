@@ -0,0 +1,184 @@
+package libregf
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+var (
+    // ErrNotFound is returned when looking up a Key or a Value that doesn't exist.
+    ErrNotFound = errors.New("libregf: not found")
+
+    // ErrWrongType is returned by the typed getters when a Value isn't of the
+    // requested type.
+    ErrWrongType = errors.New("libregf: wrong value type")
+)
+
+// Getable lists the Go types that Get and GetOr can return.
+type Getable interface {
+    string | []string | []byte | uint32 | uint64
+}
+
+// Get returns the Value of key named name, converted to T:
+//
+//   - string for REG_SZ and REG_EXPAND_SZ Values, left unexpanded;
+//   - []string for REG_MULTI_SZ Values;
+//   - []byte for REG_BINARY, REG_NONE and the resource list Values;
+//   - uint32 for REG_DWORD Values, of either endianness;
+//   - uint64 for REG_QWORD Values, and for REG_DWORD Values which fit anyway.
+//
+// It fails with ErrNotFound when there is no such Value, and with ErrWrongType
+// when the Value is of another type.
+func Get[T Getable](key RegistryKey, name string) (T, error) {
+    var t T
+
+    value, err := key.GetValue(name)
+    if err != nil { return t, err }
+    defer release(value)
+
+    err = getValue(value, &t)
+    return t, err
+}
+
+// GetOr is Get, but returns def instead of failing.
+func GetOr[T Getable](key RegistryKey, name string, def T) T {
+    t, err := Get[T](key, name)
+    if err != nil { return def }
+    return t
+}
+
+// HiveGet is Get for a Value by its path inside the registry, considering
+// that the last part of the path is the Value's name, as File.Value does.
+func HiveGet[T Getable](h Hive, path string) (T, error) {
+    var t T
+
+    key, value, err := lookupValue(h, path)
+    if err != nil { return t, err }
+    defer release(key)
+    defer release(value)
+
+    err = getValue(value, &t)
+    return t, err
+}
+
+// HiveGetOr is HiveGet, but returns def instead of failing.
+func HiveGetOr[T Getable](h Hive, path string, def T) T {
+    t, err := HiveGet[T](h, path)
+    if err != nil { return def }
+    return t
+}
+
+// lookupValue returns the Value by its path inside the registry, along with
+// its Key, which must be released too.
+func lookupValue(h Hive, path string) (RegistryKey, RegistryValue, error) {
+    parts := strings.Split(path, "\\")
+    l := len(parts)
+    k := strings.Join(parts[:l-1], "\\")
+    v := parts[l-1]
+    key, err := h.GetKey(k)
+    if err != nil { return nil, nil, err }
+    value, err := key.GetValue(v)
+    if err != nil { release(key); return nil, nil, err }
+
+    return key, value, nil
+}
+
+// getValue converts value into *t, which is one of the Getable types.
+func getValue(value RegistryValue, t interface{}) error {
+    _type, err := value.Type()
+    if err != nil { return err }
+
+    wrongType := func() error {
+        name, _ := value.Name()
+        return fmt.Errorf("%w: value %q is of type %d", ErrWrongType, name, _type)
+    }
+
+    switch t := t.(type) {
+    case *string:
+        if _type != ValueTypeString && _type != ValueTypeExpandableString { return wrongType() }
+        s, err := value.TString()
+        if err != nil { return err }
+        *t = s
+    case *[]string:
+        if _type != ValueTypeMultiValueString { return wrongType() }
+        strs, err := value.TStrings()
+        if err != nil { return err }
+        *t = strs
+    case *[]byte:
+        switch _type {
+        case ValueTypeUndefined, ValueTypeBinaryData, ValueTypeResourceList, ValueTypeFullResourceDescriptor, ValueTypeResourceRequirementsList:
+        default:
+            return wrongType()
+        }
+        data, err := value.Data()
+        if err != nil { return err }
+        *t = data
+    case *uint32:
+        if _type != ValueTypeInteger32BitLittleEndian && _type != ValueTypeInteger32BitBigEndian { return wrongType() }
+        data, err := value.Data()
+        if err != nil { return err }
+        u, ok := valueUint(_type, data)
+        if !ok { return fmt.Errorf("libregf: invalid 32-bit value data size %d", len(data)) }
+        *t = uint32(u)
+    case *uint64:
+        if _type != ValueTypeInteger64BitLittleEndian && _type != ValueTypeInteger32BitLittleEndian && _type != ValueTypeInteger32BitBigEndian { return wrongType() }
+        data, err := value.Data()
+        if err != nil { return err }
+        u, ok := valueUint(_type, data)
+        if !ok { return fmt.Errorf("libregf: invalid integer value data size %d", len(data)) }
+        *t = u
+    }
+
+    return nil
+}
+
+// GetString returns a REG_SZ or REG_EXPAND_SZ Value of the Key by its name.
+func (key *Key) GetString(name string) (string, error) {
+    return Get[string](key, name)
+}
+
+// GetStrings returns a REG_MULTI_SZ Value of the Key by its name.
+func (key *Key) GetStrings(name string) ([]string, error) {
+    return Get[[]string](key, name)
+}
+
+// GetBytes returns a REG_BINARY (or similar) Value of the Key by its name.
+func (key *Key) GetBytes(name string) ([]byte, error) {
+    return Get[[]byte](key, name)
+}
+
+// GetUint32 returns a REG_DWORD Value of the Key by its name.
+func (key *Key) GetUint32(name string) (uint32, error) {
+    return Get[uint32](key, name)
+}
+
+// GetUint64 returns a REG_QWORD (or REG_DWORD) Value of the Key by its name.
+func (key *Key) GetUint64(name string) (uint64, error) {
+    return Get[uint64](key, name)
+}
+
+// GetString returns a REG_SZ or REG_EXPAND_SZ Value by its path inside the registry.
+func (file *File) GetString(path string) (string, error) {
+    return HiveGet[string](file, path)
+}
+
+// GetStrings returns a REG_MULTI_SZ Value by its path inside the registry.
+func (file *File) GetStrings(path string) ([]string, error) {
+    return HiveGet[[]string](file, path)
+}
+
+// GetBytes returns a REG_BINARY (or similar) Value by its path inside the registry.
+func (file *File) GetBytes(path string) ([]byte, error) {
+    return HiveGet[[]byte](file, path)
+}
+
+// GetUint32 returns a REG_DWORD Value by its path inside the registry.
+func (file *File) GetUint32(path string) (uint32, error) {
+    return HiveGet[uint32](file, path)
+}
+
+// GetUint64 returns a REG_QWORD (or REG_DWORD) Value by its path inside the registry.
+func (file *File) GetUint64(path string) (uint64, error) {
+    return HiveGet[uint64](file, path)
+}
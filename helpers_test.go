@@ -0,0 +1,37 @@
+package libregf_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// openHive builds the registry file described by h in a temporary directory
+// and opens it, closing it once the test is over.
+func openHive(t testing.TB, h *regftest.Hive, opts ...libregf.Option) *libregf.File {
+    t.Helper()
+
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatalf("building hive: %v", err) }
+
+    file, err := libregf.OpenFile(path, opts...)
+    if err != nil { t.Fatalf("opening hive: %v", err) }
+    t.Cleanup(file.Close)
+
+    return file
+}
+
+// keyPaths returns the paths of all of the Keys of h, in the order Walk visits them.
+func keyPaths(t testing.TB, h libregf.Hive) []string {
+    t.Helper()
+
+    paths := []string{}
+    err := libregf.Walk(h, func(path string, key libregf.RegistryKey) error {
+        paths = append(paths, path)
+        return nil
+    })
+    if err != nil { t.Fatalf("walking hive: %v", err) }
+
+    return paths
+}
@@ -0,0 +1,273 @@
+package libregf
+
+import (
+    "context"
+    "strings"
+    "unicode"
+    "unicode/utf16"
+)
+
+// NameIssue is a set of reasons why the name of a Key or a Value looks hidden
+// or anomalous, as reported by CheckName and AnalyzeNames.
+type NameIssue int
+
+const (
+    // NameNul is set for names containing NUL characters. Tools built on the
+    // Windows API, regedit included, stop reading a name at its first NUL, so
+    // a leading one, as Poweliks uses, hides the whole name.
+    NameNul NameIssue = 1 << iota
+    // NameControl is set for names containing other control characters.
+    NameControl
+    // NameNonPrintable is set for names containing invisible or non printable
+    // characters, such as zero width spaces, direction overrides or private use characters.
+    NameNonPrintable
+    // NameInvalidUTF16 is set for names containing unpaired surrogates.
+    NameInvalidUTF16
+    // NameWhitespace is set for names starting or ending with white space,
+    // which makes them look like other names.
+    NameWhitespace
+    // NameTooLong is set for names longer than Windows allows: 255 characters
+    // for Keys and 16383 for Values.
+    NameTooLong
+    // NameEmpty is set for Keys, other than the root Key, with an empty name.
+    NameEmpty
+    // NameSeparator is set for Keys whose name contains a "\".
+    NameSeparator
+    // NameDuplicate is set for names used more than once in the same Key.
+    NameDuplicate
+    // NameDefaultValue is set for default Values (those with an empty name)
+    // which are not strings, whose data is larger than DefaultValueMaxSize, or
+    // which can't be read.
+    NameDefaultValue
+)
+
+// DefaultValueMaxSize is the size of data above which AnalyzeNames reports a
+// default Value, as payloads stored there tend to be large.
+const DefaultValueMaxSize = 1024
+
+var nameIssueNames = []string{
+    "nul", "control", "non-printable", "invalid-utf16", "whitespace",
+    "too-long", "empty", "separator", "duplicate", "default-value",
+}
+
+// String returns the names of the issues, separated by commas.
+func (issues NameIssue) String() string {
+    names := []string{}
+    for i, name := range nameIssueNames {
+        if issues&(1<<i) != 0 { names = append(names, name) }
+    }
+    return strings.Join(names, ",")
+}
+
+// CheckName returns the issues found in a name, given as UTF-16 code units
+// (see UTF16Name), of a Key when key is true or of a Value otherwise.
+// It doesn't report NameEmpty, NameDuplicate and NameDefaultValue, which
+// depend on where the name is found.
+func CheckName(name []uint16, key bool) NameIssue {
+    var issues NameIssue
+
+    max := maxValueNameLen
+    if key { max = maxKeyNameLen }
+    if len(name) > max { issues |= NameTooLong }
+
+    for i := 0; i < len(name); i++ {
+        r := rune(name[i])
+        if utf16.IsSurrogate(r) {
+            if r < 0xdc00 && i+1 < len(name) && name[i+1] >= 0xdc00 && name[i+1] < 0xe000 {
+                r = utf16.DecodeRune(r, rune(name[i+1]))
+                i++
+            } else {
+                issues |= NameInvalidUTF16
+                continue
+            }
+        }
+
+        switch {
+        case r == 0:
+            issues |= NameNul
+        case unicode.IsControl(r):
+            issues |= NameControl
+        case r == '\\' && key:
+            issues |= NameSeparator
+        case !unicode.IsGraphic(r):
+            issues |= NameNonPrintable
+        }
+    }
+
+    if len(name) > 0 && (isSpaceUnit(name[0]) || isSpaceUnit(name[len(name)-1])) {
+        issues |= NameWhitespace
+    }
+
+    return issues
+}
+
+func isSpaceUnit(c uint16) bool {
+    return !utf16.IsSurrogate(rune(c)) && unicode.IsSpace(rune(c))
+}
+
+// NameFinding is a Key or a Value whose name looks hidden or anomalous.
+type NameFinding struct {
+    Path    string    // path of the Key
+    Value   bool      // whether the finding is about a Value of the Key, or about the Key itself
+    Name    string    // name of the Key or the Value, as decoded by Name()
+    RawName []byte    // name as stored in the file, see RawName()
+    Issues  NameIssue // what is wrong with the name
+}
+
+// rawNamer is implemented by Keys and Values that give access to their names as
+// stored in the file, such as *Key and *Value.
+type rawNamer interface {
+    RawName() ([]byte, error)
+    NameIsCompressed() (bool, error)
+    UTF16Name() ([]uint16, error)
+    owner() *File
+}
+
+func (key *Key) owner() *File { return key.file }
+
+func (value *Value) owner() *File { return value.file }
+
+// rawNames returns the name of x as UTF-16 code units and as stored in the file,
+// falling back on its decoded name for implementations without raw names.
+// Code units are decoded from the stored name, since libregf stops decoding
+// names at their first NUL, which would hide the very names looked for.
+func rawNames(x interface{ Name() (string, error) }) (string, []uint16, []byte, error) {
+    name, err := x.Name()
+    if err != nil { return "", nil, nil, err }
+
+    if rn, ok := x.(rawNamer); ok {
+        raw, err := rn.RawName()
+        if err != nil { return "", nil, nil, err }
+        compressed, err := rn.NameIsCompressed()
+        if err != nil { return "", nil, nil, err }
+        if !compressed { return name, decodeUTF16Units(raw), raw, nil }
+
+        codepage, err := rn.owner().Codepage()
+        if err != nil { return "", nil, nil, err }
+        if table, ok := codepages[codepage]; ok {
+            return name, utf16.Encode([]rune(decodeCompressedName(raw, table))), raw, nil
+        }
+
+        // only libregf knows the double byte codepages
+        units, err := rn.UTF16Name()
+        if err != nil { return "", nil, nil, err }
+        return name, units, raw, nil
+    }
+
+    units := utf16.Encode([]rune(name))
+    return name, units, encodeUTF16Units(units), nil
+}
+
+// AnalyzeNames walks a registry file looking for Keys and Values whose names
+// look hidden or anomalous, as malware uses to hide from regedit.
+func (file *File) AnalyzeNames() ([]NameFinding, error) {
+    return AnalyzeNamesContext(context.Background(), file)
+}
+
+// AnalyzeNames is File.AnalyzeNames for any Hive.
+func AnalyzeNames(h Hive) ([]NameFinding, error) {
+    return AnalyzeNamesContext(context.Background(), h)
+}
+
+// AnalyzeNamesContext is AnalyzeNames, but stops as soon as ctx is cancelled.
+// In that case it returns ctx.Err() along with the findings so far.
+func AnalyzeNamesContext(ctx context.Context, h Hive) ([]NameFinding, error) {
+    findings := []NameFinding{}
+
+    err := WalkContext(ctx, h, func(path string, key RegistryKey) error {
+        if path != "" {
+            name, units, raw, err := rawNames(key)
+            if err != nil { return err }
+
+            issues := CheckName(units, true)
+            if len(units) == 0 { issues |= NameEmpty }
+            if issues != 0 {
+                findings = append(findings, NameFinding{Path: path, Name: name, RawName: raw, Issues: issues})
+            }
+        }
+
+        found, err := analyzeSubkeyNames(path, key)
+        if err != nil { return err }
+        findings = append(findings, found...)
+
+        found, err = analyzeValueNames(ctx, path, key)
+        if err != nil { return err }
+        findings = append(findings, found...)
+
+        return nil
+    })
+
+    return findings, err
+}
+
+// analyzeSubkeyNames reports the sub keys of key that share a name.
+func analyzeSubkeyNames(path string, key RegistryKey) ([]NameFinding, error) {
+    n, err := key.SubkeysLen()
+    if err != nil { return nil, err }
+
+    findings := []NameFinding{}
+    seen := make(map[string]bool, n)
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return nil, err }
+        name, units, raw, err := rawNames(subkey)
+        release(subkey)
+        if err != nil { return nil, err }
+
+        upcased := string(encodeUTF16Units(upcaseUnits(units)))
+        if seen[upcased] {
+            findings = append(findings, NameFinding{Path: joinPath(path, name), Name: name, RawName: raw, Issues: NameDuplicate})
+        }
+        seen[upcased] = true
+    }
+
+    return findings, nil
+}
+
+// analyzeValueNames reports the Values of key whose names look hidden or
+// anomalous, along with suspicious default Values.
+func analyzeValueNames(ctx context.Context, path string, key RegistryKey) ([]NameFinding, error) {
+    n, err := key.ValuesLen()
+    if err != nil { return nil, err }
+
+    findings := []NameFinding{}
+    seen := make(map[string]bool, n)
+    for i := 0; i < n; i++ {
+        if err := ctx.Err(); err != nil { return nil, err }
+
+        value, err := key.GetValueAt(i)
+        if err != nil { return nil, err }
+        name, units, raw, err := rawNames(value)
+        if err != nil { release(value); return nil, err }
+
+        issues := CheckName(units, false)
+        upcased := string(encodeUTF16Units(upcaseUnits(units)))
+        if seen[upcased] { issues |= NameDuplicate }
+        seen[upcased] = true
+
+        if len(units) == 0 && isSuspiciousDefault(value) { issues |= NameDefaultValue }
+        release(value)
+
+        if issues != 0 {
+            findings = append(findings, NameFinding{Path: path, Value: true, Name: name, RawName: raw, Issues: issues})
+        }
+    }
+
+    return findings, nil
+}
+
+// isSuspiciousDefault tells whether a default Value is not a string, holds more
+// data than DefaultValueMaxSize, or can't be read. Its size is taken from
+// DataLen() when the Value has it, so that its data isn't read at all: a
+// default Value padded past WithMaxValueSize mustn't stop the analysis.
+func isSuspiciousDefault(value RegistryValue) bool {
+    _type, err := value.Type()
+    if err != nil || !isStringType(_type) { return true }
+
+    if dl, ok := value.(interface{ DataLen() (int, error) }); ok {
+        size, err := dl.DataLen()
+        return err != nil || size > DefaultValueMaxSize
+    }
+    data, err := value.Data()
+    return err != nil || len(data) > DefaultValueMaxSize
+}
@@ -0,0 +1,88 @@
+package libregf_test
+
+import (
+    "strings"
+    "testing"
+    "unicode/utf16"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+func TestAnalyzeNames(t *testing.T) {
+    h := &regftest.Hive{Keys: []regftest.Key{
+        {Path: "Software\\\x00Hidden", Values: []regftest.Value{{Name: "\x00Run", Type: "REG_SZ", String: "rundll32.exe"}}},
+        {Path: "Software\\Zero\u200bWidth", Values: []regftest.Value{{Name: "\u200b", Type: "REG_SZ", String: "x"}}},
+        {Path: "Software\\Größe\x00", Values: []regftest.Value{{Name: "", Type: "REG_BINARY", Size: 4096}}},
+        {Path: "Software\\Clean", Values: []regftest.Value{{Name: "Run", Type: "REG_SZ", String: "ok"}}},
+    }}
+    file := openHive(t, h)
+
+    findings, err := file.AnalyzeNames()
+    if err != nil { t.Fatal(err) }
+
+    // names with characters above U+00FF are stored in UTF-16LE, the others
+    // compressed, one byte per character
+    type finding struct {
+        value  bool
+        raw    string
+        issues libregf.NameIssue
+    }
+    want := map[finding]bool{
+        {false, "\x00Hidden", libregf.NameNul}:                        true,
+        {true, "\x00Run", libregf.NameNul}:                            true,
+        {false, utf16LE("Zero\u200bWidth"), libregf.NameNonPrintable}: true,
+        {true, utf16LE("\u200b"), libregf.NameNonPrintable}:           true,
+        {false, "Gr\xf6\xdfe\x00", libregf.NameNul}:                   true,
+        {true, "", libregf.NameDefaultValue}:                          true,
+    }
+    for _, f := range findings {
+        key := finding{f.Value, string(f.RawName), f.Issues}
+        if !want[key] {
+            t.Errorf("unexpected finding: %s %q %v", f.Path, f.RawName, f.Issues)
+            continue
+        }
+        delete(want, key)
+    }
+    for f := range want {
+        t.Errorf("missing finding: %q %v", f.raw, f.issues)
+    }
+}
+
+// Default Values padded past WithMaxValueSize, or corrupted, are reported
+// rather than stopping the analysis.
+func TestAnalyzeNamesUnreadableDefault(t *testing.T) {
+    h := &regftest.Hive{
+        Keys: []regftest.Key{
+            {Path: "Padded", Values: []regftest.Value{{Name: "", Type: "REG_SZ", String: strings.Repeat("padding ", 1000)}}},
+            {Path: "Corrupted", Values: []regftest.Value{{Name: "", Type: "REG_SZ", String: "x"}}},
+            {Path: "Software", Values: []regftest.Value{{Name: "\x00Run", Type: "REG_SZ", String: "rundll32.exe"}}},
+        },
+        Corruptions: []regftest.Corruption{{Path: "Corrupted", DefaultValue: true, Kind: regftest.BadDataSize}},
+    }
+    file := openHive(t, h, libregf.WithMaxValueSize(4096))
+
+    findings, err := file.AnalyzeNames()
+    if err != nil { t.Fatal(err) }
+
+    got := map[string]libregf.NameIssue{}
+    for _, f := range findings {
+        got[f.Path+"\\"+f.Name] = f.Issues
+    }
+    for path, want := range map[string]libregf.NameIssue{
+        "Padded\\":           libregf.NameDefaultValue,
+        "Corrupted\\":        libregf.NameDefaultValue,
+        "Software\\\x00Run": libregf.NameNul,
+    } {
+        if got[path] != want { t.Errorf("%q: got %v, want %v", path, got[path], want) }
+    }
+}
+
+// utf16LE encodes s in UTF-16LE.
+func utf16LE(s string) string {
+    b := []byte{}
+    for _, u := range utf16.Encode([]rune(s)) {
+        b = append(b, byte(u), byte(u>>8))
+    }
+    return string(b)
+}
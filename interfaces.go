@@ -0,0 +1,90 @@
+package libregf
+
+// Hive is anything registry Keys can be read from. A File is a Hive, but so
+// can be a .reg file parser, a live registry export or a test fixture.
+// Paths are relative to the root Key and use "\" as separator.
+type Hive interface {
+    GetRootKey() (RegistryKey, error)
+    GetKey(path string) (RegistryKey, error)
+}
+
+// RegistryKey is the read-only view of a registry Key that helpers such as
+// Walk and HiveValue work with. A *Key is a RegistryKey.
+type RegistryKey interface {
+    Name() (string, error)
+    ClassName() (string, error)
+    ValuesLen() (int, error)
+    GetValueAt(index int) (RegistryValue, error)
+    GetValue(name string) (RegistryValue, error)
+    SubkeysLen() (int, error)
+    GetSubkeyAt(index int) (RegistryKey, error)
+    GetSubkey(name string) (RegistryKey, error)
+}
+
+// RegistryValue is the read-only view of a registry Value that helpers such as
+// FormatValue work with. A *Value is a RegistryValue.
+// The typed getters fail when the Value is not of the matching type.
+type RegistryValue interface {
+    Name() (string, error)
+    Type() (int, error)
+    Data() ([]byte, error)
+    TString() (string, error)
+    TStrings() ([]string, error)
+    TBinary() ([]byte, error)
+    Tint32() (int, error)
+    Tint64() (int, error)
+}
+
+// release frees a RegistryKey or RegistryValue whose implementation holds on to
+// memory that the garbage collector doesn't know about, as the libregf backend does.
+func release(x interface{}) {
+    if f, ok := x.(interface{ Free() error }); ok { f.Free() }
+}
+
+// GetRootKey returns the root Key of a registry file as a RegistryKey.
+func (file *File) GetRootKey() (RegistryKey, error) {
+    key, err := file.RootKey()
+    if err != nil { return nil, err }
+    return key, nil
+}
+
+// GetKey returns a Key by its path inside the registry as a RegistryKey.
+func (file *File) GetKey(path string) (RegistryKey, error) {
+    key, err := file.Key(path)
+    if err != nil { return nil, err }
+    return key, nil
+}
+
+// GetValueAt returns the Value at a given position inside a Key as a RegistryValue.
+func (key *Key) GetValueAt(index int) (RegistryValue, error) {
+    value, err := key.ValueAt(index)
+    if err != nil { return nil, err }
+    return value, nil
+}
+
+// GetValue returns the Value present inside a Key by its name as a RegistryValue.
+func (key *Key) GetValue(name string) (RegistryValue, error) {
+    value, err := key.Value(name)
+    if err != nil { return nil, err }
+    return value, nil
+}
+
+// GetSubkeyAt returns the Key at a given position inside another Key as a RegistryKey.
+func (key *Key) GetSubkeyAt(index int) (RegistryKey, error) {
+    subkey, err := key.SubkeyAt(index)
+    if err != nil { return nil, err }
+    return subkey, nil
+}
+
+// GetSubkey returns the Key present inside another Key by its name as a RegistryKey.
+func (key *Key) GetSubkey(name string) (RegistryKey, error) {
+    subkey, err := key.SubkeyByName(name)
+    if err != nil { return nil, err }
+    return subkey, nil
+}
+
+var (
+    _ Hive          = (*File)(nil)
+    _ RegistryKey   = (*Key)(nil)
+    _ RegistryValue = (*Value)(nil)
+)
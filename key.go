@@ -1,3 +1,5 @@
+//go:build cgo && !purego
+
 package libregf
 
 /*
@@ -7,12 +9,17 @@ package libregf
 import "C"
 
 import (
+    "bytes"
     "fmt"
+    "time"
     "unsafe"
 )
 
-// Key is an opaque struct to group related method calls
-type Key   C.libregf_key_t
+// Key is a registry key read by libregf, along with the File it belongs to.
+type Key struct {
+    ptr  *C.libregf_key_t
+    file *File
+}
 
 // Free frees memory allocated in C for the hidden Key struct.
 // It wraps libregf_key_free().
@@ -20,7 +27,7 @@ func (key *Key) Free() error {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_free((**C.libregf_key_t)(unsafe.Pointer(&key)), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_free(&key.ptr, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -41,7 +48,7 @@ func (key *Key) NameLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_utf8_name_size((*C.libregf_key_t)(key), (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_utf8_name_size(key.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -59,6 +66,7 @@ func (key *Key) NameLen() (int, error) {
 func (key *Key) Name() (string, error) { 
     namelen, err := key.NameLen()
     if err != nil { return "", err }
+    if namelen == 0 { return "", nil }
 
     buffer := make([]byte, namelen+1)
     cstr := C.CString(string(buffer[:namelen]))
@@ -66,7 +74,7 @@ func (key *Key) Name() (string, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_utf8_name((*C.libregf_key_t)(key), (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_utf8_name(key.ptr, (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -77,21 +85,125 @@ func (key *Key) Name() (string, error) {
     }
 }
 
+// RawNameLen returns the length (in bytes) of the Key's name as stored in the file.
+// It wraps libregf_key_get_name_size().
+// You don't need to call this function if you call RawName(), which calls RawNameLen().
+func (key *Key) RawNameLen() (int, error) { 
+    var namelen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_name_size(key.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(namelen), nil
+    }
+}
+
+// RawName returns the Key's name as stored in the file, without decoding it:
+// either UTF-16LE or, when NameIsCompressed() is true, one byte per character
+// in the codepage of the file. Unlike Name(), it preserves names that don't
+// decode properly, as found when names are hidden on purpose.
+// It wraps libregf_key_get_name().
+func (key *Key) RawName() ([]byte, error) { 
+    namelen, err := key.RawNameLen()
+    if err != nil { return []byte{}, err }
+    if namelen == 0 { return []byte{}, nil }
+
+    buffer := make([]byte, namelen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_name(key.ptr, (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []byte{}, fmt.Errorf("%s", pe.String())
+    } else {
+        return buffer, nil
+    }
+}
+
+// UTF16NameLen returns the length (in UTF-16 code units) of the Key's name,
+// including a terminating NUL.
+// It wraps libregf_key_get_utf16_name_size().
+// You don't need to call this function if you call UTF16Name(), which calls UTF16NameLen().
+func (key *Key) UTF16NameLen() (int, error) { 
+    var namelen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_utf16_name_size(key.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(namelen), nil
+    }
+}
+
+// UTF16Name returns the Key's name as UTF-16 code units, without a terminating
+// NUL, decoding compressed names with the codepage of the file.
+// It wraps libregf_key_get_utf16_name().
+func (key *Key) UTF16Name() ([]uint16, error) { 
+    namelen, err := key.UTF16NameLen()
+    if err != nil { return []uint16{}, err }
+    if namelen == 0 { return []uint16{}, nil }
+
+    buffer := make([]uint16, namelen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_utf16_name(key.ptr, (*C.uint16_t)(unsafe.Pointer(&buffer[0])), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []uint16{}, fmt.Errorf("%s", pe.String())
+    } else {
+        if buffer[namelen-1] == 0 { buffer = buffer[:namelen-1] }
+        return buffer, nil
+    }
+}
+
+// NameIsCompressed tells whether the Key's name is stored in the compressed
+// (one byte per character) form rather than in UTF-16LE.
+// libregf doesn't expose the flag, so this compares RawName() to UTF16Name().
+func (key *Key) NameIsCompressed() (bool, error) {
+    raw, err := key.RawName()
+    if err != nil { return false, err }
+    units, err := key.UTF16Name()
+    if err != nil { return false, err }
+
+    return !bytes.Equal(raw, encodeUTF16Units(units)), nil
+}
+
 // ClassNameLen returns the length (in bytes) of the Key's class name.
 // It wraps libregf_key_get_utf8_class_name_size().
 // You usually call it to know how much space to allocate before calling
 // libregf_key_get_utf8_class_name().
+// It returns 0 for Keys without a class name.
 // You don't need to call this function if you call ClassName(), which calls ClassNameLen().
 func (key *Key) ClassNameLen() (int, error) { 
     var namelen C.size_t
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_utf8_class_name_size((*C.libregf_key_t)(key), (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_utf8_class_name_size(key.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
-    if res != 1 {
+    if res == 0 {
+        // the Key has no class name
+        return 0, nil
+    } else if res != 1 {
         return -1, fmt.Errorf("%s", pe.String())
     } else {
         return int(namelen), nil
@@ -105,6 +217,7 @@ func (key *Key) ClassNameLen() (int, error) {
 func (key *Key) ClassName() (string, error) { 
     namelen, err := key.ClassNameLen()
     if err != nil { return "", err }
+    if namelen == 0 { return "", nil }
 
     buffer := make([]byte, namelen+1)
     cstr := C.CString(string(buffer[:namelen]))
@@ -112,7 +225,7 @@ func (key *Key) ClassName() (string, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_utf8_class_name((*C.libregf_key_t)(key), (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_utf8_class_name(key.ptr, (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -123,6 +236,88 @@ func (key *Key) ClassName() (string, error) {
     }
 }
 
+// LastWritten returns the time the Key was last written to.
+// It wraps libregf_key_get_last_written_time().
+func (key *Key) LastWritten() (time.Time, error) { 
+    var filetime C.uint64_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_last_written_time(key.ptr, &filetime, (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return time.Time{}, fmt.Errorf("%s", pe.String())
+    } else {
+        return FiletimeToTime(uint64(filetime)), nil
+    }
+}
+
+// Offset returns the offset of the Key's record inside the registry file.
+// It wraps libregf_key_get_offset().
+func (key *Key) Offset() (int64, error) { 
+    var offset C.off64_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_offset(key.ptr, &offset, (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int64(offset), nil
+    }
+}
+
+// SecurityDescriptorLen returns the length (in bytes) of the Key's security descriptor.
+// It wraps libregf_key_get_security_descriptor_size().
+// It returns 0 for Keys without a security descriptor.
+// You don't need to call this function if you call SecurityDescriptor(), which calls SecurityDescriptorLen().
+func (key *Key) SecurityDescriptorLen() (int, error) { 
+    var sdlen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_security_descriptor_size(key.ptr, (*C.size_t)(&sdlen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res == 0 {
+        // the Key has no security descriptor
+        return 0, nil
+    } else if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(sdlen), nil
+    }
+}
+
+// SecurityDescriptor returns the Key's security descriptor, in the self-relative
+// binary form of the Windows API.
+// It wraps libregf_key_get_security_descriptor().
+func (key *Key) SecurityDescriptor() ([]byte, error) { 
+    sdlen, err := key.SecurityDescriptorLen()
+    if err != nil { return []byte{}, err }
+    if sdlen == 0 { return []byte{}, nil }
+
+    buffer := make([]byte, sdlen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_key_get_security_descriptor(key.ptr, (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(sdlen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []byte{}, fmt.Errorf("%s", pe.String())
+    } else {
+        return buffer, nil
+    }
+}
+
 // ValuesLen returns the number of Values present inside a Key.
 // It wraps libregf_key_get_number_of_values().
 func (key *Key) ValuesLen() (int, error) { 
@@ -130,7 +325,7 @@ func (key *Key) ValuesLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_number_of_values((*C.libregf_key_t)(key), &num, (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_number_of_values(key.ptr, &num, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -146,39 +341,38 @@ func (key *Key) ValuesLen() (int, error) {
 func (key *Key) ValueAt(index int) (*Value, error) { 
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
-    var value Value
-    ppvalue := unsafe.Pointer(&value)
+    var pvalue *C.libregf_value_t
 
-    res := int(C.libregf_key_get_value((*C.libregf_key_t)(key), C.int(index), (**C.libregf_value_t)(ppvalue), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_value(key.ptr, C.int(index), &pvalue, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    pvalue := *(**Key)(ppvalue)
 
     if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Value)(pvalue), nil
+        return &Value{ptr: pvalue, file: key.file}, nil
     }
 }
 
 // Value returns the Value present inside a Key by its name.
+// Names are compared the way Windows does, as with SubkeyByName.
 // It wraps libregf_key_get_value_by_utf8_name().
 func (key *Key) Value(path string) (*Value, error) { 
-    var value Value
-    ppvalue := unsafe.Pointer(&value)
+    var pvalue *C.libregf_value_t
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
     bpath := append([]byte(path), 0)
 
-    res := int(C.libregf_key_get_value_by_utf8_name((*C.libregf_key_t)(key), (*C.uint8_t)(unsafe.Pointer(&bpath[0])), C.ulong(len(bpath)-1), (**C.libregf_value_t)(ppvalue), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_value_by_utf8_name(key.ptr, (*C.uint8_t)(unsafe.Pointer(&bpath[0])), C.ulong(len(bpath)-1), &pvalue, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    pvalue := *(**Value)(ppvalue)
 
-    if res != 1 {
+    if res == 0 {
+        return key.lookupValue(path)
+    } else if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Value)(pvalue), nil
+        return &Value{ptr: pvalue, file: key.file}, nil
     }
 }
 
@@ -189,7 +383,7 @@ func (key *Key) SubkeysLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_key_get_number_of_sub_keys((*C.libregf_key_t)(key), &num, (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_number_of_sub_keys(key.ptr, &num, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -205,41 +399,54 @@ func (key *Key) SubkeysLen() (int, error) {
 func (key *Key) SubkeyAt(index int) (*Key, error) { 
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
-    var subkey Key
-    ppsubkey := unsafe.Pointer(&subkey)
+    var psubkey *C.libregf_key_t
 
-    res := int(C.libregf_key_get_sub_key((*C.libregf_key_t)(key), C.int(index), (**C.libregf_key_t)(ppsubkey), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_sub_key(key.ptr, C.int(index), &psubkey, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    psubkey := *(**Key)(ppsubkey)
 
     if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Key)(psubkey), nil
+        return &Key{ptr: psubkey, file: key.file}, nil
     }
 }
 
 // SubkeyByName returns the Key present inside another Key by its name.
+// Names are compared the way Windows does: when libregf doesn't find the name,
+// which it compares by its own rules, the sub keys are compared one by one.
 // It wraps libregf_key_get_sub_key_by_utf8_name().
 func (key *Key) SubkeyByName(name string) (*Key, error) { 
+    if name == "" { return key.lookupSubkey(name) }
+
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
-    var subkey Key
-    ppsubkey := unsafe.Pointer(&subkey)
+    var psubkey *C.libregf_key_t
     bname := []byte(name)
-    //bname = append(bname, 0)
-    fmt.Printf("bname: %v\n", bname)
 
-    res := int(C.libregf_key_get_sub_key_by_utf8_name((*C.libregf_key_t)(key), (*C.uint8_t)(unsafe.Pointer(&bname[0])), C.ulong(len(bname)), (**C.libregf_key_t)(ppsubkey), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_key_get_sub_key_by_utf8_name(key.ptr, (*C.uint8_t)(unsafe.Pointer(&bname[0])), C.ulong(len(bname)), &psubkey, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
-    psubkey := *(**Key)(ppsubkey)
 
-    if res != 1 {
+    if res == 0 {
+        return key.lookupSubkey(name)
+    } else if res != 1 {
         return nil, fmt.Errorf("%s", pe.String())
     } else {
-        return (*Key)(psubkey), nil
+        return &Key{ptr: psubkey, file: key.file}, nil
     }
 }
 
+// lookupSubkey is LookupSubkey returning a *Key.
+func (key *Key) lookupSubkey(name string) (*Key, error) {
+    subkey, err := LookupSubkey(key, name)
+    if err != nil { return nil, err }
+    return subkey.(*Key), nil
+}
+
+// lookupValue is LookupValue returning a *Value.
+func (key *Key) lookupValue(name string) (*Value, error) {
+    value, err := LookupValue(key, name)
+    if err != nil { return nil, err }
+    return value.(*Value), nil
+}
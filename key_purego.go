@@ -0,0 +1,238 @@
+//go:build !cgo || purego
+
+package libregf
+
+import (
+    "fmt"
+    "time"
+    "unicode/utf16"
+)
+
+// Key is a registry key read by the pure Go backend.
+type Key struct {
+    file    *File
+    offset  uint32
+    nk      []byte
+    subkeys []uint32
+    values  []uint32
+}
+
+// key reads the key (nk) record at offset.
+func (file *File) key(offset uint32) (*Key, error) {
+    nk, err := file.hive.record(offset, "nk", nkHeaderSize)
+    if err != nil { return nil, err }
+
+    if nkHeaderSize+int(le.Uint16(nk[72:])) > len(nk) {
+        return nil, regfError("name of key at offset 0x%08x exceeds its cell", offset)
+    }
+
+    return &Key{file: file, offset: offset, nk: nk}, nil
+}
+
+// Free exists for parity with the libregf backend. Keys are garbage collected.
+func (key *Key) Free() error {
+    return nil
+}
+
+// NameLen returns the length (in bytes) of the Key's name, counting a
+// terminating NUL as the libregf backend does.
+// You don't need to call this function if you call Name(), which decodes the name.
+func (key *Key) NameLen() (int, error) {
+    name, err := key.Name()
+    if err != nil { return -1, err }
+
+    return len(name) + 1, nil
+}
+
+// Name returns the Key's name.
+func (key *Key) Name() (string, error) {
+    if key.nameIsCompressed() {
+        return decodeCompressedName(key.rawName(), key.file.table), nil
+    } else {
+        return decodeUTF16(key.rawName()), nil
+    }
+}
+
+// RawNameLen returns the length (in bytes) of the Key's name as stored in the file.
+// You don't need to call this function if you call RawName(), which reads the name.
+func (key *Key) RawNameLen() (int, error) {
+    return int(le.Uint16(key.nk[72:])), nil
+}
+
+// RawName returns the Key's name as stored in the file, without decoding it:
+// either UTF-16LE or, when NameIsCompressed() is true, one byte per character
+// in the codepage of the file. Unlike Name(), it preserves names that don't
+// decode properly, as found when names are hidden on purpose.
+func (key *Key) RawName() ([]byte, error) {
+    return append([]byte{}, key.rawName()...), nil
+}
+
+// UTF16NameLen returns the length (in UTF-16 code units) of the Key's name,
+// counting a terminating NUL as the libregf backend does.
+// You don't need to call this function if you call UTF16Name(), which decodes the name.
+func (key *Key) UTF16NameLen() (int, error) {
+    units, err := key.UTF16Name()
+    if err != nil { return -1, err }
+
+    return len(units) + 1, nil
+}
+
+// UTF16Name returns the Key's name as UTF-16 code units, without a terminating
+// NUL, decoding compressed names with the codepage of the file.
+func (key *Key) UTF16Name() ([]uint16, error) {
+    if key.nameIsCompressed() {
+        return utf16.Encode([]rune(decodeCompressedName(key.rawName(), key.file.table))), nil
+    } else {
+        return decodeUTF16Units(key.rawName()), nil
+    }
+}
+
+// NameIsCompressed tells whether the Key's name is stored in the compressed
+// (one byte per character) form rather than in UTF-16LE.
+func (key *Key) NameIsCompressed() (bool, error) {
+    return key.nameIsCompressed(), nil
+}
+
+func (key *Key) rawName() []byte {
+    return key.nk[nkHeaderSize : nkHeaderSize+int(le.Uint16(key.nk[72:]))]
+}
+
+func (key *Key) nameIsCompressed() bool {
+    return le.Uint16(key.nk[2:])&keyFlagCompressedName != 0
+}
+
+// ClassNameLen returns the length (in bytes) of the Key's class name, counting
+// a terminating NUL as the libregf backend does.
+// It returns 0 for Keys without a class name.
+// You don't need to call this function if you call ClassName(), which decodes the class name.
+func (key *Key) ClassNameLen() (int, error) {
+    name, err := key.ClassName()
+    if err != nil { return -1, err }
+    if name == "" { return 0, nil }
+
+    return len(name) + 1, nil
+}
+
+// ClassName returns the Key's class name.
+func (key *Key) ClassName() (string, error) {
+    offset := le.Uint32(key.nk[48:])
+    l := int(le.Uint16(key.nk[74:]))
+    if offset == noOffset || l == 0 { return "", nil }
+
+    data, err := key.file.hive.cell(offset)
+    if err != nil { return "", err }
+    if l > len(data) { return "", regfError("class name of key at offset 0x%08x exceeds its cell", key.offset) }
+
+    return decodeUTF16(data[:l]), nil
+}
+
+// LastWritten returns the time the Key was last written to.
+func (key *Key) LastWritten() (time.Time, error) {
+    return FiletimeToTime(le.Uint64(key.nk[4:])), nil
+}
+
+// Offset returns the offset of the Key's record inside the registry file.
+func (key *Key) Offset() (int64, error) {
+    return baseBlockSize + int64(key.offset), nil
+}
+
+// SecurityDescriptorLen returns the length (in bytes) of the Key's security descriptor.
+// It returns 0 for Keys without a security descriptor.
+// You don't need to call this function if you call SecurityDescriptor(), which reads the descriptor.
+func (key *Key) SecurityDescriptorLen() (int, error) {
+    sd, err := key.SecurityDescriptor()
+    if err != nil { return -1, err }
+
+    return len(sd), nil
+}
+
+// SecurityDescriptor returns the Key's security descriptor, in the self-relative
+// binary form of the Windows API.
+func (key *Key) SecurityDescriptor() ([]byte, error) {
+    offset := le.Uint32(key.nk[44:])
+    if offset == noOffset { return []byte{}, nil }
+
+    sk, err := key.file.hive.record(offset, "sk", skHeaderSize)
+    if err != nil { return []byte{}, err }
+
+    l := int(le.Uint32(sk[16:]))
+    if skHeaderSize+l > len(sk) { return []byte{}, regfError("security descriptor at offset 0x%08x exceeds its cell", offset) }
+
+    return sk[skHeaderSize : skHeaderSize+l], nil
+}
+
+// ValuesLen returns the number of Values present inside a Key.
+func (key *Key) ValuesLen() (int, error) {
+    if key.values == nil {
+        offsets, err := key.file.hive.valueOffsets(le.Uint32(key.nk[40:]), int(le.Uint32(key.nk[36:])))
+        if err != nil { return -1, err }
+        key.values = offsets
+    }
+
+    return len(key.values), nil
+}
+
+// ValueAt returns the Value at a given position inside a Key.
+func (key *Key) ValueAt(index int) (*Value, error) {
+    n, err := key.ValuesLen()
+    if err != nil { return nil, err }
+    if index < 0 || index >= n { return nil, regfError("invalid value index %d", index) }
+
+    return key.file.value(key.values[index])
+}
+
+// Value returns the Value present inside a Key by its name.
+// Names are compared the way Windows does; the default Value has an empty name.
+func (key *Key) Value(path string) (*Value, error) {
+    n, err := key.ValuesLen()
+    if err != nil { return nil, err }
+
+    for i := 0; i < n; i++ {
+        value, err := key.ValueAt(i)
+        if err != nil { return nil, err }
+        name, err := value.Name()
+        if err != nil { return nil, err }
+
+        if EqualNames(name, path) { return value, nil }
+    }
+
+    return nil, fmt.Errorf("%w: value %q", ErrNotFound, path)
+}
+
+// SubkeysLen returns the count of sub-Keys present inside a Key.
+func (key *Key) SubkeysLen() (int, error) {
+    if key.subkeys == nil {
+        offsets, err := key.file.hive.subkeyOffsets(le.Uint32(key.nk[28:]), 0)
+        if err != nil { return -1, err }
+        key.subkeys = offsets
+    }
+
+    return len(key.subkeys), nil
+}
+
+// SubkeyAt returns the Key at a given position inside another Key.
+func (key *Key) SubkeyAt(index int) (*Key, error) {
+    n, err := key.SubkeysLen()
+    if err != nil { return nil, err }
+    if index < 0 || index >= n { return nil, regfError("invalid sub key index %d", index) }
+
+    return key.file.key(key.subkeys[index])
+}
+
+// SubkeyByName returns the Key present inside another Key by its name.
+// Names are compared the way Windows does.
+func (key *Key) SubkeyByName(name string) (*Key, error) {
+    n, err := key.SubkeysLen()
+    if err != nil { return nil, err }
+
+    for i := 0; i < n; i++ {
+        subkey, err := key.SubkeyAt(i)
+        if err != nil { return nil, err }
+        subname, err := subkey.Name()
+        if err != nil { return nil, err }
+
+        if EqualNames(subname, name) { return subkey, nil }
+    }
+
+    return nil, fmt.Errorf("%w: sub key %q", ErrNotFound, name)
+}
@@ -0,0 +1,138 @@
+package libregf
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+    "unicode/utf16"
+)
+
+// upcaseUTF16 returns the UTF-16 code units of name, upper cased one by one.
+func upcaseUTF16(name string) []uint16 {
+    return upcaseUnits(utf16.Encode([]rune(name)))
+}
+
+// upcaseUnits returns a copy of UTF-16 code units, upper cased one by one.
+func upcaseUnits(units []uint16) []uint16 {
+    u := make([]uint16, len(units))
+    for i, c := range units {
+        u[i] = c
+        if !utf16.IsSurrogate(rune(c)) { u[i] = upcaseUnit(c) }
+    }
+    return u
+}
+
+// upcaseUnit upper cases a code unit with unicode.ToUpper, except where it is
+// known to differ from the upcase table of Windows.
+func upcaseUnit(c uint16) uint16 {
+    r := rune(c)
+    if r == 'ı' || unicode.IsTitle(r) { return c }
+    return uint16(unicode.ToUpper(r))
+}
+
+// UpcaseName returns name upper cased the way Windows does to compare names.
+// Names that compare equal have the same UpcaseName, which makes it suitable
+// as the key of a map.
+//
+// Windows compares the names of Keys and Values without regard to case, by
+// upper casing each of their UTF-16 code units on its own, with no locale
+// specific rules: the Turkish dotted "İ" and dotless "ı", and the German "ß",
+// only match themselves. Sub keys lists are sorted, and their hashes computed,
+// on such upper cased names too.
+//
+// Windows upper cases code units with a table of its own, while this package
+// uses unicode.ToUpper, leaving alone the dotless "ı" and the title case
+// digraphs such as "ǅ" (U+01C5), which the table of Windows doesn't change.
+// Other letters outside of the common scripts may still compare differently
+// than on Windows.
+func UpcaseName(name string) string {
+    return string(utf16.Decode(upcaseUTF16(name)))
+}
+
+// CompareNames orders names the way Windows does, which is also the order of
+// sub keys lists. It returns 0 when a and b are the same name.
+func CompareNames(a, b string) int {
+    ua, ub := upcaseUTF16(a), upcaseUTF16(b)
+    for i := 0; i < len(ua) && i < len(ub); i++ {
+        if ua[i] != ub[i] { return int(ua[i]) - int(ub[i]) }
+    }
+    return len(ua) - len(ub)
+}
+
+// EqualNames tells whether a and b are the same name for Windows.
+func EqualNames(a, b string) bool {
+    if a == b { return true }
+    return CompareNames(a, b) == 0
+}
+
+// SplitPath splits path into the names of its Keys. Any of the characters in
+// separators separates names, and empty names, as found with leading, trailing
+// or repeated separators, are dropped. An empty separators means "\".
+func SplitPath(path, separators string) []string {
+    if separators == "" { separators = "\\" }
+
+    return strings.FieldsFunc(path, func(r rune) bool {
+        return strings.ContainsRune(separators, r)
+    })
+}
+
+// CleanPath returns path with "\" as its only separator and without empty
+// names, which is the form paths take in the rest of this package.
+func CleanPath(path, separators string) string {
+    return strings.Join(SplitPath(path, separators), "\\")
+}
+
+// LookupSubkey returns the sub-Key of key by its name, compared the way
+// Windows does. It fails with ErrNotFound when there is no such sub-Key.
+func LookupSubkey(key RegistryKey, name string) (RegistryKey, error) {
+    n, err := key.SubkeysLen()
+    if err != nil { return nil, err }
+
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return nil, err }
+        subname, err := subkey.Name()
+        if err != nil { release(subkey); return nil, err }
+
+        if EqualNames(subname, name) { return subkey, nil }
+        release(subkey)
+    }
+
+    return nil, fmt.Errorf("%w: sub key %q", ErrNotFound, name)
+}
+
+// LookupValue returns the Value of key by its name, compared the way Windows
+// does. It fails with ErrNotFound when there is no such Value.
+func LookupValue(key RegistryKey, name string) (RegistryValue, error) {
+    n, err := key.ValuesLen()
+    if err != nil { return nil, err }
+
+    for i := 0; i < n; i++ {
+        value, err := key.GetValueAt(i)
+        if err != nil { return nil, err }
+        vname, err := value.Name()
+        if err != nil { release(value); return nil, err }
+
+        if EqualNames(vname, name) { return value, nil }
+        release(value)
+    }
+
+    return nil, fmt.Errorf("%w: value %q", ErrNotFound, name)
+}
+
+// LookupKey returns the Key by its path inside h, splitting the path on any of
+// separators as SplitPath does and comparing names the way Windows does.
+// It fails with ErrNotFound when there is no such Key.
+func LookupKey(h Hive, path, separators string) (RegistryKey, error) {
+    key, err := h.GetRootKey()
+    if err != nil { return nil, err }
+
+    for _, name := range SplitPath(path, separators) {
+        subkey, err := LookupSubkey(key, name)
+        release(key)
+        if err != nil { return nil, err }
+        key = subkey
+    }
+
+    return key, nil
+}
@@ -0,0 +1,34 @@
+package libregf_test
+
+import (
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+func TestEqualNames(t *testing.T) {
+    for _, test := range []struct {
+        a, b  string
+        equal bool
+    }{
+        {"Software", "SOFTWARE", true},
+        {"Zürich", "ZÜRICH", true},
+        {"ελληνικά", "ΕΛΛΗΝΙΚΆ", true},
+        {"straße", "STRASSE", false},
+        {"straße", "STRAßE", true},
+        {"i", "I", true},
+        {"ı", "I", false},
+        {"İ", "i", false},
+        {"ǅ", "Ǆ", false},
+        {"ǆ", "Ǆ", true},
+    } {
+        if got := libregf.EqualNames(test.a, test.b); got != test.equal { t.Errorf("EqualNames(%q, %q): got %v", test.a, test.b, got) }
+    }
+}
+
+func TestCompareNames(t *testing.T) {
+    if libregf.CompareNames("abc", "ABD") >= 0 { t.Error(`"abc" doesn't sort before "ABD"`) }
+    if libregf.CompareNames("ab", "ABC") >= 0 { t.Error(`"ab" doesn't sort before "ABC"`) }
+    // "_" sorts after the upper case letters, as Windows compares upper cased names
+    if libregf.CompareNames("_", "a") <= 0 { t.Error(`"_" doesn't sort after "a"`) }
+}
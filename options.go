@@ -0,0 +1,63 @@
+package libregf
+
+import (
+    "errors"
+    "fmt"
+)
+
+// DefaultMaxValueSize is the size of data above which Values refuse to read
+// their data into memory, unless WithMaxValueSize sets another limit.
+const DefaultMaxValueSize = 64 << 20
+
+// ErrValueTooLarge is returned when the data of a Value is larger than the
+// maximum size of its File, as set by WithMaxValueSize.
+var ErrValueTooLarge = errors.New("libregf: value data too large")
+
+// Option configures a File as it is opened by OpenFile, OpenFileContext or NewPool.
+type Option func(*fileOptions)
+
+// fileOptions holds the settings given as Options.
+type fileOptions struct {
+    codepage     int
+    maxValueSize int64
+}
+
+// fileOptionsOf applies opts to the default settings.
+func fileOptionsOf(opts []Option) fileOptions {
+    o := fileOptions{maxValueSize: DefaultMaxValueSize}
+    for _, opt := range opts {
+        opt(&o)
+    }
+    return o
+}
+
+// WithCodepage sets the codepage of the names stored in the compressed (one
+// byte per character) form, one of the Codepage* constants. It defaults to
+// CodepageWindows1252, which is wrong for hives of Windows installs localized
+// in, for example, Japanese, Russian or Greek.
+//
+// The pure Go backend only supports the single byte codepages: with the double
+// byte ones, CodepageWindows932, 936, 949 and 950, OpenFile fails with an
+// "unsupported codepage" error. Use the libregf backend for hives of Windows
+// installs localized in Chinese, Japanese or Korean.
+func WithCodepage(codepage int) Option {
+    return func(o *fileOptions) { o.codepage = codepage }
+}
+
+// WithMaxValueSize sets the size of data above which Values fail with
+// ErrValueTooLarge rather than read their data into memory, so that a corrupted
+// hive advertising gigabytes of data can't exhaust memory. It defaults to
+// DefaultMaxValueSize, and 0 means no limit. The Reader of a Value of the pure
+// Go backend streams the data, so it can still read data above the limit.
+func WithMaxValueSize(size int64) Option {
+    return func(o *fileOptions) { o.maxValueSize = size }
+}
+
+// checkValueSize fails with ErrValueTooLarge when size exceeds the maximum size
+// set by WithMaxValueSize.
+func (o fileOptions) checkValueSize(size int64) error {
+    if o.maxValueSize > 0 && size > o.maxValueSize {
+        return fmt.Errorf("%w: %d bytes, maximum is %d", ErrValueTooLarge, size, o.maxValueSize)
+    }
+    return nil
+}
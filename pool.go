@@ -0,0 +1,124 @@
+package libregf
+
+import (
+    "context"
+    "errors"
+    "sync"
+)
+
+// ErrPoolClosed is returned by Pool.Get once the Pool has been closed.
+var ErrPoolClosed = errors.New("libregf: pool is closed")
+
+// Pool hands out File handles opened on the same registry file, so that several
+// goroutines can read it in parallel, each one through its own handle.
+// A Pool is safe for concurrent use.
+type Pool struct {
+    path   string
+    opts   []Option
+    slots  chan struct{}
+    mu     sync.Mutex
+    idle   []*File
+    closed bool
+}
+
+// NewPool returns a Pool of at most size File handles opened on path, configured by opts.
+// One handle is opened right away, so that an unreadable file is reported here
+// instead of on the first call to Get().
+func NewPool(path string, size int, opts ...Option) (*Pool, error) {
+    if size < 1 { size = 1 }
+
+    file, err := OpenFile(path, opts...)
+    if err != nil { return nil, err }
+
+    pool := &Pool{
+        path:  path,
+        opts:  opts,
+        slots: make(chan struct{}, size),
+        idle:  []*File{file},
+    }
+    return pool, nil
+}
+
+// Path returns the path of the registry file the Pool opens.
+func (pool *Pool) Path() string {
+    return pool.path
+}
+
+// Size returns the maximum number of File handles the Pool hands out at once.
+func (pool *Pool) Size() int {
+    return cap(pool.slots)
+}
+
+// Get returns a File for the exclusive use of the caller, who must give it
+// back with Put() when done. It blocks while all of the Pool's handles are in use.
+func (pool *Pool) Get() (*File, error) {
+    return pool.GetContext(context.Background())
+}
+
+// GetContext is like Get, but gives up waiting for a handle, or opening one,
+// as soon as ctx is cancelled.
+func (pool *Pool) GetContext(ctx context.Context) (*File, error) {
+    select {
+    case pool.slots <- struct{}{}:
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+
+    pool.mu.Lock()
+    if pool.closed {
+        pool.mu.Unlock()
+        <-pool.slots
+        return nil, ErrPoolClosed
+    }
+    if l := len(pool.idle); l > 0 {
+        file := pool.idle[l-1]
+        pool.idle = pool.idle[:l-1]
+        pool.mu.Unlock()
+        return file, nil
+    }
+    pool.mu.Unlock()
+
+    file, err := OpenFileContext(ctx, pool.path, pool.opts...)
+    if err != nil {
+        <-pool.slots
+        return nil, err
+    }
+    return file, nil
+}
+
+// Put gives back a File obtained from Get(). The File must not be used afterwards.
+func (pool *Pool) Put(file *File) {
+    pool.mu.Lock()
+    if pool.closed {
+        pool.mu.Unlock()
+        file.Close()
+        file.free()
+    } else {
+        pool.idle = append(pool.idle, file)
+        pool.mu.Unlock()
+    }
+    <-pool.slots
+}
+
+// Do runs fn with a File from the Pool, and puts the File back when fn returns.
+func (pool *Pool) Do(fn func(file *File) error) error {
+    file, err := pool.Get()
+    if err != nil { return err }
+    defer pool.Put(file)
+
+    return fn(file)
+}
+
+// Close closes the idle handles of the Pool. Handles still in use are closed
+// as they are put back.
+func (pool *Pool) Close() {
+    pool.mu.Lock()
+    defer pool.mu.Unlock()
+
+    pool.closed = true
+    for _, file := range pool.idle {
+        file.Close()
+        file.free()
+    }
+    pool.idle = nil
+}
@@ -0,0 +1,178 @@
+package libregf_test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "reflect"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// wideHive describes a hive with enough Keys, over a few levels, to keep
+// several goroutines busy.
+func wideHive() *regftest.Hive {
+    h := regftest.Standard()
+    for i := 0; i < 8; i++ {
+        for j := 0; j < 8; j++ {
+            h.Keys = append(h.Keys, regftest.Key{
+                Path:   fmt.Sprintf("Wide\\K%d\\S%d\\Leaf", i, j),
+                Values: []regftest.Value{{Name: "Path", Type: "REG_SZ", String: fmt.Sprintf("K%d\\S%d", i, j)}},
+            })
+        }
+    }
+    return h
+}
+
+// newPool opens a Pool of size handles on the registry file described by h.
+func newPool(t *testing.T, h *regftest.Hive, size int) *libregf.Pool {
+    t.Helper()
+
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatalf("building hive: %v", err) }
+    pool, err := libregf.NewPool(path, size)
+    if err != nil { t.Fatalf("NewPool: %v", err) }
+    t.Cleanup(pool.Close)
+
+    return pool
+}
+
+func TestPoolConcurrentUse(t *testing.T) {
+    const size = 4
+    pool := newPool(t, wideHive(), size)
+    want := keyPaths(t, openHive(t, wideHive()))
+
+    var inUse, maxInUse int32
+    track := func(delta int32) {
+        n := atomic.AddInt32(&inUse, delta)
+        for {
+            m := atomic.LoadInt32(&maxInUse)
+            if n <= m || atomic.CompareAndSwapInt32(&maxInUse, m, n) { break }
+        }
+    }
+
+    var wg sync.WaitGroup
+    errs := make(chan error, 32)
+    for g := 0; g < 32; g++ {
+        wg.Add(1)
+        go func(g int) {
+            defer wg.Done()
+
+            if g%2 == 0 {
+                errs <- pool.Do(func(file *libregf.File) error {
+                    track(1)
+                    defer track(-1)
+                    return checkWalk(file, want)
+                })
+                return
+            }
+
+            file, err := pool.Get()
+            if err != nil {
+                errs <- err
+                return
+            }
+            track(1)
+            err = checkWalk(file, want)
+            if err == nil {
+                var s string
+                s, err = libregf.HiveGet[string](file, "Wide\\K3\\S5\\Leaf\\Path")
+                if err == nil && s != "K3\\S5" { err = fmt.Errorf("HiveGet: got %q", s) }
+            }
+            track(-1)
+            pool.Put(file)
+            errs <- err
+        }(g)
+    }
+    wg.Wait()
+    close(errs)
+
+    for err := range errs {
+        if err != nil { t.Error(err) }
+    }
+    if maxInUse > size { t.Errorf("%d handles were in use at once, the Pool has %d", maxInUse, size) }
+}
+
+// checkWalk walks file and compares the paths of its Keys to want.
+func checkWalk(file *libregf.File, want []string) error {
+    got := []string{}
+    err := file.Walk(func(path string, key libregf.RegistryKey) error {
+        got = append(got, path)
+        _, err := key.ValuesLen()
+        return err
+    })
+    if err != nil { return err }
+    if !reflect.DeepEqual(got, want) { return fmt.Errorf("Walk visited %d Keys, want %d", len(got), len(want)) }
+    return nil
+}
+
+func TestPoolGetContextAndClose(t *testing.T) {
+    pool := newPool(t, regftest.Standard(), 1)
+
+    file, err := pool.Get()
+    if err != nil { t.Fatal(err) }
+
+    // the only handle is in use
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := pool.GetContext(ctx); !errors.Is(err, context.Canceled) {
+        t.Errorf("GetContext on an exhausted Pool: got %v, want context.Canceled", err)
+    }
+
+    pool.Put(file)
+    pool.Close()
+    if _, err := pool.Get(); !errors.Is(err, libregf.ErrPoolClosed) {
+        t.Errorf("Get on a closed Pool: got %v, want ErrPoolClosed", err)
+    }
+}
+
+func TestParallelWalk(t *testing.T) {
+    pool := newPool(t, wideHive(), 4)
+    want := keyPaths(t, openHive(t, wideHive()))
+
+    for _, order := range []libregf.WalkOrder{libregf.Unordered, libregf.PathSorted} {
+        got := []string{}
+        results := libregf.ParallelWalk(pool, 8, order, func(path string, key libregf.RegistryKey) (string, error) {
+            return key.Name()
+        })
+        for r := range results {
+            if r.Err != nil { t.Errorf("order %d: %s: %v", order, r.Path, r.Err) }
+            got = append(got, r.Path)
+        }
+
+        if order == libregf.Unordered {
+            sorted := append([]string{}, want...)
+            sort.Strings(sorted)
+            sort.Strings(got)
+            if !reflect.DeepEqual(got, sorted) { t.Errorf("Unordered: got %d paths, want %d", len(got), len(sorted)) }
+        } else if !reflect.DeepEqual(got, want) {
+            t.Errorf("PathSorted: got %q, want %q", got, want)
+        }
+    }
+}
+
+func TestParallelWalkContextCancelled(t *testing.T) {
+    pool := newPool(t, wideHive(), 4)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+
+    var calls int32
+    results := libregf.ParallelWalkContext(ctx, pool, 4, libregf.Unordered, func(path string, key libregf.RegistryKey) (int, error) {
+        return int(atomic.AddInt32(&calls, 1)), nil
+    })
+    <-results
+    cancel()
+    for range results {
+    }
+
+    // every handle must have been put back
+    for i := 0; i < pool.Size(); i++ {
+        if _, err := pool.Get(); err != nil { t.Fatalf("Get after a cancelled ParallelWalk: %v", err) }
+    }
+}
@@ -0,0 +1,272 @@
+//go:build !cgo || purego
+
+package libregf
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+)
+
+// hive reads the cells of a REGF file for the pure Go backend.
+type hive struct {
+    r     io.ReaderAt
+    size  int64 // size of the hive bins data, past the base block
+    root  uint32
+    major uint32
+    minor uint32
+}
+
+// newHive checks the base block of a REGF file of the given size and returns a
+// hive reading its cells from r.
+func newHive(r io.ReaderAt, size int64) (*hive, error) {
+    block := make([]byte, baseBlockSize)
+    if _, err := r.ReadAt(block, 0); err != nil {
+        return nil, regfError("unable to read base block: %v", err)
+    }
+    if !bytes.Equal(block[0:4], []byte("regf")) {
+        return nil, regfError("unsupported file signature")
+    }
+
+    h := &hive{
+        r:     r,
+        root:  le.Uint32(block[36:]),
+        major: le.Uint32(block[20:]),
+        minor: le.Uint32(block[24:]),
+        size:  int64(le.Uint32(block[40:])),
+    }
+    if h.major != 1 {
+        return nil, regfError("unsupported format version %d.%d", h.major, h.minor)
+    }
+    // hives that were not cleanly written may lie about their size
+    if h.size == 0 || h.size > size-baseBlockSize {
+        h.size = size - baseBlockSize
+    }
+
+    return h, nil
+}
+
+// regfError returns an error formatted like the ones of the libregf backend.
+func regfError(format string, args ...interface{}) error {
+    return fmt.Errorf("libregf error: "+format, args...)
+}
+
+// cellSize returns the size of the data of the cell at offset, without its size field.
+func (h *hive) cellSize(offset uint32) (int64, error) {
+    if offset == noOffset || offset%cellAlignment != 0 || int64(offset)+4 > h.size {
+        return -1, regfError("invalid cell offset 0x%08x", offset)
+    }
+
+    var sb [4]byte
+    if _, err := h.r.ReadAt(sb[:], baseBlockSize+int64(offset)); err != nil {
+        return -1, regfError("unable to read cell at offset 0x%08x: %v", offset, err)
+    }
+
+    // allocated cells have a negative size
+    size := int64(int32(le.Uint32(sb[:])))
+    if size < 0 { size = -size }
+    if size < 8 || int64(offset)+size > h.size {
+        return -1, regfError("invalid size %d of cell at offset 0x%08x", size, offset)
+    }
+
+    return size - 4, nil
+}
+
+// cell returns the data of the cell at offset, without its size field.
+func (h *hive) cell(offset uint32) ([]byte, error) {
+    size, err := h.cellSize(offset)
+    if err != nil { return nil, err }
+
+    data := make([]byte, size)
+    if _, err := h.r.ReadAt(data, baseBlockSize+int64(offset)+4); err != nil {
+        return nil, regfError("unable to read cell at offset 0x%08x: %v", offset, err)
+    }
+
+    return data, nil
+}
+
+// record returns the data of the cell at offset, after checking that it holds
+// a record with the given signature and at least size bytes.
+func (h *hive) record(offset uint32, signature string, size int) ([]byte, error) {
+    data, err := h.cell(offset)
+    if err != nil { return nil, err }
+
+    if len(data) < size || string(data[0:2]) != signature {
+        return nil, regfError("missing %s record at offset 0x%08x", signature, offset)
+    }
+
+    return data, nil
+}
+
+// subkeyOffsets returns the offsets of the key (nk) records listed by the sub
+// keys list at offset, following index root (ri) lists.
+func (h *hive) subkeyOffsets(offset uint32, depth int) ([]uint32, error) {
+    if offset == noOffset { return []uint32{}, nil }
+    if depth > 2 { return nil, regfError("sub keys lists nested too deep at offset 0x%08x", offset) }
+
+    data, err := h.cell(offset)
+    if err != nil { return nil, err }
+    if len(data) < 4 { return nil, regfError("invalid sub keys list at offset 0x%08x", offset) }
+
+    n := int(le.Uint16(data[2:]))
+    stride := 8
+    switch string(data[0:2]) {
+    case "li", "ri":
+        stride = 4
+    case "lf", "lh":
+    default:
+        return nil, regfError("unsupported sub keys list signature at offset 0x%08x", offset)
+    }
+    if 4+n*stride > len(data) { return nil, regfError("sub keys list at offset 0x%08x exceeds its cell", offset) }
+
+    offsets := make([]uint32, 0, n)
+    for i := 0; i < n; i++ {
+        o := le.Uint32(data[4+i*stride:])
+        if string(data[0:2]) == "ri" {
+            sub, err := h.subkeyOffsets(o, depth+1)
+            if err != nil { return nil, err }
+            offsets = append(offsets, sub...)
+        } else {
+            offsets = append(offsets, o)
+        }
+    }
+
+    return offsets, nil
+}
+
+// valueOffsets returns the offsets of the n value (vk) records listed by the
+// values list at offset.
+func (h *hive) valueOffsets(offset uint32, n int) ([]uint32, error) {
+    if offset == noOffset || n == 0 { return []uint32{}, nil }
+
+    data, err := h.cell(offset)
+    if err != nil { return nil, err }
+    if n*4 > len(data) { return nil, regfError("values list at offset 0x%08x exceeds its cell", offset) }
+
+    offsets := make([]uint32, n)
+    for i := range offsets {
+        offsets[i] = le.Uint32(data[i*4:])
+    }
+
+    return offsets, nil
+}
+
+// valueDataSize returns the size of the data of a value (vk) record, as
+// declared by the record.
+func valueDataSize(vk []byte) int64 {
+    return int64(le.Uint32(vk[4:]) &^ valueDataInline)
+}
+
+// valueData returns the data of a value (vk) record, whether it is stored in
+// the record itself, in a cell of its own or in big data (db) segments.
+func (h *hive) valueData(vk []byte) ([]byte, error) {
+    size := le.Uint32(vk[4:])
+    offset := le.Uint32(vk[8:])
+
+    if size&valueDataInline != 0 {
+        size &^= valueDataInline
+        if size > 4 { return nil, regfError("invalid inline value data size %d", size) }
+        return append([]byte{}, vk[8:8+size]...), nil
+    }
+    if size == 0 { return []byte{}, nil }
+
+    data, err := h.cell(offset)
+    if err != nil { return nil, err }
+
+    if size > bigDataSegmentSize && h.minor >= 4 && len(data) >= dbHeaderSize && string(data[0:2]) == "db" {
+        return h.bigData(data, size)
+    }
+    if int(size) > len(data) { return nil, regfError("value data at offset 0x%08x exceeds its cell", offset) }
+
+    return data[:size], nil
+}
+
+// bigData concatenates the segments of a big data (db) record.
+func (h *hive) bigData(db []byte, size uint32) ([]byte, error) {
+    n := int(le.Uint16(db[2:]))
+    list, err := h.cell(le.Uint32(db[4:]))
+    if err != nil { return nil, err }
+    if n*4 > len(list) { return nil, regfError("big data segments list exceeds its cell") }
+    if int64(size) > int64(n)*bigDataSegmentSize { return nil, regfError("big data is missing segments") }
+
+    data := make([]byte, 0, size)
+    for i := 0; i < n && uint32(len(data)) < size; i++ {
+        segment, err := h.cell(le.Uint32(list[i*4:]))
+        if err != nil { return nil, err }
+
+        l := size - uint32(len(data))
+        if l > bigDataSegmentSize { l = bigDataSegmentSize }
+        if int(l) > len(segment) { return nil, regfError("big data segment %d exceeds its cell", i) }
+        data = append(data, segment[:l]...)
+    }
+    if uint32(len(data)) < size { return nil, regfError("big data is missing segments") }
+
+    return data, nil
+}
+
+// valueReader returns a reader over the data of a value (vk) record, like
+// valueData, except that big data (db) segments are only read as the reader
+// reaches them.
+func (h *hive) valueReader(vk []byte) (*io.SectionReader, error) {
+    size := le.Uint32(vk[4:])
+    if size&valueDataInline == 0 && size > bigDataSegmentSize && h.minor >= 4 {
+        db, err := h.cell(le.Uint32(vk[8:]))
+        if err != nil { return nil, err }
+        if len(db) >= dbHeaderSize && string(db[0:2]) == "db" { return h.bigDataReader(db, size) }
+    }
+
+    data, err := h.valueData(vk)
+    if err != nil { return nil, err }
+
+    return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), nil
+}
+
+// bigDataReader returns a reader over the segments of a big data (db) record,
+// after checking they hold size bytes.
+func (h *hive) bigDataReader(db []byte, size uint32) (*io.SectionReader, error) {
+    n := int(le.Uint16(db[2:]))
+    list, err := h.cell(le.Uint32(db[4:]))
+    if err != nil { return nil, err }
+    if n*4 > len(list) { return nil, regfError("big data segments list exceeds its cell") }
+    if int64(size) > int64(n)*bigDataSegmentSize { return nil, regfError("big data is missing segments") }
+
+    s := &segments{r: h.r}
+    for i := 0; int64(i)*bigDataSegmentSize < int64(size); i++ {
+        offset := le.Uint32(list[i*4:])
+        l, err := h.cellSize(offset)
+        if err != nil { return nil, err }
+
+        want := int64(size) - int64(i)*bigDataSegmentSize
+        if want > bigDataSegmentSize { want = bigDataSegmentSize }
+        if want > l { return nil, regfError("big data segment %d exceeds its cell", i) }
+        s.offsets = append(s.offsets, baseBlockSize+int64(offset)+4)
+    }
+
+    return io.NewSectionReader(s, 0, int64(size)), nil
+}
+
+// segments reads the segments of big data as if they were contiguous.
+type segments struct {
+    r       io.ReaderAt
+    offsets []int64 // where the data of each segment starts in the file
+}
+
+// ReadAt implements io.ReaderAt. Reads past the last segment return io.EOF.
+func (s *segments) ReadAt(p []byte, off int64) (int, error) {
+    n := 0
+    for n < len(p) {
+        pos := off + int64(n)
+        i := pos / bigDataSegmentSize
+        if pos < 0 || i >= int64(len(s.offsets)) { return n, io.EOF }
+
+        within := pos % bigDataSegmentSize
+        end := len(p)
+        if int64(end-n) > bigDataSegmentSize-within { end = n + int(bigDataSegmentSize-within) }
+
+        m, err := s.r.ReadAt(p[n:end], s.offsets[i]+within)
+        n += m
+        if err != nil { return n, err }
+    }
+
+    return n, nil
+}
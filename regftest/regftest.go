@@ -0,0 +1,550 @@
+// Package regftest builds small registry files to test code written against
+// libregf, from a description written in Go, JSON or YAML.
+//
+// Besides Keys and Values of every type, including big data Values, a
+// description can ask for deleted Keys and Values, which are left behind as
+// unallocated cells the way Windows leaves them, and for corrupted records.
+package regftest
+
+import (
+    "encoding/base64"
+    "encoding/binary"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf16"
+
+    "github.com/jdrowell/go-libregf"
+    "gopkg.in/yaml.v3"
+)
+
+// Hive describes a registry file.
+type Hive struct {
+    Timestamp   time.Time    `json:"timestamp" yaml:"timestamp"`
+    Keys        []Key        `json:"keys" yaml:"keys"`
+    Corruptions []Corruption `json:"corruptions" yaml:"corruptions"`
+}
+
+// Key describes a registry Key by its path, relative to the root Key. Parent
+// Keys don't need to be described, they are created as needed.
+// A Deleted Key, and all of its sub-Keys, are unlinked from their parent.
+type Key struct {
+    Path        string    `json:"path" yaml:"path"`
+    Class       string    `json:"class" yaml:"class"`
+    LastWritten time.Time `json:"last_written" yaml:"last_written"`
+    Values      []Value   `json:"values" yaml:"values"`
+    Deleted     bool      `json:"deleted" yaml:"deleted"`
+}
+
+// Value describes a registry Value. Type is one of the REG_* names of the
+// Windows API, such as "REG_SZ" or "REG_DWORD", or a number, such as "0x3e9",
+// for the types that Windows puts to other uses, as SAM hives do with the RIDs
+// of users. Depending on the type, the data comes from String, Strings, Integer
+// or Data. When Data is empty, Size bytes of generated data are used instead,
+// which makes for easy big data Values.
+// A Deleted Value is unlinked from its Key.
+type Value struct {
+    Name    string   `json:"name" yaml:"name"`
+    Type    string   `json:"type" yaml:"type"`
+    String  string   `json:"string" yaml:"string"`
+    Strings []string `json:"strings" yaml:"strings"`
+    Integer uint64   `json:"integer" yaml:"integer"`
+    Data    []byte   `json:"data" yaml:"-"` // see UnmarshalYAML
+    Size    int      `json:"size" yaml:"size"`
+    Deleted bool     `json:"deleted" yaml:"deleted"`
+}
+
+// Kinds of Corruption.
+const (
+    // BadSignature overwrites the signature of a key (nk) or value (vk) record.
+    BadSignature = "signature"
+    // BadCellSize makes the cell of a record claim to extend past the end of the hive.
+    BadCellSize = "cell-size"
+    // BadNameLength makes the name of a record extend past the end of its cell.
+    BadNameLength = "name-length"
+    // BadDataOffset makes a Value's data point past the end of the hive.
+    BadDataOffset = "data-offset"
+    // BadDataSize makes a Value claim a gigabyte of data, keeping its data offset.
+    BadDataSize = "data-size"
+    // BadChecksum breaks the checksum of the base block. Path and Value are ignored.
+    BadChecksum = "checksum"
+    // SubkeyLoop points the first entry of the sub keys list of a Key back at
+    // its parent, so that walking the Key never ends.
+    SubkeyLoop = "subkey-loop"
+)
+
+// Corruption describes damage done to the record of a Key or, when Value is
+// set, to the record of one of its Values. DefaultValue targets the default
+// Value of the Key, whose name is empty.
+type Corruption struct {
+    Path         string `json:"path" yaml:"path"`
+    Value        string `json:"value" yaml:"value"`
+    DefaultValue bool   `json:"default_value" yaml:"default_value"`
+    Kind         string `json:"kind" yaml:"kind"`
+}
+
+var valueTypes = map[string]int{
+    "REG_NONE":                       libregf.ValueTypeUndefined,
+    "REG_SZ":                         libregf.ValueTypeString,
+    "REG_EXPAND_SZ":                  libregf.ValueTypeExpandableString,
+    "REG_BINARY":                     libregf.ValueTypeBinaryData,
+    "REG_DWORD":                      libregf.ValueTypeInteger32BitLittleEndian,
+    "REG_DWORD_BIG_ENDIAN":           libregf.ValueTypeInteger32BitBigEndian,
+    "REG_LINK":                       libregf.ValueTypeSymbolicLink,
+    "REG_MULTI_SZ":                   libregf.ValueTypeMultiValueString,
+    "REG_RESOURCE_LIST":              libregf.ValueTypeResourceList,
+    "REG_FULL_RESOURCE_DESCRIPTOR":   libregf.ValueTypeFullResourceDescriptor,
+    "REG_RESOURCE_REQUIREMENTS_LIST": libregf.ValueTypeResourceRequirementsList,
+    "REG_QWORD":                      libregf.ValueTypeInteger64BitLittleEndian,
+}
+
+// Load reads the JSON description of a Hive. Data is base64 encoded, as
+// encoding/json does for []byte.
+func Load(r io.Reader) (*Hive, error) {
+    var h Hive
+    dec := json.NewDecoder(r)
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(&h); err != nil { return nil, err }
+
+    return &h, nil
+}
+
+// LoadYAML reads the YAML description of a Hive, whose fields are named as in
+// JSON. Data is a base64 string, tagged !!binary or not, or a sequence of bytes.
+func LoadYAML(r io.Reader) (*Hive, error) {
+    var h Hive
+    dec := yaml.NewDecoder(r)
+    dec.KnownFields(true)
+    if err := dec.Decode(&h); err != nil { return nil, err }
+
+    return &h, nil
+}
+
+// UnmarshalYAML decodes the YAML description of a Value, letting its data be
+// given as a base64 string as in JSON, tagged !!binary or not, or as a
+// sequence of bytes.
+func (v *Value) UnmarshalYAML(node *yaml.Node) error {
+    type value Value
+    var raw struct {
+        value `yaml:",inline"`
+        Data  yaml.Node `yaml:"data"`
+    }
+    if err := checkFields(node, reflect.TypeOf(raw)); err != nil { return err }
+    if err := node.Decode(&raw); err != nil { return err }
+
+    *v = Value(raw.value)
+    switch {
+    case raw.Data.Kind == 0:
+    case raw.Data.Kind == yaml.SequenceNode:
+        return raw.Data.Decode(&v.Data)
+    case raw.Data.Tag == "!!binary":
+        var s string
+        if err := raw.Data.Decode(&s); err != nil { return err }
+        v.Data = []byte(s)
+    default:
+        data, err := base64.StdEncoding.DecodeString(raw.Data.Value)
+        if err != nil { return fmt.Errorf("regftest: line %d: data: %v", raw.Data.Line, err) }
+        v.Data = data
+    }
+
+    return nil
+}
+
+// checkFields fails on the keys of a YAML mapping which aren't fields of t, as
+// yaml.Decoder.KnownFields does, since it doesn't apply to UnmarshalYAML.
+func checkFields(node *yaml.Node, t reflect.Type) error {
+    known := map[string]bool{}
+    var add func(t reflect.Type)
+    add = func(t reflect.Type) {
+        for i := 0; i < t.NumField(); i++ {
+            tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")
+            if len(tag) > 1 && tag[1] == "inline" {
+                add(t.Field(i).Type)
+            } else {
+                known[tag[0]] = true
+            }
+        }
+    }
+    add(t)
+
+    for i := 0; i+1 < len(node.Content); i += 2 {
+        if key := node.Content[i]; !known[key.Value] { return fmt.Errorf("regftest: line %d: field %s not found in type %s", key.Line, key.Value, t) }
+    }
+    return nil
+}
+
+// LoadFile reads the description of a Hive from a file, in YAML when its name
+// ends in ".yaml" or ".yml", in JSON otherwise.
+func LoadFile(path string) (*Hive, error) {
+    f, err := os.Open(path)
+    if err != nil { return nil, err }
+    defer f.Close()
+
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        return LoadYAML(f)
+    default:
+        return Load(f)
+    }
+}
+
+// WriteFile builds the registry file described by h and writes it at path.
+func (h *Hive) WriteFile(path string) error {
+    data, err := h.Build()
+    if err != nil { return err }
+
+    return os.WriteFile(path, data, 0644)
+}
+
+// TempFile builds the registry file described by h in a new file of dir, as
+// os.CreateTemp does, and returns its path. Removing it is up to the caller.
+func (h *Hive) TempFile(dir string) (string, error) {
+    data, err := h.Build()
+    if err != nil { return "", err }
+
+    f, err := os.CreateTemp(dir, "regftest-*.hve")
+    if err != nil { return "", err }
+    if _, err := f.Write(data); err != nil {
+        f.Close()
+        os.Remove(f.Name())
+        return "", err
+    }
+    if err := f.Close(); err != nil {
+        os.Remove(f.Name())
+        return "", err
+    }
+
+    return f.Name(), nil
+}
+
+// Build returns the registry file described by h.
+func (h *Hive) Build() ([]byte, error) {
+    w := libregf.NewWriter()
+    if !h.Timestamp.IsZero() { w.SetTimestamp(h.Timestamp) }
+
+    for _, k := range h.Keys {
+        key, err := w.CreateKey(k.Path)
+        if err != nil { return nil, err }
+
+        key.SetClassName(k.Class)
+        key.SetLastWritten(k.LastWritten)
+        for _, v := range k.Values {
+            if err := setValue(key, v); err != nil { return nil, fmt.Errorf("regftest: %s\\%s: %v", k.Path, v.Name, err) }
+        }
+    }
+
+    data, err := w.Bytes()
+    if err != nil { return nil, err }
+
+    // Deleting, then corrupting, works on records found by walking the hive,
+    // so nothing may be unlinked or damaged before it has been found.
+    for _, k := range h.Keys {
+        for _, v := range k.Values {
+            if !v.Deleted { continue }
+            if err := deleteValue(data, k.Path, v.Name); err != nil { return nil, err }
+        }
+    }
+    for _, k := range h.Keys {
+        if !k.Deleted || h.deletedAncestor(k.Path) { continue }
+        if err := deleteKey(data, k.Path); err != nil { return nil, err }
+    }
+    for _, c := range h.Corruptions {
+        if err := corrupt(data, c); err != nil { return nil, err }
+    }
+
+    return data, nil
+}
+
+// deletedAncestor tells whether a parent Key of path is deleted too, in which
+// case deleting the parent takes care of it.
+func (h *Hive) deletedAncestor(path string) bool {
+    path = strings.Trim(path, "\\")
+    for _, k := range h.Keys {
+        parent := strings.Trim(k.Path, "\\")
+        if k.Deleted && len(parent) < len(path) && strings.EqualFold(path[:len(parent)+1], parent+"\\") { return true }
+    }
+    return false
+}
+
+func setValue(key *libregf.WriterKey, v Value) error {
+    _type, ok := valueTypes[v.Type]
+    if !ok {
+        n, err := strconv.ParseUint(v.Type, 0, 32)
+        if err != nil { return fmt.Errorf("unknown value type %q", v.Type) }
+        _type = int(n)
+    }
+
+    data := v.Data
+    if len(data) == 0 && v.Size > 0 { data = pattern(v.Size) }
+
+    switch _type {
+    case libregf.ValueTypeString:
+        key.SetString(v.Name, v.String)
+    case libregf.ValueTypeExpandableString:
+        key.SetExpandableString(v.Name, v.String)
+    case libregf.ValueTypeSymbolicLink:
+        key.SetSymbolicLink(v.Name, v.String)
+    case libregf.ValueTypeMultiValueString:
+        key.SetStrings(v.Name, v.Strings)
+    case libregf.ValueTypeInteger32BitLittleEndian:
+        key.SetUint32(v.Name, uint32(v.Integer))
+    case libregf.ValueTypeInteger32BitBigEndian:
+        key.SetUint32BigEndian(v.Name, uint32(v.Integer))
+    case libregf.ValueTypeInteger64BitLittleEndian:
+        key.SetUint64(v.Name, v.Integer)
+    default:
+        key.SetValue(v.Name, _type, data)
+    }
+
+    return nil
+}
+
+// pattern returns size bytes of data which don't repeat with a period that
+// divides the size of a big data segment, so that misplaced segments show.
+func pattern(size int) []byte {
+    data := make([]byte, size)
+    for i := range data {
+        data[i] = byte(i % 251)
+    }
+    return data
+}
+
+// Layout of the records touched by this package; see format.go in libregf.
+const (
+    baseBlockSize = 4096
+    noOffset      = 0xffffffff
+)
+
+var le = binary.LittleEndian
+
+// cell returns the data of the cell at offset, including its size field.
+func cell(data []byte, offset uint32) []byte {
+    start := baseBlockSize + int(offset)
+    size := int(int32(le.Uint32(data[start:])))
+    if size < 0 { size = -size }
+    return data[start : start+size]
+}
+
+// free marks the cell at offset as unallocated, leaving its content as is.
+func free(data []byte, offset uint32) {
+    c := cell(data, offset)
+    le.PutUint32(c, uint32(len(c)))
+}
+
+// recordName decodes the name of a key (nk) or value (vk) record.
+func recordName(c []byte) string {
+    var raw []byte
+    var compressed bool
+    if string(c[4:6]) == "nk" {
+        raw = c[4+76 : 4+76+int(le.Uint16(c[4+72:]))]
+        compressed = le.Uint16(c[4+2:])&0x0020 != 0
+    } else {
+        raw = c[4+20 : 4+20+int(le.Uint16(c[4+2:]))]
+        compressed = le.Uint16(c[4+16:])&0x0001 != 0
+    }
+
+    if compressed {
+        r := make([]rune, len(raw))
+        for i, b := range raw {
+            r[i] = rune(b)
+        }
+        return string(r)
+    }
+    u := make([]uint16, len(raw)/2)
+    for i := range u {
+        u[i] = le.Uint16(raw[2*i:])
+    }
+    return string(utf16.Decode(u))
+}
+
+// subkeysList returns the cell of the hash leaf (lh) list holding the entry
+// for offset, and the index of that entry, following index root (ri) lists.
+func subkeysList(data []byte, list uint32, offset uint32) ([]byte, int) {
+    c := cell(data, list)
+    n := int(le.Uint16(c[6:]))
+    for i := 0; i < n; i++ {
+        if string(c[4:6]) == "ri" {
+            if l, j := subkeysList(data, le.Uint32(c[8+4*i:]), offset); l != nil { return l, j }
+        } else if le.Uint32(c[8+8*i:]) == offset {
+            return c, i
+        }
+    }
+    return nil, -1
+}
+
+// findKey returns the offsets of the key (nk) record at path and of its parent.
+func findKey(data []byte, path string) (uint32, uint32, error) {
+    offset, parent := le.Uint32(data[36:]), uint32(noOffset)
+
+    for _, name := range strings.Split(path, "\\") {
+        if name == "" { continue }
+
+        nk := cell(data, offset)
+        found := false
+        for _, o := range subkeyOffsets(data, le.Uint32(nk[4+28:])) {
+            if libregf.EqualNames(recordName(cell(data, o)), name) {
+                offset, parent, found = o, offset, true
+                break
+            }
+        }
+        if !found { return 0, 0, fmt.Errorf("regftest: no such key: %s", path) }
+    }
+
+    return offset, parent, nil
+}
+
+func subkeyOffsets(data []byte, list uint32) []uint32 {
+    if list == noOffset { return nil }
+
+    c := cell(data, list)
+    n := int(le.Uint16(c[6:]))
+    offsets := []uint32{}
+    for i := 0; i < n; i++ {
+        if string(c[4:6]) == "ri" {
+            offsets = append(offsets, subkeyOffsets(data, le.Uint32(c[8+4*i:]))...)
+        } else {
+            offsets = append(offsets, le.Uint32(c[8+8*i:]))
+        }
+    }
+    return offsets
+}
+
+// findValue returns the offset of the value (vk) record of a Key by its name,
+// and the index of its entry in the Key's values list.
+func findValue(data []byte, path, name string) (uint32, int, error) {
+    offset, _, err := findKey(data, path)
+    if err != nil { return 0, 0, err }
+
+    nk := cell(data, offset)
+    n := int(le.Uint32(nk[4+36:]))
+    if n == 0 { return 0, 0, fmt.Errorf("regftest: no such value: %s\\%s", path, name) }
+
+    list := cell(data, le.Uint32(nk[4+40:]))
+    for i := 0; i < n; i++ {
+        o := le.Uint32(list[4+4*i:])
+        if libregf.EqualNames(recordName(cell(data, o)), name) { return o, i, nil }
+    }
+
+    return 0, 0, fmt.Errorf("regftest: no such value: %s\\%s", path, name)
+}
+
+// deleteValue unlinks a Value from its Key and frees its cells.
+func deleteValue(data []byte, path, name string) error {
+    offset, index, err := findValue(data, path, name)
+    if err != nil { return err }
+    key, _, _ := findKey(data, path)
+
+    nk := cell(data, key)
+    n := int(le.Uint32(nk[4+36:]))
+    list := cell(data, le.Uint32(nk[4+40:]))
+    copy(list[4+4*index:4+4*(n-1)], list[4+4*(index+1):4+4*n])
+    le.PutUint32(nk[4+36:], uint32(n-1))
+
+    freeValue(data, offset)
+    return nil
+}
+
+func freeValue(data []byte, offset uint32) {
+    vk := cell(data, offset)
+    size := le.Uint32(vk[4+4:])
+    if size&0x80000000 == 0 && size > 0 {
+        dc := cell(data, le.Uint32(vk[4+8:]))
+        if string(dc[4:6]) == "db" {
+            segments := cell(data, le.Uint32(dc[4+4:]))
+            for i := 0; i < int(le.Uint16(dc[4+2:])); i++ {
+                free(data, le.Uint32(segments[4+4*i:]))
+            }
+            free(data, le.Uint32(dc[4+4:]))
+        }
+        free(data, le.Uint32(vk[4+8:]))
+    }
+    free(data, offset)
+}
+
+// deleteKey unlinks a Key from its parent and frees its cells, and those of
+// its sub-Keys and Values.
+func deleteKey(data []byte, path string) error {
+    offset, parent, err := findKey(data, path)
+    if err != nil { return err }
+    if parent == noOffset { return fmt.Errorf("regftest: can't delete the root key") }
+
+    pnk := cell(data, parent)
+    list, index := subkeysList(data, le.Uint32(pnk[4+28:]), offset)
+    n := int(le.Uint16(list[6:]))
+    copy(list[8+8*index:8+8*(n-1)], list[8+8*(index+1):8+8*n])
+    le.PutUint16(list[6:], uint16(n-1))
+    le.PutUint32(pnk[4+20:], le.Uint32(pnk[4+20:])-1)
+
+    freeKey(data, offset)
+    return nil
+}
+
+func freeKey(data []byte, offset uint32) {
+    nk := cell(data, offset)
+    for _, o := range subkeyOffsets(data, le.Uint32(nk[4+28:])) {
+        freeKey(data, o)
+    }
+    if n := int(le.Uint32(nk[4+36:])); n > 0 {
+        list := cell(data, le.Uint32(nk[4+40:]))
+        for i := 0; i < n; i++ {
+            freeValue(data, le.Uint32(list[4+4*i:]))
+        }
+        free(data, le.Uint32(nk[4+40:]))
+    }
+    free(data, offset)
+}
+
+// corrupt applies a Corruption.
+func corrupt(data []byte, c Corruption) error {
+    if c.Kind == BadChecksum {
+        le.PutUint32(data[508:], le.Uint32(data[508:])^0xffff)
+        return nil
+    }
+
+    var offset, parent uint32
+    var err error
+    value := c.Value != "" || c.DefaultValue
+    if value {
+        offset, _, err = findValue(data, c.Path, c.Value)
+    } else {
+        offset, parent, err = findKey(data, c.Path)
+    }
+    if err != nil { return err }
+
+    rec := cell(data, offset)
+    hbins := le.Uint32(data[40:])
+    switch c.Kind {
+    case BadSignature:
+        copy(rec[4:6], "xx")
+    case BadCellSize:
+        le.PutUint32(rec, uint32(-int32(hbins)))
+    case BadNameLength:
+        if value {
+            le.PutUint16(rec[4+2:], 0xffff)
+        } else {
+            le.PutUint16(rec[4+72:], 0xffff)
+        }
+    case BadDataOffset:
+        if !value { return fmt.Errorf("regftest: %s corruption needs a value", c.Kind) }
+        le.PutUint32(rec[4+4:], 0x100)
+        le.PutUint32(rec[4+8:], hbins+0x1000)
+    case BadDataSize:
+        if !value { return fmt.Errorf("regftest: %s corruption needs a value", c.Kind) }
+        le.PutUint32(rec[4+4:], 1<<30)
+    case SubkeyLoop:
+        subkeys := subkeyOffsets(data, le.Uint32(rec[4+28:]))
+        if value || parent == noOffset || len(subkeys) == 0 { return fmt.Errorf("regftest: %s corruption needs a key with a parent and sub keys", c.Kind) }
+        list, i := subkeysList(data, le.Uint32(rec[4+28:]), subkeys[0])
+        le.PutUint32(list[8+8*i:], parent)
+    default:
+        return fmt.Errorf("regftest: unknown corruption %q", c.Kind)
+    }
+
+    return nil
+}
@@ -0,0 +1,101 @@
+package regftest_test
+
+import (
+    "bytes"
+    "errors"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// The descriptions of testdata/small.json and testdata/small.yaml are the same.
+func TestLoadFile(t *testing.T) {
+    fromJSON, err := regftest.LoadFile(filepath.Join("testdata", "small.json"))
+    if err != nil { t.Fatalf("JSON: %v", err) }
+    fromYAML, err := regftest.LoadFile(filepath.Join("testdata", "small.yaml"))
+    if err != nil { t.Fatalf("YAML: %v", err) }
+
+    if !reflect.DeepEqual(fromJSON, fromYAML) { t.Errorf("JSON and YAML differ:\n%+v\n%+v", fromJSON, fromYAML) }
+
+    a, err := fromJSON.Build()
+    if err != nil { t.Fatal(err) }
+    b, err := fromYAML.Build()
+    if err != nil { t.Fatal(err) }
+    if !bytes.Equal(a, b) { t.Error("JSON and YAML build different files") }
+}
+
+func TestLoadUnknownFields(t *testing.T) {
+    if _, err := regftest.Load(strings.NewReader(`{"keys": [{"path": "A", "valeus": []}]}`)); err == nil {
+        t.Error("Load accepted an unknown field")
+    }
+    if _, err := regftest.LoadYAML(strings.NewReader("keys:\n  - path: A\n    valeus: []\n")); err == nil {
+        t.Error("LoadYAML accepted an unknown field")
+    }
+    if _, err := regftest.LoadYAML(strings.NewReader("keys:\n  - path: A\n    values:\n      - {name: V, typ: REG_SZ}\n")); err == nil {
+        t.Error("LoadYAML accepted an unknown field of a value")
+    }
+}
+
+func TestBuild(t *testing.T) {
+    h, err := regftest.LoadFile(filepath.Join("testdata", "small.yaml"))
+    if err != nil { t.Fatal(err) }
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    file, err := libregf.OpenFile(path)
+    if err != nil { t.Fatal(err) }
+    defer file.Close()
+
+    key, err := file.Key("Software\\Vendor")
+    if err != nil { t.Fatal(err) }
+    defer key.Free()
+
+    if class, err := key.ClassName(); err != nil || class != "VendorClass" { t.Errorf("ClassName: got %q, %v", class, err) }
+    if lw, err := key.LastWritten(); err != nil || !lw.Equal(h.Keys[0].LastWritten) { t.Errorf("LastWritten: got %v, %v", lw, err) }
+    if s, err := key.GetString("Version"); err != nil || s != "1.0" { t.Errorf("Version: got %q, %v", s, err) }
+    if strs, err := key.GetStrings("Paths"); err != nil || !reflect.DeepEqual(strs, []string{"C:\\a", "C:\\b"}) { t.Errorf("Paths: got %q, %v", strs, err) }
+    for _, name := range []string{"Blob", "Bytes"} {
+        if data, err := key.GetBytes(name); err != nil || !bytes.Equal(data, []byte{1, 2, 3}) { t.Errorf("%s: got %x, %v", name, data, err) }
+    }
+    if data, err := key.GetBytes("Big"); err != nil || len(data) != 20000 { t.Errorf("Big: got %d bytes, %v", len(data), err) }
+    if value, err := key.GetValue("Rid"); err != nil {
+        t.Errorf("Rid: %v", err)
+    } else if _type, err := value.Type(); err != nil || _type != 0x3e9 { t.Errorf("Rid: got type %#x, %v", _type, err) }
+
+    if _, err := key.GetString("Old"); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("deleted value: got %v, want ErrNotFound", err) }
+    if n, err := key.GetUint32("Count"); err != nil || n != 42 { t.Errorf("Count: got %d, %v", n, err) }
+    if _, err := file.GetUint32("Software\\Broken\\Count"); err == nil { t.Error("corrupted value was read") }
+}
+
+func TestBuildErrors(t *testing.T) {
+    for _, h := range []*regftest.Hive{
+        {Keys: []regftest.Key{{Path: "A", Values: []regftest.Value{{Name: "V", Type: "REG_WHATEVER"}}}}},
+        {Keys: []regftest.Key{{Path: "A"}}, Corruptions: []regftest.Corruption{{Path: "B", Kind: regftest.BadSignature}}},
+        {Keys: []regftest.Key{{Path: "A"}}, Corruptions: []regftest.Corruption{{Path: "A", Value: "V", Kind: regftest.BadSignature}}},
+        {Keys: []regftest.Key{{Path: "A"}}, Corruptions: []regftest.Corruption{{Path: "A", Kind: "scratch"}}},
+        {Keys: []regftest.Key{{Path: "A"}}, Corruptions: []regftest.Corruption{{Path: "A", Kind: regftest.BadDataSize}}},
+        {Keys: []regftest.Key{{Path: "A"}}, Corruptions: []regftest.Corruption{{Path: "A", Kind: regftest.SubkeyLoop}}},
+        {Keys: []regftest.Key{{Path: "A\\B"}}, Corruptions: []regftest.Corruption{{Kind: regftest.SubkeyLoop}}},
+    } {
+        if _, err := h.Build(); err == nil { t.Errorf("Build succeeded on %+v", *h) }
+    }
+}
+
+func TestBuildDeletedKey(t *testing.T) {
+    h := regftest.Standard()
+    path, err := h.TempFile(t.TempDir())
+    if err != nil { t.Fatal(err) }
+    file, err := libregf.OpenFile(path)
+    if err != nil { t.Fatal(err) }
+    defer file.Close()
+
+    if _, err := file.Key("Deleted"); !errors.Is(err, libregf.ErrNotFound) { t.Errorf("deleted key: got %v, want ErrNotFound", err) }
+
+    // the deleted Key is left behind, as an unallocated cell
+    data, err := h.Build()
+    if err != nil { t.Fatal(err) }
+    if !bytes.Contains(data, []byte("Deleted")) { t.Error("the record of the deleted key was wiped") }
+}
@@ -0,0 +1,54 @@
+package regftest
+
+import (
+    "strings"
+    "time"
+)
+
+// Standard returns the description of a Hive exercising most of what a
+// registry file can hold: Values of every type, big data Values, Keys with a
+// class name or a non ASCII name, a deleted Key and a deleted Value.
+// It has no corruptions; add them to the returned Hive as needed.
+func Standard() *Hive {
+    t := time.Date(2023, 3, 19, 12, 0, 0, 0, time.UTC)
+
+    return &Hive{
+        Timestamp: t,
+        Keys: []Key{
+            {
+                Path:        "Types",
+                Class:       "TypesClass",
+                LastWritten: t.Add(-time.Hour),
+                Values: []Value{
+                    {Name: "", Type: "REG_SZ", String: "default value"},
+                    {Name: "None", Type: "REG_NONE", Data: []byte{1, 2, 3}},
+                    {Name: "String", Type: "REG_SZ", String: "hello, world"},
+                    {Name: "Expandable", Type: "REG_EXPAND_SZ", String: "%SystemRoot%\\System32"},
+                    {Name: "Binary", Type: "REG_BINARY", Data: []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x01}},
+                    {Name: "Dword", Type: "REG_DWORD", Integer: 0xdeadbeef},
+                    {Name: "DwordBigEndian", Type: "REG_DWORD_BIG_ENDIAN", Integer: 0x01020304},
+                    {Name: "Link", Type: "REG_LINK", String: "\\Registry\\Machine\\Software"},
+                    {Name: "Multi", Type: "REG_MULTI_SZ", Strings: []string{"one", "two", "three"}},
+                    {Name: "ResourceList", Type: "REG_RESOURCE_LIST", Data: []byte{1, 0, 0, 0}},
+                    {Name: "Qword", Type: "REG_QWORD", Integer: 1 << 40},
+                    {Name: "Big", Type: "REG_BINARY", Size: 40000},
+                    {Name: "BigString", Type: "REG_SZ", String: strings.Repeat("big data ", 3000)},
+                    {Name: "Gone", Type: "REG_SZ", String: "deleted value", Deleted: true},
+                },
+            },
+            {
+                Path: "Names\\Zürich\\Ελληνικά\\日本語",
+                Values: []Value{
+                    {Name: "Größe", Type: "REG_DWORD", Integer: 1},
+                },
+            },
+            {
+                Path: "Deleted",
+                Values: []Value{
+                    {Name: "Secret", Type: "REG_SZ", String: "recover me"},
+                },
+                Deleted: true,
+            },
+        },
+    }
+}
@@ -0,0 +1,485 @@
+package libregf
+
+import (
+    "encoding/binary"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// UnmarshalTypeError describes a Value that could not be stored in a field of
+// a Go struct by Unmarshal.
+type UnmarshalTypeError struct {
+    Value  string       // name of the Value
+    Type   int          // type of the Value, as returned by (*Value).Type()
+    Field  string       // name of the struct field
+    GoType reflect.Type // type of the struct field
+    Reason string       // optional details
+}
+
+func (e *UnmarshalTypeError) Error() string {
+    s := fmt.Sprintf("libregf: cannot unmarshal value %q of type %d into field %s of type %s", e.Value, e.Type, e.Field, e.GoType)
+    if e.Reason != "" { s += ": " + e.Reason }
+    return s
+}
+
+// keyTimes is implemented by RegistryKeys that know when they were last
+// written to, such as a *Key.
+type keyTimes interface {
+    LastWritten() (time.Time, error)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Unmarshal populates the Go value pointed to by v from key.
+//
+// When v points to a struct, each exported field is read from the Value whose
+// name is given by the field's "reg" tag, or by the field's name when there is
+// no tag. Names are compared the way Windows does. A tag of "-" skips the field,
+// and fields whose Value is missing are left untouched. The name may be followed
+// by comma separated options:
+//
+//   - "string": the Value is a string that is parsed into a numeric, bool or
+//     time.Time field (as "20060102" dates, the format of InstallDate).
+//   - "dword", "qword": the Value must be a 32-bit or a 64-bit integer.
+//   - "keyname": the field receives the name of the Key itself.
+//   - "lastwritten": the field receives the last written time of the Key itself.
+//
+// Values are converted as follows:
+//
+//   - string fields take REG_SZ, REG_EXPAND_SZ and REG_LINK Values, or integers
+//     with the "dword" or "qword" options.
+//   - integer and bool fields take REG_DWORD (either endianness) and REG_QWORD Values.
+//   - []byte fields take the raw data of any Value.
+//   - []string fields take REG_MULTI_SZ Values, or a single string.
+//   - time.Time fields take FILETIMEs stored as REG_QWORD or 8 bytes of REG_BINARY,
+//     or Unix timestamps stored as REG_DWORD.
+//   - interface{} fields take whatever Go type fits the Value best.
+//   - pointer fields are allocated when their Value or sub-Key exists.
+//
+// Fields of struct type are read from the sub-Key of the same name, while slices
+// and maps of structs are populated from each of the sub-Keys of that sub-Key,
+// maps being indexed by sub-Key name. Embedded structs without a tag are read
+// from the Key itself. Likewise, v may point to a slice or a map of structs to
+// read each of the sub-Keys of key, as found under "Uninstall" or "Services".
+func Unmarshal(key RegistryKey, v interface{}) error {
+    rv := reflect.ValueOf(v)
+    if rv.Kind() != reflect.Pointer || rv.IsNil() {
+        return fmt.Errorf("libregf: Unmarshal needs a non-nil pointer, not %T", v)
+    }
+
+    rv = rv.Elem()
+    switch {
+    case rv.Kind() == reflect.Struct && rv.Type() != timeType:
+        return unmarshalKey(key, rv)
+    case isSubkeyList(rv.Type()):
+        return unmarshalSubkeys(key, rv)
+    default:
+        return fmt.Errorf("libregf: Unmarshal needs a pointer to a struct, a slice or a map, not %T", v)
+    }
+}
+
+// isSubkeyList tells whether t is a slice or a map (indexed by strings) of
+// structs or of pointers to structs, which are populated from sub-Keys.
+func isSubkeyList(t reflect.Type) bool {
+    switch t.Kind() {
+    case reflect.Slice:
+    case reflect.Map:
+        if t.Key().Kind() != reflect.String { return false }
+    default:
+        return false
+    }
+
+    e := t.Elem()
+    if e.Kind() == reflect.Pointer { e = e.Elem() }
+    return e.Kind() == reflect.Struct && e != timeType
+}
+
+// keyIndex holds the Values and the names of the sub-Keys of a Key, so fields
+// can be looked up by name without asking the Key each time.
+type keyIndex struct {
+    key     RegistryKey
+    values  map[string]RegistryValue
+    subkeys map[string]int
+}
+
+func newKeyIndex(key RegistryKey) (*keyIndex, error) {
+    n, err := key.ValuesLen()
+    if err != nil { return nil, err }
+
+    idx := &keyIndex{key: key, values: make(map[string]RegistryValue, n)}
+    for i := 0; i < n; i++ {
+        value, err := key.GetValueAt(i)
+        if err != nil { idx.release(); return nil, err }
+        name, err := value.Name()
+        if err != nil { release(value); idx.release(); return nil, err }
+
+        // names are unique in hives written by Windows; keep the first Value,
+        // as LookupValue does
+        upcased := UpcaseName(name)
+        if _, ok := idx.values[upcased]; ok {
+            release(value)
+            continue
+        }
+        idx.values[upcased] = value
+    }
+
+    return idx, nil
+}
+
+// release frees the Values held by the index.
+func (idx *keyIndex) release() {
+    for _, value := range idx.values {
+        release(value)
+    }
+}
+
+// subkey returns the sub-Key by its name, or nil when there is none.
+func (idx *keyIndex) subkey(name string) (RegistryKey, error) {
+    if idx.subkeys == nil {
+        n, err := idx.key.SubkeysLen()
+        if err != nil { return nil, err }
+
+        idx.subkeys = make(map[string]int, n)
+        for i := 0; i < n; i++ {
+            subkey, err := idx.key.GetSubkeyAt(i)
+            if err != nil { return nil, err }
+            subname, err := subkey.Name()
+            release(subkey)
+            if err != nil { return nil, err }
+
+            if _, ok := idx.subkeys[UpcaseName(subname)]; !ok { idx.subkeys[UpcaseName(subname)] = i }
+        }
+    }
+
+    i, ok := idx.subkeys[UpcaseName(name)]
+    if !ok { return nil, nil }
+
+    return idx.key.GetSubkeyAt(i)
+}
+
+// fieldTag is the parsed "reg" tag of a struct field.
+type fieldTag struct {
+    name        string
+    asString    bool
+    dword       bool
+    qword       bool
+    keyName     bool
+    lastWritten bool
+}
+
+func parseFieldTag(field reflect.StructField) (fieldTag, bool) {
+    tag, tagged := field.Tag.Lookup("reg")
+    if tag == "-" { return fieldTag{}, false }
+
+    parts := strings.Split(tag, ",")
+    ft := fieldTag{name: parts[0]}
+    if ft.name == "" && !tagged { ft.name = field.Name }
+    for _, opt := range parts[1:] {
+        switch opt {
+        case "string":
+            ft.asString = true
+        case "dword":
+            ft.dword = true
+        case "qword":
+            ft.qword = true
+        case "keyname":
+            ft.keyName = true
+        case "lastwritten":
+            ft.lastWritten = true
+        }
+    }
+    if ft.name == "" && tagged && !ft.keyName && !ft.lastWritten { ft.name = field.Name }
+
+    return ft, true
+}
+
+// unmarshalKey populates the struct rv from key.
+func unmarshalKey(key RegistryKey, rv reflect.Value) error {
+    idx, err := newKeyIndex(key)
+    if err != nil { return err }
+    defer idx.release()
+
+    return unmarshalFields(idx, rv)
+}
+
+func unmarshalFields(idx *keyIndex, rv reflect.Value) error {
+    t := rv.Type()
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        fv := rv.Field(i)
+
+        _, tagged := field.Tag.Lookup("reg")
+        if field.Anonymous && !tagged && field.Type.Kind() == reflect.Struct {
+            if err := unmarshalFields(idx, fv); err != nil { return err }
+            continue
+        }
+        if !field.IsExported() { continue }
+
+        ft, ok := parseFieldTag(field)
+        if !ok { continue }
+
+        if err := unmarshalField(idx, field, fv, ft); err != nil { return err }
+    }
+
+    return nil
+}
+
+func unmarshalField(idx *keyIndex, field reflect.StructField, fv reflect.Value, ft fieldTag) error {
+    switch {
+    case ft.keyName:
+        name, err := idx.key.Name()
+        if err != nil { return err }
+        return setString(fv, name, field)
+    case ft.lastWritten:
+        kt, ok := idx.key.(keyTimes)
+        if !ok { return nil }
+        lw, err := kt.LastWritten()
+        if err != nil { return err }
+        if fv.Type() != timeType { return fmt.Errorf("libregf: field %s must be a time.Time to receive the last written time", field.Name) }
+        fv.Set(reflect.ValueOf(lw))
+        return nil
+    }
+
+    ftype := field.Type
+    if ftype.Kind() == reflect.Pointer { ftype = ftype.Elem() }
+
+    if (ftype.Kind() == reflect.Struct && ftype != timeType) || isSubkeyList(ftype) {
+        subkey, err := idx.subkey(ft.name)
+        if err != nil { return err }
+        if subkey == nil { return nil }
+        defer release(subkey)
+
+        if ftype.Kind() == reflect.Struct {
+            return unmarshalKey(subkey, allocate(fv))
+        } else {
+            return unmarshalSubkeys(subkey, allocate(fv))
+        }
+    }
+
+    value, ok := idx.values[UpcaseName(ft.name)]
+    if !ok { return nil }
+    if fv.Kind() != reflect.Pointer { return unmarshalValue(value, field, fv, ft) }
+
+    // pointers are only set once the Value converts, so that nil still tells
+    // a missing or unusable Value
+    elem := reflect.New(fv.Type().Elem())
+    if err := unmarshalValue(value, field, elem.Elem(), ft); err != nil { return err }
+    fv.Set(elem)
+    return nil
+}
+
+// allocate returns the value pointed to by fv when it is a pointer, allocating
+// it first if need be, or fv itself.
+func allocate(fv reflect.Value) reflect.Value {
+    if fv.Kind() != reflect.Pointer { return fv }
+    if fv.IsNil() { fv.Set(reflect.New(fv.Type().Elem())) }
+    return fv.Elem()
+}
+
+// unmarshalSubkeys populates the slice or map rv from each of the sub-Keys of key.
+func unmarshalSubkeys(key RegistryKey, rv reflect.Value) error {
+    n, err := key.SubkeysLen()
+    if err != nil { return err }
+
+    t := rv.Type()
+    if t.Kind() == reflect.Map && rv.IsNil() { rv.Set(reflect.MakeMapWithSize(t, n)) }
+
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return err }
+
+        elem := reflect.New(t.Elem()).Elem()
+        err = unmarshalKey(subkey, allocate(elem))
+        if err != nil { release(subkey); return err }
+
+        if t.Kind() == reflect.Slice {
+            rv.Set(reflect.Append(rv, elem))
+        } else {
+            name, err := subkey.Name()
+            if err != nil { release(subkey); return err }
+            rv.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), elem)
+        }
+        release(subkey)
+    }
+
+    return nil
+}
+
+// valueUint returns the data of a REG_DWORD, REG_DWORD_BIG_ENDIAN or REG_QWORD
+// Value as an uint64.
+func valueUint(_type int, data []byte) (uint64, bool) {
+    switch _type {
+    case ValueTypeInteger32BitLittleEndian:
+        if len(data) < 4 { return 0, false }
+        return uint64(le.Uint32(data)), true
+    case ValueTypeInteger32BitBigEndian:
+        if len(data) < 4 { return 0, false }
+        return uint64(binary.BigEndian.Uint32(data)), true
+    case ValueTypeInteger64BitLittleEndian:
+        if len(data) < 8 { return 0, false }
+        return le.Uint64(data), true
+    default:
+        return 0, false
+    }
+}
+
+// isStringType tells whether Values of type _type hold a single string.
+func isStringType(_type int) bool {
+    return _type == ValueTypeString || _type == ValueTypeExpandableString || _type == ValueTypeSymbolicLink
+}
+
+// unmarshalValue stores value into fv, which belongs to field.
+func unmarshalValue(value RegistryValue, field reflect.StructField, fv reflect.Value, ft fieldTag) error {
+    _type, err := value.Type()
+    if err != nil { return err }
+    name, err := value.Name()
+    if err != nil { return err }
+
+    mismatch := func(reason string) error {
+        return &UnmarshalTypeError{Value: name, Type: _type, Field: field.Name, GoType: fv.Type(), Reason: reason}
+    }
+
+    if ft.dword && _type != ValueTypeInteger32BitLittleEndian && _type != ValueTypeInteger32BitBigEndian { return mismatch("not a dword") }
+    if ft.qword && _type != ValueTypeInteger64BitLittleEndian { return mismatch("not a qword") }
+    if ft.asString && !isStringType(_type) { return mismatch("not a string") }
+
+    data, err := value.Data()
+    if err != nil { return err }
+
+    if fv.Type() == timeType {
+        var t time.Time
+        switch {
+        case ft.asString:
+            s, err := value.TString()
+            if err != nil { return err }
+            t, err = time.Parse("20060102", strings.TrimSpace(s))
+            if err != nil { return mismatch(err.Error()) }
+        case _type == ValueTypeInteger32BitLittleEndian || _type == ValueTypeInteger32BitBigEndian:
+            u, _ := valueUint(_type, data)
+            t = time.Unix(int64(u), 0).UTC()
+        case _type == ValueTypeInteger64BitLittleEndian || (_type == ValueTypeBinaryData && len(data) == 8):
+            t = FiletimeToTime(le.Uint64(data))
+        default:
+            return mismatch("")
+        }
+        fv.Set(reflect.ValueOf(t))
+        return nil
+    }
+
+    switch fv.Kind() {
+    case reflect.String:
+        if u, ok := valueUint(_type, data); ok && (ft.dword || ft.qword) {
+            return setString(fv, strconv.FormatUint(u, 10), field)
+        }
+        if !isStringType(_type) { return mismatch("") }
+        s, err := value.TString()
+        if err != nil { return err }
+        return setString(fv, s, field)
+
+    case reflect.Bool:
+        if ft.asString {
+            s, err := value.TString()
+            if err != nil { return err }
+            b, err := strconv.ParseBool(strings.TrimSpace(s))
+            if err != nil { return mismatch(err.Error()) }
+            fv.SetBool(b)
+            return nil
+        }
+        u, ok := valueUint(_type, data)
+        if !ok { return mismatch("") }
+        fv.SetBool(u != 0)
+
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        var i int64
+        if ft.asString {
+            s, err := value.TString()
+            if err != nil { return err }
+            i, err = strconv.ParseInt(strings.TrimSpace(s), 0, 64)
+            if err != nil { return mismatch(err.Error()) }
+        } else {
+            u, ok := valueUint(_type, data)
+            if !ok { return mismatch("") }
+            // DWORDs are stored unsigned, but commonly hold negative numbers.
+            if _type == ValueTypeInteger64BitLittleEndian { i = int64(u) } else { i = int64(int32(uint32(u))) }
+        }
+        if fv.OverflowInt(i) { return mismatch(fmt.Sprintf("%d overflows", i)) }
+        fv.SetInt(i)
+
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        var u uint64
+        if ft.asString {
+            s, err := value.TString()
+            if err != nil { return err }
+            u, err = strconv.ParseUint(strings.TrimSpace(s), 0, 64)
+            if err != nil { return mismatch(err.Error()) }
+        } else {
+            var ok bool
+            u, ok = valueUint(_type, data)
+            if !ok { return mismatch("") }
+        }
+        if fv.OverflowUint(u) { return mismatch(fmt.Sprintf("%d overflows", u)) }
+        fv.SetUint(u)
+
+    case reflect.Slice:
+        switch fv.Type().Elem().Kind() {
+        case reflect.Uint8:
+            fv.SetBytes(append([]byte{}, data...))
+        case reflect.String:
+            var strs []string
+            if _type == ValueTypeMultiValueString {
+                strs, err = value.TStrings()
+                if err != nil { return err }
+            } else if isStringType(_type) {
+                s, err := value.TString()
+                if err != nil { return err }
+                strs = []string{s}
+            } else {
+                return mismatch("")
+            }
+            sv := reflect.MakeSlice(fv.Type(), len(strs), len(strs))
+            for i, s := range strs {
+                sv.Index(i).SetString(s)
+            }
+            fv.Set(sv)
+        default:
+            return mismatch("")
+        }
+
+    case reflect.Interface:
+        if fv.NumMethod() != 0 { return mismatch("") }
+        var x interface{}
+        switch {
+        case isStringType(_type):
+            x, err = value.TString()
+        case _type == ValueTypeMultiValueString:
+            x, err = value.TStrings()
+        case _type == ValueTypeInteger32BitLittleEndian || _type == ValueTypeInteger32BitBigEndian:
+            u, ok := valueUint(_type, data)
+            if !ok { return mismatch("") }
+            x = uint32(u)
+        case _type == ValueTypeInteger64BitLittleEndian:
+            u, ok := valueUint(_type, data)
+            if !ok { return mismatch("") }
+            x = u
+        default:
+            x = append([]byte{}, data...)
+        }
+        if err != nil { return err }
+        fv.Set(reflect.ValueOf(x))
+
+    default:
+        return mismatch("")
+    }
+
+    return nil
+}
+
+// setString stores s into a field of string kind.
+func setString(fv reflect.Value, s string, field reflect.StructField) error {
+    if fv.Kind() != reflect.String { return fmt.Errorf("libregf: field %s must be a string", field.Name) }
+    fv.SetString(s)
+    return nil
+}
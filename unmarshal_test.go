@@ -0,0 +1,138 @@
+package libregf_test
+
+import (
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+// mockKey is a RegistryKey held in memory, whose Values count how many times
+// they are freed, as Values of the libregf backend must be.
+type mockKey struct {
+    name    string
+    values  []*mockValue
+    subkeys []*mockKey
+}
+
+func (k *mockKey) Name() (string, error)      { return k.name, nil }
+func (k *mockKey) ClassName() (string, error) { return "", nil }
+func (k *mockKey) ValuesLen() (int, error)    { return len(k.values), nil }
+func (k *mockKey) SubkeysLen() (int, error)   { return len(k.subkeys), nil }
+
+func (k *mockKey) GetValueAt(index int) (libregf.RegistryValue, error) {
+    k.values[index].opened++
+    return k.values[index], nil
+}
+
+func (k *mockKey) GetValue(name string) (libregf.RegistryValue, error) {
+    for i, v := range k.values {
+        if libregf.EqualNames(v.name, name) { return k.GetValueAt(i) }
+    }
+    return nil, fmt.Errorf("%w: value %q", libregf.ErrNotFound, name)
+}
+
+func (k *mockKey) GetSubkeyAt(index int) (libregf.RegistryKey, error) {
+    return k.subkeys[index], nil
+}
+
+func (k *mockKey) GetSubkey(name string) (libregf.RegistryKey, error) {
+    return libregf.LookupSubkey(k, name)
+}
+
+// mockValue is a REG_SZ RegistryValue.
+type mockValue struct {
+    name           string
+    data           string
+    opened, freed  int
+}
+
+func (v *mockValue) Name() (string, error)       { return v.name, nil }
+func (v *mockValue) Type() (int, error)          { return libregf.ValueTypeString, nil }
+func (v *mockValue) Data() ([]byte, error)       { return []byte(v.data), nil }
+func (v *mockValue) TString() (string, error)    { return v.data, nil }
+func (v *mockValue) TStrings() ([]string, error) { return nil, libregf.ErrWrongType }
+func (v *mockValue) TBinary() ([]byte, error)    { return nil, libregf.ErrWrongType }
+func (v *mockValue) Tint32() (int, error)        { return 0, libregf.ErrWrongType }
+func (v *mockValue) Tint64() (int, error)        { return 0, libregf.ErrWrongType }
+func (v *mockValue) Free() error                 { v.freed++; return nil }
+
+func TestUnmarshalDuplicateNames(t *testing.T) {
+    key := &mockKey{
+        values: []*mockValue{{name: "Name", data: "first"}, {name: "NAME", data: "second"}},
+        subkeys: []*mockKey{
+            {name: "Sub", values: []*mockValue{{name: "Name", data: "first sub key"}}},
+            {name: "SUB", values: []*mockValue{{name: "Name", data: "second sub key"}}},
+        },
+    }
+
+    var v struct {
+        Name string
+        Sub  struct{ Name string }
+    }
+    if err := libregf.Unmarshal(key, &v); err != nil { t.Fatal(err) }
+
+    if v.Name != "first" { t.Errorf("Name: got %q, want the first value", v.Name) }
+    if v.Sub.Name != "first sub key" { t.Errorf("Sub.Name: got %q, want the first sub key's", v.Sub.Name) }
+
+    for _, k := range append([]*mockKey{key}, key.subkeys...) {
+        for _, value := range k.values {
+            if value.freed != value.opened { t.Errorf("value %q of %q opened %d times, freed %d times", value.data, k.name, value.opened, value.freed) }
+        }
+    }
+}
+
+// FILETIMEs cover years 1601 to 60056, far more than a time.Duration does.
+func TestUnmarshalFiletimes(t *testing.T) {
+    for _, test := range []struct {
+        ft   uint64
+        want time.Time
+    }{
+        {1, time.Date(1601, 1, 1, 0, 0, 0, 100, time.UTC)},
+        {116444736000000000, time.Unix(0, 0).UTC()},
+        {133000000000000000, time.Date(2022, 6, 18, 4, 26, 40, 0, time.UTC)},
+        {300000000000000000, time.Unix(18355526400, 0).UTC()},
+        {0xffffffffffffffff, time.Unix(1833029933770, 955161500).UTC()},
+    } {
+        data := binary.LittleEndian.AppendUint64(nil, test.ft)
+        h := &regftest.Hive{Keys: []regftest.Key{{Path: "K", LastWritten: test.want, Values: []regftest.Value{
+            {Name: "Qword", Type: "REG_QWORD", Integer: test.ft},
+            {Name: "Binary", Type: "REG_BINARY", Data: data},
+        }}}}
+        key, err := openHive(t, h).Key("K")
+        if err != nil { t.Fatal(err) }
+
+        var v struct {
+            Qword, Binary time.Time
+            LastWritten   time.Time `reg:",lastwritten"`
+        }
+        if err := libregf.Unmarshal(key, &v); err != nil { t.Fatal(err) }
+        key.Free()
+
+        if got := libregf.FiletimeToTime(test.ft); !got.Equal(test.want) { t.Errorf("FiletimeToTime(%#x): got %v, want %v", test.ft, got, test.want) }
+        if !v.Qword.Equal(test.want) { t.Errorf("REG_QWORD %#x: got %v, want %v", test.ft, v.Qword, test.want) }
+        if !v.Binary.Equal(test.want) { t.Errorf("REG_BINARY %#x: got %v, want %v", test.ft, v.Binary, test.want) }
+        // written and read back through the Writer
+        if !v.LastWritten.Equal(test.want) { t.Errorf("LastWritten %#x: got %v, want %v", test.ft, v.LastWritten, test.want) }
+    }
+}
+
+func TestUnmarshalPointers(t *testing.T) {
+    key := &mockKey{values: []*mockValue{{name: "Name", data: "name"}, {name: "Count", data: "not a number"}}}
+
+    var v struct {
+        Name    *string
+        Count   *uint32
+        Missing *string
+    }
+    var typeErr *libregf.UnmarshalTypeError
+    if err := libregf.Unmarshal(key, &v); !errors.As(err, &typeErr) { t.Fatalf("got %v, want an UnmarshalTypeError", err) }
+
+    if v.Name == nil || *v.Name != "name" { t.Errorf("Name: got %v", v.Name) }
+    if v.Count != nil { t.Errorf("Count: got %d, want nil for a Value that doesn't convert", *v.Count) }
+    if v.Missing != nil { t.Errorf("Missing: got %q, want nil", *v.Missing) }
+}
@@ -1,3 +1,5 @@
+//go:build cgo && !purego
+
 package libregf
 
 /*
@@ -7,13 +9,17 @@ package libregf
 import "C"
 
 import (
+    "bytes"
     "fmt"
-    "strings"
+    "io"
     "unsafe"
 )
 
-// Value is an opaque struct to group related method calls
-type Value       C.libregf_value_t
+// Value is a registry value read by libregf, along with the File it belongs to.
+type Value struct {
+    ptr  *C.libregf_value_t
+    file *File
+}
 // MultiString is an opaque struct to group related method calls
 type MultiString C.libregf_multi_string_t
 
@@ -23,7 +29,7 @@ func (value *Value) Free() error {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_free((**C.libregf_value_t)(unsafe.Pointer(&value)), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_free(&value.ptr, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -44,7 +50,7 @@ func (value *Value) NameLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_utf8_name_size((*C.libregf_value_t)(value), (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_utf8_name_size(value.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -62,6 +68,7 @@ func (value *Value) NameLen() (int, error) {
 func (value *Value) Name() (string, error) { 
     namelen, err := value.NameLen()
     if err != nil { return "", err }
+    if namelen == 0 { return "", nil }
 
     buffer := make([]byte, namelen+1)
     cstr := C.CString(string(buffer[:namelen]))
@@ -69,7 +76,7 @@ func (value *Value) Name() (string, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_utf8_name((*C.libregf_value_t)(value), (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_utf8_name(value.ptr, (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -80,6 +87,106 @@ func (value *Value) Name() (string, error) {
     }
 }
 
+// RawNameLen returns the length (in bytes) of the Value's name as stored in the file.
+// It wraps libregf_value_get_name_size().
+// You don't need to call this function if you call RawName(), which calls RawNameLen().
+func (value *Value) RawNameLen() (int, error) { 
+    var namelen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_name_size(value.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(namelen), nil
+    }
+}
+
+// RawName returns the Value's name as stored in the file, without decoding it:
+// either UTF-16LE or, when NameIsCompressed() is true, one byte per character
+// in the codepage of the file. Unlike Name(), it preserves names that don't
+// decode properly, as found when names are hidden on purpose.
+// It wraps libregf_value_get_name().
+func (value *Value) RawName() ([]byte, error) { 
+    namelen, err := value.RawNameLen()
+    if err != nil { return []byte{}, err }
+    if namelen == 0 { return []byte{}, nil }
+
+    buffer := make([]byte, namelen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_name(value.ptr, (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []byte{}, fmt.Errorf("%s", pe.String())
+    } else {
+        return buffer, nil
+    }
+}
+
+// UTF16NameLen returns the length (in UTF-16 code units) of the Value's name,
+// including a terminating NUL.
+// It wraps libregf_value_get_utf16_name_size().
+// You don't need to call this function if you call UTF16Name(), which calls UTF16NameLen().
+func (value *Value) UTF16NameLen() (int, error) { 
+    var namelen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_utf16_name_size(value.ptr, (*C.size_t)(&namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(namelen), nil
+    }
+}
+
+// UTF16Name returns the Value's name as UTF-16 code units, without a terminating
+// NUL, decoding compressed names with the codepage of the file.
+// It wraps libregf_value_get_utf16_name().
+func (value *Value) UTF16Name() ([]uint16, error) { 
+    namelen, err := value.UTF16NameLen()
+    if err != nil { return []uint16{}, err }
+    if namelen == 0 { return []uint16{}, nil }
+
+    buffer := make([]uint16, namelen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_utf16_name(value.ptr, (*C.uint16_t)(unsafe.Pointer(&buffer[0])), C.ulong(namelen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []uint16{}, fmt.Errorf("%s", pe.String())
+    } else {
+        if buffer[namelen-1] == 0 { buffer = buffer[:namelen-1] }
+        return buffer, nil
+    }
+}
+
+// NameIsCompressed tells whether the Value's name is stored in the compressed
+// (one byte per character) form rather than in UTF-16LE.
+// libregf doesn't expose the flag, so this compares RawName() to UTF16Name().
+func (value *Value) NameIsCompressed() (bool, error) {
+    raw, err := value.RawName()
+    if err != nil { return false, err }
+    units, err := value.UTF16Name()
+    if err != nil { return false, err }
+
+    return !bytes.Equal(raw, encodeUTF16Units(units)), nil
+}
+
 // Type returns the value's type.
 // It wraps libregf_value_get_value_type().
 func (value *Value) Type() (int, error) { 
@@ -87,7 +194,7 @@ func (value *Value) Type() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_type((*C.libregf_value_t)(value), &_type, (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_type(value.ptr, &_type, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -98,6 +205,60 @@ func (value *Value) Type() (int, error) {
     }
 }
 
+// DataLen returns the length (in bytes) of the Value's raw data, whatever its type.
+// It wraps libregf_value_get_value_data_size().
+// You don't need to call this function if you call Data(), which calls DataLen().
+func (value *Value) DataLen() (int, error) { 
+    var dlen C.size_t
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_value_data_size(value.ptr, (*C.size_t)(&dlen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return -1, fmt.Errorf("%s", pe.String())
+    } else {
+        return int(dlen), nil
+    }
+}
+
+// Data returns the Value's raw data as a Go []byte, whatever its type, without
+// any of the conversions done by the typed getters.
+// It wraps libregf_value_get_value_data().
+func (value *Value) Data() ([]byte, error) { 
+    dlen, err := value.DataLen()
+    if err != nil { return []byte{}, err }
+    if dlen == 0 { return []byte{}, nil }
+    if err := value.file.options.checkValueSize(int64(dlen)); err != nil { return []byte{}, err }
+
+    buffer := make([]byte, dlen)
+    var cerr Error
+    ppe := unsafe.Pointer(&cerr)
+
+    res := int(C.libregf_value_get_value_data(value.ptr, (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(dlen), (**C.libregf_error_t)(ppe)))
+    pe := *(**Error)(ppe)
+    defer pe.Free()
+
+    if res != 1 {
+        return []byte{}, fmt.Errorf("%s", pe.String())
+    } else {
+        return buffer, nil
+    }
+}
+
+// Reader returns a reader over the Value's raw data, as returned by Data().
+// It doesn't stream: libregf only gives access to the whole data at once, so
+// the data is read into memory before Reader returns, and Reader fails with
+// ErrValueTooLarge as Data() does. Only the pure Go backend streams big data.
+func (value *Value) Reader() (*io.SectionReader, error) {
+    data, err := value.Data()
+    if err != nil { return nil, err }
+
+    return io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data))), nil
+}
+
 // TStringLen returns the length (in bytes) of a value of type LIBREGF_VALUE_TYPE_STRING
 // It wraps libregf_value_get_value_utf8_string_size().
 // You don't need to call this function if you call TString(), which calls TStringLen().
@@ -106,7 +267,7 @@ func (value *Value) TStringLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_utf8_string_size((*C.libregf_value_t)(value), (*C.size_t)(&tlen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_utf8_string_size(value.ptr, (*C.size_t)(&tlen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -122,6 +283,7 @@ func (value *Value) TStringLen() (int, error) {
 func (value *Value) TString() (string, error) { 
     tlen, err := value.TStringLen()
     if err != nil { return "", err }
+    if err := value.file.options.checkValueSize(int64(tlen)); err != nil { return "", err }
 
     buffer := make([]byte, tlen+1)
     cstr := C.CString(string(buffer[:tlen]))
@@ -129,7 +291,7 @@ func (value *Value) TString() (string, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_utf8_string((*C.libregf_value_t)(value), (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(tlen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_utf8_string(value.ptr, (*C.uint8_t)(unsafe.Pointer(cstr)), C.ulong(tlen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -148,7 +310,7 @@ func (value *Value) TBinaryLen() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_binary_data_size((*C.libregf_value_t)(value), (*C.size_t)(&tlen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_binary_data_size(value.ptr, (*C.size_t)(&tlen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -164,12 +326,14 @@ func (value *Value) TBinaryLen() (int, error) {
 func (value *Value) TBinary() ([]byte, error) { 
     tlen, err := value.TBinaryLen()
     if err != nil { return []byte{}, err }
+    if tlen == 0 { return []byte{}, nil }
+    if err := value.file.options.checkValueSize(int64(tlen)); err != nil { return []byte{}, err }
 
     buffer := make([]byte, tlen)
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_binary_data((*C.libregf_value_t)(value), (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(tlen), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_binary_data(value.ptr, (*C.uint8_t)(unsafe.Pointer(&buffer[0])), C.ulong(tlen), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -187,7 +351,7 @@ func (value *Value) Tint32() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_32bit((*C.libregf_value_t)(value), &cint, (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_32bit(value.ptr, &cint, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -205,7 +369,7 @@ func (value *Value) Tint64() (int, error) {
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_64bit((*C.libregf_value_t)(value), &cint, (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_64bit(value.ptr, &cint, (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
 
@@ -221,12 +385,16 @@ func (value *Value) Tint64() (int, error) {
 // the (*MultiString) methods.
 // It wraps libregf_value_get_value_multi_string().
 func (value *Value) TMultiString() (*MultiString, error) { 
+    dlen, err := value.DataLen()
+    if err != nil { return nil, err }
+    if err := value.file.options.checkValueSize(int64(dlen)); err != nil { return nil, err }
+
     var ms MultiString
     ppms := unsafe.Pointer(&ms)
     var cerr Error
     ppe := unsafe.Pointer(&cerr)
 
-    res := int(C.libregf_value_get_value_multi_string((*C.libregf_value_t)(value), (**C.libregf_multi_string_t)(ppms), (**C.libregf_error_t)(ppe)))
+    res := int(C.libregf_value_get_value_multi_string(value.ptr, (**C.libregf_multi_string_t)(ppms), (**C.libregf_error_t)(ppe)))
     pe := *(**Error)(ppe)
     defer pe.Free()
     pms := *(**MultiString)(ppms)
@@ -238,56 +406,6 @@ func (value *Value) TMultiString() (*MultiString, error) {
     }
 }
 
-// String returns any possible value as a string. These results may be
-// truncated depending on the type and size of the underlying value.
-func (value *Value) String() (string, error) {
-    _type, err := value.Type()
-    if err != nil { return "", err }
-
-    switch _type {
-    case C.LIBREGF_VALUE_TYPE_STRING:
-        tstr, err := value.TString()
-        if err != nil { return "", err }
-        return tstr, nil
-    case C.LIBREGF_VALUE_TYPE_EXPANDABLE_STRING:
-        tstr, err := value.TString()
-        if err != nil { return "", err }
-        return tstr, nil
-    case C.LIBREGF_VALUE_TYPE_MULTI_VALUE_STRING:
-        ms, err := value.TMultiString()
-        if err != nil { return "", err }
-        strs, err := ms.Strings()
-        if err != nil { return "", err }
-        l := len(strs)
-        extra := ""
-        if l > 4 {
-            l = 4
-            extra = "…"
-        }
-        return strings.Join(strs[:l], ", ") + extra, nil
-    case C.LIBREGF_VALUE_TYPE_BINARY_DATA:
-        tbin, err := value.TBinary()
-        if err != nil { return "", err }
-        l := len(tbin)
-        extra := ""
-        if l > 40 {
-            l = 40
-            extra = "…"
-        }
-        return fmt.Sprintf("%x", tbin[:l]) + extra, nil
-    case C.LIBREGF_VALUE_TYPE_INTEGER_32BIT_LITTLE_ENDIAN:
-        i, err := value.Tint32()
-        if err != nil { return "", err }
-        return fmt.Sprintf("%d", i), nil
-    case C.LIBREGF_VALUE_TYPE_INTEGER_64BIT_LITTLE_ENDIAN:
-        i, err := value.Tint64()
-        if err != nil { return "", err }
-        return fmt.Sprintf("%d", i), nil
-    default:
-        return fmt.Sprintf("[Please implement value type %d]", _type), nil
-    }
-}
-
 // Free frees the memory allocated by C to an opaque *MultiString.
 // It wraps libregf_multi_string_free().
 // Most of the time you will just defer a call to Free() right after calling
@@ -367,19 +485,3 @@ func (ms *MultiString) StringAt(index int) (string, error) {
         return C.GoString(cstr), nil
     }
 }
-
-// Strings returns all of the strings inside a MultiString as a []string.
-func (ms *MultiString) Strings() ([]string, error) {
-    slen, err := ms.StringsLen()
-    if err != nil { return []string{}, err }
-
-    strs := make([]string, slen)
-    for i := 0; i < slen; i++ {
-        s, err := ms.StringAt(i)
-        if err != nil { return []string{}, nil }
-
-        strs[i] = s
-    }
-
-    return strs, nil
-} 
@@ -0,0 +1,236 @@
+//go:build !cgo || purego
+
+package libregf
+
+import (
+    "io"
+    "unicode/utf16"
+)
+
+// Value is a registry value read by the pure Go backend.
+type Value struct {
+    file   *File
+    offset uint32
+    vk     []byte
+}
+
+// MultiString holds the strings of a value of type LIBREGF_VALUE_TYPE_MULTI_VALUE_STRING.
+type MultiString struct {
+    strs []string
+}
+
+// value reads the value (vk) record at offset.
+func (file *File) value(offset uint32) (*Value, error) {
+    vk, err := file.hive.record(offset, "vk", vkHeaderSize)
+    if err != nil { return nil, err }
+
+    if vkHeaderSize+int(le.Uint16(vk[2:])) > len(vk) {
+        return nil, regfError("name of value at offset 0x%08x exceeds its cell", offset)
+    }
+
+    return &Value{file: file, offset: offset, vk: vk}, nil
+}
+
+// Free exists for parity with the libregf backend. Values are garbage collected.
+func (value *Value) Free() error {
+    return nil
+}
+
+// NameLen returns the length (in bytes) of the Value's name, counting a
+// terminating NUL as the libregf backend does.
+// You don't need to call this function if you call Name(), which decodes the name.
+func (value *Value) NameLen() (int, error) {
+    name, err := value.Name()
+    if err != nil { return -1, err }
+
+    return len(name) + 1, nil
+}
+
+// Name returns the Value's name. The default Value of a Key has an empty name.
+func (value *Value) Name() (string, error) {
+    if value.nameIsCompressed() {
+        return decodeCompressedName(value.rawName(), value.file.table), nil
+    } else {
+        return decodeUTF16(value.rawName()), nil
+    }
+}
+
+// RawNameLen returns the length (in bytes) of the Value's name as stored in the file.
+// You don't need to call this function if you call RawName(), which reads the name.
+func (value *Value) RawNameLen() (int, error) {
+    return int(le.Uint16(value.vk[2:])), nil
+}
+
+// RawName returns the Value's name as stored in the file, without decoding it:
+// either UTF-16LE or, when NameIsCompressed() is true, one byte per character
+// in the codepage of the file. Unlike Name(), it preserves names that don't
+// decode properly, as found when names are hidden on purpose.
+func (value *Value) RawName() ([]byte, error) {
+    return append([]byte{}, value.rawName()...), nil
+}
+
+// UTF16NameLen returns the length (in UTF-16 code units) of the Value's name,
+// counting a terminating NUL as the libregf backend does.
+// You don't need to call this function if you call UTF16Name(), which decodes the name.
+func (value *Value) UTF16NameLen() (int, error) {
+    units, err := value.UTF16Name()
+    if err != nil { return -1, err }
+
+    return len(units) + 1, nil
+}
+
+// UTF16Name returns the Value's name as UTF-16 code units, without a terminating
+// NUL, decoding compressed names with the codepage of the file.
+func (value *Value) UTF16Name() ([]uint16, error) {
+    if value.nameIsCompressed() {
+        return utf16.Encode([]rune(decodeCompressedName(value.rawName(), value.file.table))), nil
+    } else {
+        return decodeUTF16Units(value.rawName()), nil
+    }
+}
+
+// NameIsCompressed tells whether the Value's name is stored in the compressed
+// (one byte per character) form rather than in UTF-16LE.
+func (value *Value) NameIsCompressed() (bool, error) {
+    return value.nameIsCompressed(), nil
+}
+
+func (value *Value) rawName() []byte {
+    return value.vk[vkHeaderSize : vkHeaderSize+int(le.Uint16(value.vk[2:]))]
+}
+
+func (value *Value) nameIsCompressed() bool {
+    return le.Uint16(value.vk[16:])&valueFlagCompressedName != 0
+}
+
+// Type returns the value's type.
+func (value *Value) Type() (int, error) {
+    return int(le.Uint32(value.vk[12:])), nil
+}
+
+// DataLen returns the length (in bytes) of the Value's raw data, whatever its
+// type, as declared by the Value. It doesn't read the data.
+// You don't need to call this function if you call Data(), which reads the data.
+func (value *Value) DataLen() (int, error) {
+    return int(valueDataSize(value.vk)), nil
+}
+
+// Data returns the Value's raw data as a Go []byte, whatever its type, without
+// any of the conversions done by the typed getters.
+// It fails with ErrValueTooLarge when the data is larger than the maximum size
+// set by WithMaxValueSize.
+func (value *Value) Data() ([]byte, error) {
+    if err := value.file.options.checkValueSize(valueDataSize(value.vk)); err != nil { return nil, err }
+
+    return value.file.hive.valueData(value.vk)
+}
+
+// Reader returns a reader over the Value's raw data, as returned by Data().
+// Big data is read segment by segment as the reader reaches it, so Values
+// larger than the maximum size set by WithMaxValueSize can still be read.
+func (value *Value) Reader() (*io.SectionReader, error) {
+    return value.file.hive.valueReader(value.vk)
+}
+
+// data returns the raw data of the Value after checking its type is one of types.
+func (value *Value) data(types ...int) ([]byte, error) {
+    _type, _ := value.Type()
+    for _, t := range types {
+        if t == _type { return value.Data() }
+    }
+
+    return nil, regfError("unsupported value type %d", _type)
+}
+
+// TStringLen returns the length (in bytes) of a value of type LIBREGF_VALUE_TYPE_STRING,
+// counting a terminating NUL as the libregf backend does.
+// You don't need to call this function if you call TString(), which decodes the string.
+func (value *Value) TStringLen() (int, error) {
+    s, err := value.TString()
+    if err != nil { return -1, err }
+
+    return len(s) + 1, nil
+}
+
+// TString returns a value of type LIBREGF_VALUE_TYPE_STRING as a Go string
+func (value *Value) TString() (string, error) {
+    data, err := value.data(ValueTypeString, ValueTypeExpandableString, ValueTypeSymbolicLink)
+    if err != nil { return "", err }
+
+    return decodeUTF16(data), nil
+}
+
+// TBinaryLen returns the length (in bytes) of a value of type LIBREGF_VALUE_TYPE_BINARY_DATA
+// You don't need to call this function if you call TBinary(), which reads the data.
+func (value *Value) TBinaryLen() (int, error) {
+    data, err := value.TBinary()
+    if err != nil { return -1, err }
+
+    return len(data), nil
+}
+
+// TBinary returns a value of type LIBREGF_VALUE_TYPE_BINARY_DATA as a Go []byte
+func (value *Value) TBinary() ([]byte, error) {
+    data, err := value.data(ValueTypeUndefined, ValueTypeBinaryData, ValueTypeResourceList, ValueTypeFullResourceDescriptor, ValueTypeResourceRequirementsList)
+    if err != nil { return []byte{}, err }
+
+    return data, nil
+}
+
+// Tint32 returns a value of type LIBREGF_VALUE_TYPE_INTEGER_32BIT_LITTLE_ENDIAN
+// (or big endian) as a Go int
+func (value *Value) Tint32() (int, error) {
+    data, err := value.data(ValueTypeInteger32BitLittleEndian, ValueTypeInteger32BitBigEndian)
+    if err != nil { return -1, err }
+    if len(data) < 4 { return -1, regfError("invalid 32-bit value data size %d", len(data)) }
+
+    if _type, _ := value.Type(); _type == ValueTypeInteger32BitBigEndian {
+        return int(uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])), nil
+    }
+    return int(le.Uint32(data)), nil
+}
+
+// Tint64 returns a value of type LIBREGF_VALUE_TYPE_INTEGER_64BIT_LITTLE_ENDIAN as a Go int
+func (value *Value) Tint64() (int, error) {
+    data, err := value.data(ValueTypeInteger64BitLittleEndian)
+    if err != nil { return -1, err }
+    if len(data) < 8 { return -1, regfError("invalid 64-bit value data size %d", len(data)) }
+
+    return int(le.Uint64(data)), nil
+}
+
+// TMultiString returns a value of type LIBREGF_VALUE_TYPE_MULTI_VALUE_STRING as a
+// pointer to a MultiString struct. You can only access the inner strings through
+// the (*MultiString) methods.
+func (value *Value) TMultiString() (*MultiString, error) {
+    data, err := value.data(ValueTypeMultiValueString)
+    if err != nil { return nil, err }
+
+    return &MultiString{strs: decodeMultiUTF16(data)}, nil
+}
+
+// Free exists for parity with the libregf backend. MultiStrings are garbage collected.
+func (ms *MultiString) Free() error {
+    return nil
+}
+
+// StringsLen returns the number of strings contained in a MultiString.
+func (ms *MultiString) StringsLen() (int, error) {
+    return len(ms.strs), nil
+}
+
+// StringLenAt returns the length (in bytes) of a particular string
+// in a MultiString, counting a terminating NUL as the libregf backend does.
+func (ms *MultiString) StringLenAt(index int) (int, error) {
+    s, err := ms.StringAt(index)
+    if err != nil { return -1, err }
+
+    return len(s) + 1, nil
+}
+
+// StringAt returns the string at a position inside a MultiString.
+func (ms *MultiString) StringAt(index int) (string, error) {
+    if index < 0 || index >= len(ms.strs) { return "", regfError("invalid string index %d", index) }
+
+    return ms.strs[index], nil
+}
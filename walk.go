@@ -0,0 +1,184 @@
+package libregf
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// WalkFunc is the type of the function called by Walk for every Key it visits.
+// path is the Key's path relative to the root Key, as accepted by File.Key(),
+// so the root Key itself has an empty path.
+// The Key is freed once the function returns, so don't hold on to it.
+// If the function returns SkipKey, the Key's sub-Keys are not visited.
+// Any other error stops the walk and is returned by Walk.
+type WalkFunc func(path string, key RegistryKey) error
+
+// SkipKey is used as a return value from a WalkFunc to skip the sub-Keys of
+// the Key being visited. It is never returned as an error by Walk.
+var SkipKey = errors.New("skip this key")
+
+// Match is a single hit returned by Search.
+// Value is empty when the Key's name itself matched.
+type Match struct {
+    Path  string
+    Value string
+}
+
+// Walk visits every Key of a registry file, depth first, starting at the root Key.
+func (file *File) Walk(fn WalkFunc) error {
+    return Walk(file, fn)
+}
+
+// WalkContext is like Walk, but stops as soon as ctx is cancelled. In that case
+// it returns ctx.Err().
+func (file *File) WalkContext(ctx context.Context, fn WalkFunc) error {
+    return WalkContext(ctx, file, fn)
+}
+
+// Walk is File.Walk for any Hive.
+func Walk(h Hive, fn WalkFunc) error {
+    return WalkContext(context.Background(), h, fn)
+}
+
+// WalkContext is File.WalkContext for any Hive. Cancellation is checked before
+// each Key is visited; the Hive isn't asked to abort, since libregf has no way
+// to clear that request and every later call on the File would fail. Errors
+// met once ctx is cancelled are reported as ctx.Err().
+func WalkContext(ctx context.Context, h Hive, fn WalkFunc) error {
+    root, err := h.GetRootKey()
+    if err != nil { return ctxErr(ctx, err) }
+    defer release(root)
+
+    return ctxErr(ctx, walk(ctx, "", root, fn))
+}
+
+// walk calls fn for key, found at path, then for each of its sub-Keys in turn.
+func walk(ctx context.Context, path string, key RegistryKey, fn WalkFunc) error {
+    return walkKey(ctx, path, key, fn, map[int64]bool{}, 0)
+}
+
+// maxKeyDepth is how deep Keys can be nested: Windows limits registry paths
+// to 512 levels.
+const maxKeyDepth = 512
+
+// ErrKeyLoop is returned by Walk and the functions built on it when the sub
+// keys lists of a hive lead back to a Key already visited, or nest Keys deeper
+// than Windows allows, as only damaged or crafted hives do.
+var ErrKeyLoop = errors.New("libregf: sub keys loop back to a visited key")
+
+// offsetter is implemented by Keys that know the offset of their record in
+// the file, such as *Key.
+type offsetter interface {
+    Offset() (int64, error)
+}
+
+// enterKey records key, nested depth levels below the Key a recursion started
+// at, in visited. It fails with ErrKeyLoop when key was already visited or is
+// nested too deep.
+func enterKey(key RegistryKey, visited map[int64]bool, depth int) error {
+    if depth > maxKeyDepth { return fmt.Errorf("%w: keys nested more than %d levels deep", ErrKeyLoop, maxKeyDepth) }
+
+    if o, ok := key.(offsetter); ok {
+        offset, err := o.Offset()
+        if err != nil { return err }
+        if visited[offset] { return fmt.Errorf("%w: key at offset 0x%08x", ErrKeyLoop, offset) }
+        visited[offset] = true
+    }
+
+    return nil
+}
+
+// walkKey is walk for a Key nested depth levels below where the walk started.
+// visited holds the offsets of the Keys visited so far, when they are known.
+func walkKey(ctx context.Context, path string, key RegistryKey, fn WalkFunc, visited map[int64]bool, depth int) error {
+    if err := ctx.Err(); err != nil { return err }
+    if err := enterKey(key, visited, depth); err != nil { return fmt.Errorf("%s: %w", path, err) }
+
+    err := fn(path, key)
+    if err == SkipKey { return nil }
+    if err != nil { return err }
+
+    n, err := key.SubkeysLen()
+    if err != nil { return err }
+
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return err }
+        name, err := subkey.Name()
+        if err != nil {
+            release(subkey)
+            return err
+        }
+
+        err = walkKey(ctx, joinPath(path, name), subkey, fn, visited, depth+1)
+        release(subkey)
+        if err != nil { return err }
+    }
+
+    return nil
+}
+
+// joinPath appends a Key name to a registry path.
+func joinPath(path, name string) string {
+    if path == "" { return name }
+    return path + "\\" + name
+}
+
+// Search walks a registry file looking for term, without regard to case, in
+// Key names, Value names and the string representation of Values' data.
+func (file *File) Search(term string) ([]Match, error) {
+    return Search(file, term)
+}
+
+// SearchContext is like Search, but stops as soon as ctx is cancelled. In that
+// case it returns ctx.Err() along with the matches found so far.
+func (file *File) SearchContext(ctx context.Context, term string) ([]Match, error) {
+    return SearchContext(ctx, file, term)
+}
+
+// Search is File.Search for any Hive.
+func Search(h Hive, term string) ([]Match, error) {
+    return SearchContext(context.Background(), h, term)
+}
+
+// SearchContext is File.SearchContext for any Hive.
+func SearchContext(ctx context.Context, h Hive, term string) ([]Match, error) {
+    term = strings.ToLower(term)
+    matches := []Match{}
+
+    err := WalkContext(ctx, h, func(path string, key RegistryKey) error {
+        name, err := key.Name()
+        if err != nil { return err }
+        if path != "" && strings.Contains(strings.ToLower(name), term) {
+            matches = append(matches, Match{Path: path})
+        }
+
+        n, err := key.ValuesLen()
+        if err != nil { return err }
+
+        for i := 0; i < n; i++ {
+            if err := ctx.Err(); err != nil { return err }
+
+            value, err := key.GetValueAt(i)
+            if err != nil { return err }
+            vname, err := value.Name()
+            if err != nil {
+                release(value)
+                return err
+            }
+            s, err := FormatValue(value)
+            release(value)
+            if err != nil { return err }
+
+            if strings.Contains(strings.ToLower(vname), term) || strings.Contains(strings.ToLower(s), term) {
+                matches = append(matches, Match{Path: path, Value: vname})
+            }
+        }
+
+        return nil
+    })
+
+    return matches, err
+}
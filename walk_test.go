@@ -0,0 +1,66 @@
+package libregf_test
+
+import (
+    "context"
+    "errors"
+    "reflect"
+    "testing"
+
+    "github.com/jdrowell/go-libregf"
+    "github.com/jdrowell/go-libregf/regftest"
+)
+
+func TestWalkContextCancelled(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+    want := keyPaths(t, file)
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if err := file.WalkContext(ctx, func(string, libregf.RegistryKey) error { return nil }); !errors.Is(err, context.Canceled) {
+        t.Fatalf("WalkContext with a cancelled context: got %v, want context.Canceled", err)
+    }
+
+    // cancelled half way through
+    ctx, cancel = context.WithCancel(context.Background())
+    visited := 0
+    err := file.WalkContext(ctx, func(path string, key libregf.RegistryKey) error {
+        visited++
+        if visited == 2 { cancel() }
+        return nil
+    })
+    if !errors.Is(err, context.Canceled) { t.Fatalf("WalkContext cancelled while walking: got %v, want context.Canceled", err) }
+    if visited != 2 { t.Errorf("WalkContext visited %d Keys after being cancelled at the 2nd", visited) }
+
+    // the File must remain usable
+    if got := keyPaths(t, file); !reflect.DeepEqual(got, want) {
+        t.Errorf("Walk after a cancelled WalkContext: got %q, want %q", got, want)
+    }
+    if _, err := file.Key("Types"); err != nil { t.Errorf("Key after a cancelled WalkContext: %v", err) }
+}
+
+func TestSearchContextCancelled(t *testing.T) {
+    file := openHive(t, regftest.Standard())
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := file.SearchContext(ctx, "hello"); !errors.Is(err, context.Canceled) {
+        t.Fatalf("SearchContext with a cancelled context: got %v, want context.Canceled", err)
+    }
+
+    matches, err := file.Search("hello")
+    if err != nil { t.Fatalf("Search after a cancelled SearchContext: %v", err) }
+    if len(matches) != 1 || matches[0].Path != "Types" || matches[0].Value != "String" {
+        t.Errorf("Search(\"hello\"): got %+v, want the String Value of Types", matches)
+    }
+}
+
+func TestOpenFileContextCancelled(t *testing.T) {
+    path, err := regftest.Standard().TempFile(t.TempDir())
+    if err != nil { t.Fatal(err) }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    if _, err := libregf.OpenFileContext(ctx, path); !errors.Is(err, context.Canceled) {
+        t.Fatalf("OpenFileContext with a cancelled context: got %v, want context.Canceled", err)
+    }
+}
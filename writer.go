@@ -0,0 +1,717 @@
+package libregf
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "os"
+    "sort"
+    "strings"
+    "time"
+    "unicode/utf16"
+)
+
+// Limits on names enforced by Windows, in characters.
+const (
+    maxKeyNameLen   = 255
+    maxValueNameLen = 16383
+)
+
+// lh lists hold at most this many sub keys; beyond that they are split under
+// an index root (ri) list.
+const maxSubkeysListLen = 1012
+
+// Writer builds a registry file in memory and serializes it in the REGF format,
+// version 1.5, which both Windows and libregf load.
+// Use NewWriter() to start an empty hive, or EditHive() to start from an existing one.
+// A Writer is not safe for concurrent use.
+type Writer struct {
+    root      *WriterKey
+    timestamp time.Time
+}
+
+// WriterKey is a registry key being built by a Writer.
+type WriterKey struct {
+    writer      *Writer
+    parent      *WriterKey
+    name        string
+    class       string
+    security    []byte
+    lastWritten time.Time
+    subkeys     []*WriterKey
+    values      []*writerValue
+}
+
+type writerValue struct {
+    name  string
+    _type int
+    data  []byte
+}
+
+// NewWriter returns a Writer holding an empty hive, whose root Key is named "ROOT".
+func NewWriter() *Writer {
+    w := &Writer{timestamp: time.Now().UTC()}
+    w.root = &WriterKey{writer: w, name: "ROOT"}
+    return w
+}
+
+// EditHive returns a Writer holding a copy of every Key and Value of h, so
+// that it can be modified and written to a new file.
+// Class names are copied, as are last written times and security descriptors
+// when h's Keys provide them, as a File's Keys do.
+func EditHive(h Hive) (*Writer, error) {
+    root, err := h.GetRootKey()
+    if err != nil { return nil, err }
+    defer release(root)
+
+    w := NewWriter()
+    if err := w.root.copyFrom(root, map[int64]bool{}, 0); err != nil { return nil, err }
+
+    return w, nil
+}
+
+// EditFile is EditHive for the registry file at path.
+func EditFile(path string) (*Writer, error) {
+    file, err := OpenFile(path)
+    if err != nil { return nil, err }
+    defer file.Close()
+
+    return EditHive(file)
+}
+
+// copyFrom copies key, nested depth levels below the root Key, and its sub-Keys
+// into k. visited holds the offsets of the Keys copied so far, see enterKey.
+func (k *WriterKey) copyFrom(key RegistryKey, visited map[int64]bool, depth int) error {
+    if err := enterKey(key, visited, depth); err != nil { return err }
+
+    name, err := key.Name()
+    if err != nil { return err }
+    k.name = name
+
+    if k.class, err = key.ClassName(); err != nil { return err }
+    if lw, ok := key.(interface{ LastWritten() (time.Time, error) }); ok {
+        if k.lastWritten, err = lw.LastWritten(); err != nil { return err }
+    }
+    if sd, ok := key.(interface{ SecurityDescriptor() ([]byte, error) }); ok {
+        if k.security, err = sd.SecurityDescriptor(); err != nil { return err }
+    }
+
+    n, err := key.ValuesLen()
+    if err != nil { return err }
+    for i := 0; i < n; i++ {
+        value, err := key.GetValueAt(i)
+        if err != nil { return err }
+        name, err := value.Name()
+        if err != nil {
+            release(value)
+            return err
+        }
+        _type, err := value.Type()
+        if err != nil {
+            release(value)
+            return err
+        }
+        data, err := value.Data()
+        release(value)
+        if err != nil { return err }
+
+        k.values = append(k.values, &writerValue{name: name, _type: _type, data: data})
+    }
+
+    n, err = key.SubkeysLen()
+    if err != nil { return err }
+    for i := 0; i < n; i++ {
+        subkey, err := key.GetSubkeyAt(i)
+        if err != nil { return err }
+
+        sk := &WriterKey{writer: k.writer, parent: k}
+        err = sk.copyFrom(subkey, visited, depth+1)
+        release(subkey)
+        if err != nil { return err }
+
+        k.subkeys = append(k.subkeys, sk)
+    }
+
+    return nil
+}
+
+// SetTimestamp sets the time written in the base block of the hive, which is
+// also the last written time of every Key that doesn't have one of its own.
+// It defaults to the time the Writer was created.
+func (w *Writer) SetTimestamp(t time.Time) {
+    w.timestamp = t
+}
+
+// Root returns the root Key of the hive.
+func (w *Writer) Root() *WriterKey {
+    return w.root
+}
+
+// Key returns a Key by its path inside the hive.
+// Names are matched without regard to case.
+func (w *Writer) Key(path string) (*WriterKey, error) {
+    k := w.root
+    for _, name := range strings.Split(path, "\\") {
+        if name == "" { continue }
+
+        if k = k.Subkey(name); k == nil { return nil, errors.New("libregf: no such key: " + path) }
+    }
+
+    return k, nil
+}
+
+// CreateKey returns a Key by its path inside the hive, creating it and any
+// missing parent Keys along the way.
+func (w *Writer) CreateKey(path string) (*WriterKey, error) {
+    k := w.root
+    for _, name := range strings.Split(path, "\\") {
+        if name == "" { continue }
+
+        var err error
+        if k, err = k.CreateSubkey(name); err != nil { return nil, err }
+    }
+
+    return k, nil
+}
+
+// DeleteKey deletes a Key, along with all of its sub-Keys and Values.
+func (w *Writer) DeleteKey(path string) error {
+    k, err := w.Key(path)
+    if err != nil { return err }
+    if k == w.root { return errors.New("libregf: can't delete the root key") }
+
+    return k.parent.DeleteSubkey(k.name)
+}
+
+// Name returns the Key's name.
+func (k *WriterKey) Name() string {
+    return k.name
+}
+
+// Subkey returns the sub-Key of a Key by its name, or nil if there is none.
+// Names are matched without regard to case.
+func (k *WriterKey) Subkey(name string) *WriterKey {
+    for _, sk := range k.subkeys {
+        if EqualNames(sk.name, name) { return sk }
+    }
+    return nil
+}
+
+// CreateSubkey returns the sub-Key of a Key by its name, creating it if needed.
+func (k *WriterKey) CreateSubkey(name string) (*WriterKey, error) {
+    if sk := k.Subkey(name); sk != nil { return sk, nil }
+
+    if name == "" || strings.Contains(name, "\\") || len(utf16.Encode([]rune(name))) > maxKeyNameLen {
+        return nil, errors.New("libregf: invalid key name: " + name)
+    }
+
+    sk := &WriterKey{writer: k.writer, parent: k, name: name}
+    k.subkeys = append(k.subkeys, sk)
+    return sk, nil
+}
+
+// DeleteSubkey deletes the sub-Key of a Key by its name, along with all of its
+// own sub-Keys and Values.
+func (k *WriterKey) DeleteSubkey(name string) error {
+    for i, sk := range k.subkeys {
+        if EqualNames(sk.name, name) {
+            k.subkeys = append(k.subkeys[:i], k.subkeys[i+1:]...)
+            return nil
+        }
+    }
+    return errors.New("libregf: no such sub key: " + name)
+}
+
+// SetClassName sets the Key's class name. An empty class name removes it.
+func (k *WriterKey) SetClassName(class string) {
+    k.class = class
+}
+
+// SetSecurityDescriptor sets the Key's security descriptor, in the self-relative
+// binary form of the Windows API. Keys without one get a default descriptor
+// granting full control to SYSTEM and Administrators, and read access to Everyone.
+func (k *WriterKey) SetSecurityDescriptor(sd []byte) {
+    k.security = append([]byte{}, sd...)
+}
+
+// SetLastWritten sets the Key's last written time.
+func (k *WriterKey) SetLastWritten(t time.Time) {
+    k.lastWritten = t
+}
+
+// SetValue sets a Value of any type from its raw data, replacing any Value of
+// the same name. The default Value of a Key has an empty name.
+func (k *WriterKey) SetValue(name string, _type int, data []byte) {
+    v := &writerValue{name: name, _type: _type, data: append([]byte{}, data...)}
+    for i, old := range k.values {
+        if EqualNames(old.name, name) {
+            k.values[i] = v
+            return
+        }
+    }
+    k.values = append(k.values, v)
+}
+
+// SetString sets a Value of type LIBREGF_VALUE_TYPE_STRING.
+func (k *WriterKey) SetString(name, s string) {
+    k.SetValue(name, ValueTypeString, append(encodeUTF16(s), 0, 0))
+}
+
+// SetExpandableString sets a Value of type LIBREGF_VALUE_TYPE_EXPANDABLE_STRING.
+func (k *WriterKey) SetExpandableString(name, s string) {
+    k.SetValue(name, ValueTypeExpandableString, append(encodeUTF16(s), 0, 0))
+}
+
+// SetSymbolicLink sets a Value of type LIBREGF_VALUE_TYPE_SYMBOLIC_LINK, which,
+// unlike the other string types, is stored without a terminating NUL.
+func (k *WriterKey) SetSymbolicLink(name, target string) {
+    k.SetValue(name, ValueTypeSymbolicLink, encodeUTF16(target))
+}
+
+// SetStrings sets a Value of type LIBREGF_VALUE_TYPE_MULTI_VALUE_STRING.
+func (k *WriterKey) SetStrings(name string, strs []string) {
+    data := []byte{}
+    for _, s := range strs {
+        data = append(data, encodeUTF16(s)...)
+        data = append(data, 0, 0)
+    }
+    k.SetValue(name, ValueTypeMultiValueString, append(data, 0, 0))
+}
+
+// SetBinary sets a Value of type LIBREGF_VALUE_TYPE_BINARY_DATA.
+func (k *WriterKey) SetBinary(name string, data []byte) {
+    k.SetValue(name, ValueTypeBinaryData, data)
+}
+
+// SetUint32 sets a Value of type LIBREGF_VALUE_TYPE_INTEGER_32BIT_LITTLE_ENDIAN.
+func (k *WriterKey) SetUint32(name string, i uint32) {
+    data := make([]byte, 4)
+    le.PutUint32(data, i)
+    k.SetValue(name, ValueTypeInteger32BitLittleEndian, data)
+}
+
+// SetUint32BigEndian sets a Value of type LIBREGF_VALUE_TYPE_INTEGER_32BIT_BIG_ENDIAN.
+func (k *WriterKey) SetUint32BigEndian(name string, i uint32) {
+    data := []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)}
+    k.SetValue(name, ValueTypeInteger32BitBigEndian, data)
+}
+
+// SetUint64 sets a Value of type LIBREGF_VALUE_TYPE_INTEGER_64BIT_LITTLE_ENDIAN.
+func (k *WriterKey) SetUint64(name string, i uint64) {
+    data := make([]byte, 8)
+    le.PutUint64(data, i)
+    k.SetValue(name, ValueTypeInteger64BitLittleEndian, data)
+}
+
+// DeleteValue deletes a Value of a Key by its name.
+func (k *WriterKey) DeleteValue(name string) error {
+    for i, v := range k.values {
+        if EqualNames(v.name, name) {
+            k.values = append(k.values[:i], k.values[i+1:]...)
+            return nil
+        }
+    }
+    return errors.New("libregf: no such value: " + name)
+}
+
+// WriteFile writes the hive to a registry file at path.
+func (w *Writer) WriteFile(path string) error {
+    data, err := w.Bytes()
+    if err != nil { return err }
+
+    return os.WriteFile(path, data, 0644)
+}
+
+// WriteTo writes the hive to out, in the REGF format.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+    data, err := w.Bytes()
+    if err != nil { return 0, err }
+
+    n, err := out.Write(data)
+    return int64(n), err
+}
+
+// Bytes returns the hive in the REGF format.
+func (w *Writer) Bytes() ([]byte, error) {
+    s := &serializer{w: w, nk: map[*WriterKey]uint32{}, sk: map[string]*skCell{}}
+    if err := s.layout(); err != nil { return nil, err }
+
+    block := make([]byte, baseBlockSize)
+    copy(block, "regf")
+    le.PutUint32(block[4:], 1)  // primary sequence number
+    le.PutUint32(block[8:], 1)  // secondary sequence number
+    le.PutUint64(block[12:], timeToFiletime(w.timestamp))
+    le.PutUint32(block[20:], 1) // major version
+    le.PutUint32(block[24:], 5) // minor version
+    le.PutUint32(block[28:], 0) // primary file
+    le.PutUint32(block[32:], 1) // direct memory load
+    le.PutUint32(block[36:], s.nk[w.root])
+    le.PutUint32(block[40:], uint32(len(s.cells.buf)))
+    le.PutUint32(block[44:], 1) // clustering factor
+    le.PutUint32(block[508:], baseBlockChecksum(block))
+
+    return append(block, s.cells.buf...), nil
+}
+
+// cellAllocator lays out cells in hive bins, the way Windows does: a new bin is
+// started whenever a cell doesn't fit in the current one, and the space left
+// at the end of a bin is turned into a free cell.
+type cellAllocator struct {
+    buf       []byte
+    binEnd    int
+    timestamp uint64
+}
+
+// alloc allocates an allocated cell with room for size bytes of data, and
+// returns its offset.
+func (a *cellAllocator) alloc(size int) uint32 {
+    size = (size + 4 + cellAlignment - 1) &^ (cellAlignment - 1)
+
+    if len(a.buf)+size > a.binEnd {
+        a.closeBin()
+
+        start := len(a.buf)
+        binSize := (hbinHeaderSize + size + hbinAlignment - 1) &^ (hbinAlignment - 1)
+        a.buf = append(a.buf, make([]byte, binSize)...)
+        copy(a.buf[start:], "hbin")
+        le.PutUint32(a.buf[start+4:], uint32(start))
+        le.PutUint32(a.buf[start+8:], uint32(binSize))
+        if start == 0 { le.PutUint64(a.buf[start+20:], a.timestamp) }
+        a.binEnd = start + binSize
+        a.buf = a.buf[:start+hbinHeaderSize]
+    }
+
+    offset := len(a.buf)
+    a.buf = a.buf[:offset+size]
+    le.PutUint32(a.buf[offset:], uint32(-int32(size)))
+    return uint32(offset)
+}
+
+// closeBin turns the space left at the end of the current bin into a free cell.
+func (a *cellAllocator) closeBin() {
+    if rest := a.binEnd - len(a.buf); rest > 0 {
+        le.PutUint32(a.buf[len(a.buf):a.binEnd], uint32(rest))
+    }
+    a.buf = a.buf[:a.binEnd]
+}
+
+// cell returns the data of the cell at offset.
+func (a *cellAllocator) cell(offset uint32) []byte {
+    size := -int32(le.Uint32(a.buf[offset:]))
+    return a.buf[offset+4 : offset+uint32(size)]
+}
+
+// skCell is a security key (sk) record shared by all the Keys with the same
+// security descriptor.
+type skCell struct {
+    offset uint32
+    refs   uint32
+}
+
+type serializer struct {
+    w     *Writer
+    cells cellAllocator
+    nk    map[*WriterKey]uint32
+    sk    map[string]*skCell
+    sks   []*skCell
+}
+
+// layout allocates and fills every cell of the hive: key (nk) records first,
+// since they refer to each other, then everything else, Key by Key.
+func (s *serializer) layout() error {
+    s.cells.timestamp = timeToFiletime(s.w.timestamp)
+
+    var allocKeys func(k *WriterKey) error
+    allocKeys = func(k *WriterKey) error {
+        name, _ := encodeName(k.name)
+        s.nk[k] = s.cells.alloc(nkHeaderSize + len(name))
+
+        for _, sk := range k.subkeys {
+            if err := allocKeys(sk); err != nil { return err }
+        }
+        return nil
+    }
+    if err := allocKeys(s.w.root); err != nil { return err }
+
+    var fillKeys func(k *WriterKey) error
+    fillKeys = func(k *WriterKey) error {
+        if err := s.fillKey(k); err != nil { return err }
+
+        for _, sk := range k.subkeys {
+            if err := fillKeys(sk); err != nil { return err }
+        }
+        return nil
+    }
+    if err := fillKeys(s.w.root); err != nil { return err }
+
+    // security key records form a circular doubly linked list
+    for i, sk := range s.sks {
+        cell := s.cells.cell(sk.offset)
+        le.PutUint32(cell[4:], s.sks[(i+1)%len(s.sks)].offset)
+        le.PutUint32(cell[8:], s.sks[(i+len(s.sks)-1)%len(s.sks)].offset)
+        le.PutUint32(cell[12:], sk.refs)
+    }
+
+    s.cells.closeBin()
+    return nil
+}
+
+func (s *serializer) fillKey(k *WriterKey) error {
+    name, compressed := encodeName(k.name)
+
+    flags := uint16(0)
+    if compressed { flags |= keyFlagCompressedName }
+    parent := uint32(noOffset)
+    if k.parent == nil {
+        flags |= keyFlagHiveEntry | keyFlagNoDelete
+    } else {
+        parent = s.nk[k.parent]
+    }
+
+    lastWritten := k.lastWritten
+    if lastWritten.IsZero() { lastWritten = s.w.timestamp }
+
+    // sub keys are listed sorted by their upper case names
+    subkeys := append([]*WriterKey{}, k.subkeys...)
+    sort.Slice(subkeys, func(i, j int) bool {
+        return CompareNames(subkeys[i].name, subkeys[j].name) < 0
+    })
+    for i := 1; i < len(subkeys); i++ {
+        if CompareNames(subkeys[i-1].name, subkeys[i].name) == 0 {
+            return errors.New("libregf: duplicate key name: " + subkeys[i].name)
+        }
+    }
+
+    subkeysList := uint32(noOffset)
+    maxSubkeyName, maxSubkeyClass := 0, 0
+    if len(subkeys) > 0 { subkeysList = s.subkeysList(subkeys) }
+    for _, sk := range subkeys {
+        if l := 2 * len(utf16.Encode([]rune(sk.name))); l > maxSubkeyName { maxSubkeyName = l }
+        if l := 2 * len(utf16.Encode([]rune(sk.class))); l > maxSubkeyClass { maxSubkeyClass = l }
+    }
+
+    valuesList := uint32(noOffset)
+    maxValueName, maxValueData := 0, 0
+    if len(k.values) > 0 {
+        valuesList = s.cells.alloc(4 * len(k.values))
+        for i, v := range k.values {
+            if len(utf16.Encode([]rune(v.name))) > maxValueNameLen {
+                return errors.New("libregf: invalid value name: " + v.name)
+            }
+            if l := 2 * len(utf16.Encode([]rune(v.name))); l > maxValueName { maxValueName = l }
+            if len(v.data) > maxValueData { maxValueData = len(v.data) }
+
+            vk := s.value(v)
+            le.PutUint32(s.cells.cell(valuesList)[4*i:], vk)
+        }
+    }
+
+    class := uint32(noOffset)
+    classLen := 0
+    if k.class != "" {
+        data := encodeUTF16(k.class)
+        classLen = len(data)
+        class = s.cells.alloc(len(data))
+        copy(s.cells.cell(class), data)
+    }
+
+    // cells are slices of a buffer which grows as cells are allocated, so the
+    // key record is only sliced once everything it points to is
+    security := s.security(k.security)
+
+    nk := s.cells.cell(s.nk[k])
+    copy(nk, "nk")
+    le.PutUint16(nk[2:], flags)
+    le.PutUint64(nk[4:], timeToFiletime(lastWritten))
+    le.PutUint32(nk[16:], parent)
+    le.PutUint32(nk[20:], uint32(len(subkeys)))
+    le.PutUint32(nk[28:], subkeysList)
+    le.PutUint32(nk[32:], noOffset)
+    le.PutUint32(nk[36:], uint32(len(k.values)))
+    le.PutUint32(nk[40:], valuesList)
+    le.PutUint32(nk[44:], security)
+    le.PutUint32(nk[48:], class)
+    le.PutUint32(nk[52:], uint32(maxSubkeyName))
+    le.PutUint32(nk[56:], uint32(maxSubkeyClass))
+    le.PutUint32(nk[60:], uint32(maxValueName))
+    le.PutUint32(nk[64:], uint32(maxValueData))
+    le.PutUint16(nk[72:], uint16(len(name)))
+    le.PutUint16(nk[74:], uint16(classLen))
+    copy(nk[nkHeaderSize:], name)
+
+    return nil
+}
+
+// subkeysList writes a hash leaf (lh) list of sorted sub keys, split under an
+// index root (ri) list when there are too many of them, and returns its offset.
+func (s *serializer) subkeysList(subkeys []*WriterKey) uint32 {
+    if len(subkeys) > maxSubkeysListLen {
+        lists := []uint32{}
+        for i := 0; i < len(subkeys); i += maxSubkeysListLen {
+            j := i + maxSubkeysListLen
+            if j > len(subkeys) { j = len(subkeys) }
+            lists = append(lists, s.subkeysList(subkeys[i:j]))
+        }
+
+        ri := s.cells.alloc(4 + 4*len(lists))
+        cell := s.cells.cell(ri)
+        copy(cell, "ri")
+        le.PutUint16(cell[2:], uint16(len(lists)))
+        for i, l := range lists {
+            le.PutUint32(cell[4+4*i:], l)
+        }
+        return ri
+    }
+
+    lh := s.cells.alloc(4 + 8*len(subkeys))
+    cell := s.cells.cell(lh)
+    copy(cell, "lh")
+    le.PutUint16(cell[2:], uint16(len(subkeys)))
+    for i, sk := range subkeys {
+        le.PutUint32(cell[4+8*i:], s.nk[sk])
+        le.PutUint32(cell[8+8*i:], nameHash(sk.name))
+    }
+    return lh
+}
+
+// value writes a value (vk) record along with its data, and returns its offset.
+func (s *serializer) value(v *writerValue) uint32 {
+    name, compressed := encodeName(v.name)
+
+    size := uint32(len(v.data))
+    offset := uint32(0)
+    switch {
+    case len(v.data) <= 4:
+        size |= valueDataInline
+        var inline [4]byte
+        copy(inline[:], v.data)
+        offset = le.Uint32(inline[:])
+    case len(v.data) > bigDataSegmentSize:
+        offset = s.bigData(v.data)
+    default:
+        offset = s.cells.alloc(len(v.data))
+        copy(s.cells.cell(offset), v.data)
+    }
+
+    flags := uint16(0)
+    if compressed && len(name) > 0 { flags |= valueFlagCompressedName }
+
+    vk := s.cells.alloc(vkHeaderSize + len(name))
+    cell := s.cells.cell(vk)
+    copy(cell, "vk")
+    le.PutUint16(cell[2:], uint16(len(name)))
+    le.PutUint32(cell[4:], size)
+    le.PutUint32(cell[8:], offset)
+    le.PutUint32(cell[12:], uint32(v._type))
+    le.PutUint16(cell[16:], flags)
+    copy(cell[vkHeaderSize:], name)
+
+    return vk
+}
+
+// bigData writes a big data (db) record with its segments, and returns its offset.
+func (s *serializer) bigData(data []byte) uint32 {
+    segments := []uint32{}
+    for i := 0; i < len(data); i += bigDataSegmentSize {
+        j := i + bigDataSegmentSize
+        if j > len(data) { j = len(data) }
+
+        segment := s.cells.alloc(j - i)
+        copy(s.cells.cell(segment), data[i:j])
+        segments = append(segments, segment)
+    }
+
+    list := s.cells.alloc(4 * len(segments))
+    for i, segment := range segments {
+        le.PutUint32(s.cells.cell(list)[4*i:], segment)
+    }
+
+    db := s.cells.alloc(dbHeaderSize)
+    cell := s.cells.cell(db)
+    copy(cell, "db")
+    le.PutUint16(cell[2:], uint16(len(segments)))
+    le.PutUint32(cell[4:], list)
+    return db
+}
+
+// security returns the offset of the security key (sk) record holding sd,
+// writing it if no Key used it yet.
+func (s *serializer) security(sd []byte) uint32 {
+    if len(sd) == 0 { sd = defaultSecurityDescriptor }
+
+    if sk, ok := s.sk[string(sd)]; ok {
+        sk.refs++
+        return sk.offset
+    }
+
+    offset := s.cells.alloc(skHeaderSize + len(sd))
+    cell := s.cells.cell(offset)
+    copy(cell, "sk")
+    le.PutUint32(cell[16:], uint32(len(sd)))
+    copy(cell[skHeaderSize:], sd)
+
+    sk := &skCell{offset: offset, refs: 1}
+    s.sk[string(sd)] = sk
+    s.sks = append(s.sks, sk)
+    return offset
+}
+
+// encodeName encodes a Key or Value name the way Windows stores it: one byte
+// per character when that is lossless, UTF-16LE otherwise.
+func encodeName(name string) ([]byte, bool) {
+    b := make([]byte, 0, len(name))
+    for _, r := range name {
+        // bytes 0x80 to 0x9f would be read back through codepage 1252
+        if r > 0xff || (r >= 0x80 && r < 0xa0) { return encodeUTF16(name), false }
+        b = append(b, byte(r))
+    }
+    return b, true
+}
+
+// nameHash computes the hash of a sub key name stored in hash leaf (lh) lists.
+func nameHash(name string) uint32 {
+    var h uint32
+    for _, c := range upcaseUTF16(name) {
+        h = h*37 + uint32(c)
+    }
+    return h
+}
+
+// defaultSecurityDescriptor grants full control to SYSTEM and Administrators
+// and read access to Everyone, inherited by sub keys, with Administrators as
+// owner and SYSTEM as group.
+var defaultSecurityDescriptor = func() []byte {
+    system := []byte{1, 1, 0, 0, 0, 0, 0, 5, 18, 0, 0, 0}
+    admins := []byte{1, 2, 0, 0, 0, 0, 0, 5, 32, 0, 0, 0, 0x20, 2, 0, 0}
+    everyone := []byte{1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+
+    ace := func(mask uint32, sid []byte) []byte {
+        b := make([]byte, 8, 8+len(sid))
+        b[0] = 0    // ACCESS_ALLOWED_ACE_TYPE
+        b[1] = 0x02 // CONTAINER_INHERIT_ACE
+        le.PutUint16(b[2:], uint16(8+len(sid)))
+        le.PutUint32(b[4:], mask)
+        return append(b, sid...)
+    }
+    aces := bytes.Join([][]byte{
+        ace(0xf003f, system), // KEY_ALL_ACCESS
+        ace(0xf003f, admins),
+        ace(0x20019, everyone), // KEY_READ
+    }, nil)
+
+    acl := make([]byte, 8, 8+len(aces))
+    acl[0] = 2 // ACL_REVISION
+    le.PutUint16(acl[2:], uint16(8+len(aces)))
+    le.PutUint16(acl[4:], 3)
+    acl = append(acl, aces...)
+
+    sd := make([]byte, 20)
+    sd[0] = 1                      // revision
+    le.PutUint16(sd[2:], 0x8004)   // SE_SELF_RELATIVE | SE_DACL_PRESENT
+    le.PutUint32(sd[4:], uint32(20+len(acl)))
+    le.PutUint32(sd[8:], uint32(20+len(acl)+len(admins)))
+    le.PutUint32(sd[16:], 20)
+    sd = append(sd, acl...)
+    sd = append(sd, admins...)
+    return append(sd, system...)
+}()
@@ -0,0 +1,178 @@
+package libregf_test
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/jdrowell/go-libregf"
+)
+
+// keySnapshot is what a Key holds, as read back from a registry file.
+type keySnapshot struct {
+    Class       string
+    LastWritten time.Time
+    Security    []byte
+    Values      map[string]valueSnapshot
+}
+
+type valueSnapshot struct {
+    Type int
+    Data []byte
+}
+
+// snapshot reads every Key and Value of h, by path.
+func snapshot(t *testing.T, h libregf.Hive) map[string]keySnapshot {
+    t.Helper()
+
+    keys := map[string]keySnapshot{}
+    err := libregf.Walk(h, func(path string, key libregf.RegistryKey) error {
+        k := key.(*libregf.Key)
+        snap := keySnapshot{Values: map[string]valueSnapshot{}}
+        var err error
+        if snap.Class, err = k.ClassName(); err != nil { return err }
+        if snap.LastWritten, err = k.LastWritten(); err != nil { return err }
+        if snap.Security, err = k.SecurityDescriptor(); err != nil { return err }
+
+        n, err := k.ValuesLen()
+        if err != nil { return err }
+        for i := 0; i < n; i++ {
+            value, err := k.ValueAt(i)
+            if err != nil { return err }
+            name, err := value.Name()
+            if err != nil { return err }
+            _type, err := value.Type()
+            if err != nil { return err }
+            data, err := value.Data()
+            if err != nil { return err }
+            value.Free()
+            snap.Values[name] = valueSnapshot{Type: _type, Data: data}
+        }
+
+        keys[path] = snap
+        return nil
+    })
+    if err != nil { t.Fatalf("reading back: %v", err) }
+
+    return keys
+}
+
+// securityDescriptor returns a self-relative security descriptor whose owner
+// is S-1-5-21-n.
+func securityDescriptor(n uint32) []byte {
+    sd := make([]byte, 20+16)
+    sd[0] = 1                                     // revision
+    binary.LittleEndian.PutUint16(sd[2:], 0x8000) // self-relative
+    binary.LittleEndian.PutUint32(sd[4:], 20)     // offset of the owner
+    copy(sd[20:], []byte{1, 2, 0, 0, 0, 0, 0, 5})
+    binary.LittleEndian.PutUint32(sd[28:], 21)
+    binary.LittleEndian.PutUint32(sd[32:], n)
+    return sd
+}
+
+// writeAndOpen writes w to a new file and opens it.
+func writeAndOpen(t *testing.T, w *libregf.Writer) *libregf.File {
+    t.Helper()
+
+    path := filepath.Join(t.TempDir(), "hive")
+    if err := w.WriteFile(path); err != nil { t.Fatalf("WriteFile: %v", err) }
+    file, err := libregf.OpenFile(path)
+    if err != nil { t.Fatalf("OpenFile: %v", err) }
+    t.Cleanup(file.Close)
+
+    return file
+}
+
+// checkRoundTrip compares the Keys read back from file to what was written.
+func checkRoundTrip(t *testing.T, file *libregf.File, want map[string]keySnapshot) {
+    t.Helper()
+
+    got := snapshot(t, file)
+    for path, w := range want {
+        g, ok := got[path]
+        if !ok {
+            t.Errorf("key %q is missing", path)
+            continue
+        }
+        if g.Class != w.Class { t.Errorf("key %q: class %q, want %q", path, g.Class, w.Class) }
+        if !w.LastWritten.IsZero() && !g.LastWritten.Equal(w.LastWritten) {
+            t.Errorf("key %q: last written %v, want %v", path, g.LastWritten, w.LastWritten)
+        }
+        if w.Security != nil && !bytes.Equal(g.Security, w.Security) {
+            t.Errorf("key %q: security descriptor %x, want %x", path, g.Security, w.Security)
+        }
+        if !reflect.DeepEqual(g.Values, w.Values) { t.Errorf("key %q: values %v, want %v", path, g.Values, w.Values) }
+    }
+    for path := range got {
+        if _, ok := want[path]; !ok && path != "" { t.Errorf("unexpected key %q", path) }
+    }
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+    lw := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+    w := libregf.NewWriter()
+    want := map[string]keySnapshot{}
+
+    values := []struct {
+        name  string
+        _type int
+        data  []byte
+    }{
+        {"", libregf.ValueTypeString, []byte("d\x00\x00\x00")},
+        {"Inline", libregf.ValueTypeInteger32BitLittleEndian, []byte{1, 2, 3, 4}},
+        {"Empty", libregf.ValueTypeBinaryData, []byte{}},
+        {"Small", libregf.ValueTypeBinaryData, []byte{1, 2, 3, 4, 5}},
+        {"Qword", libregf.ValueTypeInteger64BitLittleEndian, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+        {"Big", libregf.ValueTypeBinaryData, bytes.Repeat([]byte("0123456789abcdef"), 5000)},
+        {"Ünïcödé", libregf.ValueTypeMultiValueString, []byte("a\x00\x00\x00\x00\x00")},
+    }
+
+    for i := 0; i < 300; i++ {
+        path := fmt.Sprintf("Secured\\Key%03d", i)
+        key, err := w.CreateKey(path)
+        if err != nil { t.Fatal(err) }
+
+        snap := keySnapshot{Class: fmt.Sprintf("class %d", i), LastWritten: lw.Add(time.Duration(i) * time.Second), Security: securityDescriptor(uint32(i)), Values: map[string]valueSnapshot{}}
+        key.SetClassName(snap.Class)
+        key.SetLastWritten(snap.LastWritten)
+        key.SetSecurityDescriptor(snap.Security)
+        for _, v := range values[:1+i%len(values)] {
+            key.SetValue(v.name, v._type, v.data)
+            snap.Values[v.name] = valueSnapshot{Type: v._type, Data: v.data}
+        }
+        want[path] = snap
+    }
+    want["Secured"] = keySnapshot{Values: map[string]valueSnapshot{}}
+
+    // enough sub keys to need an index root (ri) list
+    for i := 0; i < 2100; i++ {
+        path := fmt.Sprintf("Many\\%s%d", strings.Repeat("k", i%7), i)
+        if _, err := w.CreateKey(path); err != nil { t.Fatal(err) }
+        want[path] = keySnapshot{Values: map[string]valueSnapshot{}}
+    }
+    want["Many"] = keySnapshot{Values: map[string]valueSnapshot{}}
+
+    file := writeAndOpen(t, w)
+    checkRoundTrip(t, file, want)
+
+    // a copy made by EditHive reads back the same
+    edited, err := libregf.EditHive(file)
+    if err != nil { t.Fatal(err) }
+    if got, want := snapshot(t, writeAndOpen(t, edited)), snapshot(t, file); !reflect.DeepEqual(got, want) {
+        t.Errorf("EditHive changed the hive")
+    }
+}
+
+func TestWriterDuplicateNames(t *testing.T) {
+    w := libregf.NewWriter()
+    w.Root().SetString("Name", "first")
+    w.Root().SetString("NAME", "second")
+    file := writeAndOpen(t, w)
+
+    if s, err := file.GetString("name"); err != nil || s != "second" { t.Errorf("got %q, %v, want the last value set", s, err) }
+}